@@ -0,0 +1,63 @@
+// Command simnet serves a simulated NEAR network for running the warchest
+// end to end without a node:
+//
+//	go run ./cmd/simnet -credentials /tmp/simnet-keys
+//	go-warchest -url http://localhost:3030 -network simnet -credentials /tmp/simnet-keys \
+//		-accountId pool.simnet -delegatorId owner.simnet
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/rozum-dev/near-go-warchest/common"
+	"github.com/rozum-dev/near-go-warchest/rpc/simnet"
+)
+
+func main() {
+	def := simnet.DefaultConfig()
+	addr := flag.String("addr", ":3030", "listen address")
+	credentialsDir := flag.String("credentials", "simnet-credentials", "Directory to write the delegator keys to")
+	poolId := flag.String("pool", "pool.simnet", "Staking pool account id")
+	owner := flag.String("owner", "owner.simnet", "Pool owner and delegator account id")
+	balance := flag.Int64("balance", 1000000, "Liquid balance of the owner in NEAR")
+	deposit := flag.Int64("deposit", 150000, "Unstaked balance of the owner in the pool in NEAR")
+	validators := flag.Int("validators", 12, "Number of validators competing for seats")
+	blockTime := flag.Duration("block-time", def.BlockTime, "Time between blocks, 0 to never advance")
+	epochLength := flag.Int64("epoch-length", def.EpochLength, "Blocks per epoch")
+	seats := flag.Int("seats", def.NumSeats, "Block producer seats")
+	protocolVersion := flag.Int("protocol-version", def.ProtocolVersion, "Protocol version, 49 and later use the newer seat price")
+	flag.Parse()
+
+	cfg := def
+	cfg.BlockTime = *blockTime
+	cfg.EpochLength = *epochLength
+	cfg.NumSeats = *seats
+	cfg.ProtocolVersion = *protocolVersion
+	net := simnet.New(cfg)
+
+	pub, err := simnet.WriteKey(*credentialsDir, cfg.ChainId, *owner)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	net.AddAccount(*owner, common.NearAmount(*balance), pub)
+	net.AddPool(*poolId, *owner, "ed25519:"+*poolId)
+	net.Deposit(*poolId, *owner, common.NearAmount(*deposit))
+	// Stakes from 20k to 20k*validators NEAR, so the seat price is within reach
+	for i := 1; i <= *validators; i++ {
+		net.AddValidator(fmt.Sprintf("validator%d.simnet", i), common.NearAmount(int64(i)*20000))
+	}
+
+	if *blockTime > 0 {
+		go func() {
+			for range time.Tick(*blockTime) {
+				net.Advance(1)
+			}
+		}()
+	}
+	log.Printf("Simnet serving on %s, keys of %s in %s\n", *addr, *owner, *credentialsDir)
+	log.Fatalln(http.ListenAndServe(*addr, net))
+}
@@ -0,0 +1,91 @@
+package common
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	MinAccountIDLength = 2
+	MaxAccountIDLength = 64
+)
+
+// AccountID is a NEAR account id, such as "pool.poolv1.near". An id is 2 to
+// 64 characters long and is made of lowercase letters and digits; the
+// separators ".", "-" and "_" may only stand between them. An implicit
+// account is 64 lowercase hex characters, the hex of an ed25519 public key.
+// A top-level account has no dot.
+type AccountID string
+
+// ParseAccountID returns s as an account id, or an error saying what is wrong with it.
+func ParseAccountID(s string) (AccountID, error) {
+	id := AccountID(s)
+	if err := id.Validate(); err != nil {
+		return "", err
+	}
+	return id, nil
+}
+
+// Validate checks the account id rules of the protocol.
+func (id AccountID) Validate() error {
+	s := string(id)
+	if len(s) < MinAccountIDLength || len(s) > MaxAccountIDLength {
+		return fmt.Errorf("invalid account id %q: must be %d to %d characters long", s, MinAccountIDLength, MaxAccountIDLength)
+	}
+	prevSeparator := true
+	for i, c := range s {
+		switch {
+		case c >= 'a' && c <= 'z', c >= '0' && c <= '9':
+			prevSeparator = false
+		case c == '.' || c == '-' || c == '_':
+			if prevSeparator {
+				if i == 0 {
+					return fmt.Errorf("invalid account id %q: cannot start with %q", s, c)
+				}
+				return fmt.Errorf("invalid account id %q: %q cannot follow another separator", s, c)
+			}
+			prevSeparator = true
+		case c >= 'A' && c <= 'Z':
+			return fmt.Errorf("invalid account id %q: must be lowercase", s)
+		default:
+			return fmt.Errorf("invalid account id %q: %q is not allowed, only a-z, 0-9, '.', '-' and '_'", s, c)
+		}
+	}
+	if prevSeparator {
+		return fmt.Errorf("invalid account id %q: cannot end with a separator", s)
+	}
+	return nil
+}
+
+// IsImplicit reports whether the id is the hex of a public key.
+func (id AccountID) IsImplicit() bool {
+	if len(id) != 64 {
+		return false
+	}
+	for _, c := range id {
+		if !(c >= 'a' && c <= 'f' || c >= '0' && c <= '9') {
+			return false
+		}
+	}
+	return true
+}
+
+// IsTopLevel reports whether the id is a top-level account such as "near".
+// Implicit accounts are top-level too.
+func (id AccountID) IsTopLevel() bool {
+	return !strings.Contains(string(id), ".")
+}
+
+func (id AccountID) String() string {
+	return string(id)
+}
+
+// Set parses a command line flag.
+func (id *AccountID) Set(s string) error {
+	v, err := ParseAccountID(s)
+	if err != nil {
+		return err
+	}
+	*id = v
+	return nil
+}
@@ -0,0 +1,80 @@
+package common
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseAccountID(t *testing.T) {
+	implicit := "98793cd91a3f870fb126f66285808c7e094afcfc4eda8a970f6648cdf0dbd6de"
+	tests := []struct {
+		id string
+		// Part of the error, empty for a valid id
+		err string
+	}{
+		{"near", ""},
+		{"pool.poolv1.near", ""},
+		{"my_pool.pool.f863973.m0", ""},
+		{"a-b_c.d", ""},
+		{"10", ""},
+		{implicit, ""},
+		{strings.Repeat("a", 64), ""},
+		// Length
+		{"a", "2 to 64 characters"},
+		{"", "2 to 64 characters"},
+		{strings.Repeat("a", 65), "2 to 64 characters"},
+		{implicit + "0", "2 to 64 characters"},
+		// Separators
+		{".near", "cannot start with '.'"},
+		{"-near", "cannot start with '-'"},
+		{"near.", "cannot end with a separator"},
+		{"near_", "cannot end with a separator"},
+		{"pool..near", "'.' cannot follow another separator"},
+		{"pool.-near", "'-' cannot follow another separator"},
+		{"pool_-near", "'-' cannot follow another separator"},
+		// Casing and characters
+		{"Pool.near", "must be lowercase"},
+		{strings.ToUpper(implicit), "must be lowercase"},
+		{"pool near", "' ' is not allowed"},
+		{"pool@near", "'@' is not allowed"},
+		{"--help", "cannot start with '-'"},
+		{"пул.near", "is not allowed"},
+	}
+	for _, tt := range tests {
+		id, err := ParseAccountID(tt.id)
+		switch {
+		case tt.err == "" && err != nil:
+			t.Errorf("%q: %v", tt.id, err)
+		case tt.err == "" && id.String() != tt.id:
+			t.Errorf("%q: parsed as %q", tt.id, id)
+		case tt.err != "" && err == nil:
+			t.Errorf("%q: accepted, want an error with %q", tt.id, tt.err)
+		case tt.err != "" && !strings.Contains(err.Error(), tt.err):
+			t.Errorf("%q: error %q, want %q", tt.id, err, tt.err)
+		}
+	}
+}
+
+func TestAccountIDKinds(t *testing.T) {
+	tests := []struct {
+		id       AccountID
+		implicit bool
+		topLevel bool
+	}{
+		{"near", false, true},
+		{"pool.poolv1.near", false, false},
+		{"98793cd91a3f870fb126f66285808c7e094afcfc4eda8a970f6648cdf0dbd6de", true, true},
+		// Not hex
+		{AccountID(strings.Repeat("g", 64)), false, true},
+		// Hex but too short
+		{"98793cd91a3f870fb126f66285808c7e", false, true},
+	}
+	for _, tt := range tests {
+		if got := tt.id.IsImplicit(); got != tt.implicit {
+			t.Errorf("%q: implicit %v, want %v", tt.id, got, tt.implicit)
+		}
+		if got := tt.id.IsTopLevel(); got != tt.topLevel {
+			t.Errorf("%q: top-level %v, want %v", tt.id, got, tt.topLevel)
+		}
+	}
+}
@@ -0,0 +1,200 @@
+package common
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+const nearDecimals = 24
+
+var yoctoPerNear = new(big.Int).Exp(big.NewInt(10), big.NewInt(nearDecimals), nil)
+
+// Amount is an exact amount of NEAR tokens in yoctoNEAR (1 NEAR = 10^24 yoctoNEAR).
+// The zero value is 0. Amounts are immutable; arithmetic returns a new Amount.
+type Amount struct {
+	v *big.Int
+}
+
+// ParseAmount parses a yoctoNEAR integer string as returned by the RPC and the staking pool.
+func ParseAmount(yocto string) (Amount, error) {
+	v, ok := new(big.Int).SetString(strings.TrimSpace(yocto), 10)
+	if !ok || v.Sign() < 0 {
+		return Amount{}, fmt.Errorf("invalid yoctoNEAR amount %q", yocto)
+	}
+	return Amount{v}, nil
+}
+
+// ParseNear parses a decimal amount of NEAR such as "1,234.5678".
+func ParseNear(s string) (Amount, error) {
+	s = strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(s), "NEAR"))
+	s = strings.Replace(s, ",", "", -1)
+	parts := strings.SplitN(s, ".", 2)
+	whole := parts[0]
+	var frac string
+	if len(parts) == 2 {
+		frac = parts[1]
+	}
+	if len(frac) > nearDecimals {
+		return Amount{}, fmt.Errorf("too many decimals in %q", s)
+	}
+	if whole == "" && frac == "" {
+		return Amount{}, fmt.Errorf("invalid NEAR amount %q", s)
+	}
+	v, ok := new(big.Int).SetString(whole+frac+strings.Repeat("0", nearDecimals-len(frac)), 10)
+	if !ok || v.Sign() < 0 {
+		return Amount{}, fmt.Errorf("invalid NEAR amount %q", s)
+	}
+	return Amount{v}, nil
+}
+
+// NearAmount returns n whole NEAR.
+func NearAmount(n int64) Amount {
+	return Amount{new(big.Int).Mul(big.NewInt(n), yoctoPerNear)}
+}
+
+// NewAmount returns an Amount holding a copy of v yoctoNEAR.
+func NewAmount(v *big.Int) Amount {
+	return Amount{new(big.Int).Set(v)}
+}
+
+func (a Amount) int() *big.Int {
+	if a.v == nil {
+		return new(big.Int)
+	}
+	return a.v
+}
+
+// BigInt returns a copy of the amount in yoctoNEAR.
+func (a Amount) BigInt() *big.Int {
+	return new(big.Int).Set(a.int())
+}
+
+func (a Amount) Add(b Amount) Amount {
+	return Amount{new(big.Int).Add(a.int(), b.int())}
+}
+
+func (a Amount) Sub(b Amount) Amount {
+	return Amount{new(big.Int).Sub(a.int(), b.int())}
+}
+
+func (a Amount) Mul(n int64) Amount {
+	return Amount{new(big.Int).Mul(a.int(), big.NewInt(n))}
+}
+
+// Div divides by n, rounding towards zero.
+func (a Amount) Div(n int64) Amount {
+	return Amount{new(big.Int).Quo(a.int(), big.NewInt(n))}
+}
+
+// MulFrac returns a*num/den, rounding towards zero.
+func (a Amount) MulFrac(num, den int64) Amount {
+	v := new(big.Int).Mul(a.int(), big.NewInt(num))
+	return Amount{v.Quo(v, big.NewInt(den))}
+}
+
+func (a Amount) Cmp(b Amount) int {
+	return a.int().Cmp(b.int())
+}
+
+func (a Amount) Sign() int {
+	return a.int().Sign()
+}
+
+func (a Amount) IsZero() bool {
+	return a.Sign() == 0
+}
+
+// Ratio returns a/b as a float, or 0 when b is zero.
+func (a Amount) Ratio(b Amount) float64 {
+	if b.IsZero() {
+		return 0
+	}
+	f, _ := new(big.Rat).SetFrac(a.int(), b.int()).Float64()
+	return f
+}
+
+// Near returns the amount in NEAR as a float, for metrics.
+func (a Amount) Near() float64 {
+	f, _ := new(big.Rat).SetFrac(a.int(), yoctoPerNear).Float64()
+	return f
+}
+
+// Yocto returns the amount as a yoctoNEAR integer string, as expected by contract calls.
+func (a Amount) Yocto() string {
+	return a.int().String()
+}
+
+// NearString returns the exact amount of NEAR as a plain decimal such as
+// "1234.5", as near-shell expects for --amount.
+func (a Amount) NearString() string {
+	v := a.int()
+	sign := ""
+	if v.Sign() < 0 {
+		sign = "-"
+		v = new(big.Int).Neg(v)
+	}
+	whole, frac := new(big.Int).QuoRem(v, yoctoPerNear, new(big.Int))
+	fracStr := strings.TrimRight(fmt.Sprintf("%024s", frac.String()), "0")
+	if fracStr == "" {
+		return sign + whole.String()
+	}
+	return sign + whole.String() + "." + fracStr
+}
+
+// String formats the amount as "1,234.5678 NEAR", truncated to 4 decimals.
+func (a Amount) String() string {
+	v := a.int()
+	sign := ""
+	if v.Sign() < 0 {
+		sign = "-"
+		v = new(big.Int).Neg(v)
+	}
+	whole, frac := new(big.Int).QuoRem(v, yoctoPerNear, new(big.Int))
+	fracStr := fmt.Sprintf("%024s", frac.String())[:4]
+	return fmt.Sprintf("%s%s.%s NEAR", sign, groupThousands(whole.String()), fracStr)
+}
+
+func groupThousands(s string) string {
+	var b strings.Builder
+	for i, c := range s {
+		if i > 0 && (len(s)-i)%3 == 0 {
+			b.WriteByte(',')
+		}
+		b.WriteRune(c)
+	}
+	return b.String()
+}
+
+func MinAmount(a, b Amount) Amount {
+	if a.Cmp(b) <= 0 {
+		return a
+	}
+	return b
+}
+
+func MaxAmount(a, b Amount) Amount {
+	if a.Cmp(b) >= 0 {
+		return a
+	}
+	return b
+}
+
+// MarshalJSON encodes the amount as a yoctoNEAR string, like the RPC does.
+func (a Amount) MarshalJSON() ([]byte, error) {
+	return json.Marshal(a.Yocto())
+}
+
+func (a *Amount) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	v, err := ParseAmount(s)
+	if err != nil {
+		return err
+	}
+	*a = v
+	return nil
+}
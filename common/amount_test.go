@@ -0,0 +1,153 @@
+package common
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestParseAmount(t *testing.T) {
+	tests := []struct {
+		in    string
+		yocto string
+		err   bool
+	}{
+		{in: "0", yocto: "0"},
+		{in: "1", yocto: "1"},
+		{in: " 1000000000000000000000000 ", yocto: "1000000000000000000000000"},
+		{in: "123456789012345678901234567890", yocto: "123456789012345678901234567890"},
+		{in: "", err: true},
+		{in: "-1", err: true},
+		{in: "1.5", err: true},
+		{in: "1,000", err: true},
+		{in: "abc", err: true},
+	}
+	for _, tt := range tests {
+		a, err := ParseAmount(tt.in)
+		if (err != nil) != tt.err {
+			t.Errorf("ParseAmount(%q) error = %v, want error %v", tt.in, err, tt.err)
+			continue
+		}
+		if err == nil && a.Yocto() != tt.yocto {
+			t.Errorf("ParseAmount(%q) = %s, want %s", tt.in, a.Yocto(), tt.yocto)
+		}
+	}
+}
+
+func TestParseNear(t *testing.T) {
+	tests := []struct {
+		in    string
+		yocto string
+		err   bool
+	}{
+		{in: "0", yocto: "0"},
+		{in: "1", yocto: "1000000000000000000000000"},
+		{in: "1.5", yocto: "1500000000000000000000000"},
+		{in: ".5", yocto: "500000000000000000000000"},
+		{in: "5.", yocto: "5000000000000000000000000"},
+		{in: "1,234.5678 NEAR", yocto: "1234567800000000000000000000"},
+		{in: "1,000,000", yocto: "1000000000000000000000000000000"},
+		// One yoctoNEAR, the smallest amount
+		{in: "0.000000000000000000000001", yocto: "1"},
+		{in: "0.0000000000000000000000001", err: true},
+		{in: "-1", err: true},
+		{in: "-0.5", err: true},
+		{in: "", err: true},
+		{in: ".", err: true},
+		{in: "1.2.3", err: true},
+		{in: "1e3", err: true},
+		{in: "NEAR", err: true},
+	}
+	for _, tt := range tests {
+		a, err := ParseNear(tt.in)
+		if (err != nil) != tt.err {
+			t.Errorf("ParseNear(%q) error = %v, want error %v", tt.in, err, tt.err)
+			continue
+		}
+		if err == nil && a.Yocto() != tt.yocto {
+			t.Errorf("ParseNear(%q) = %s, want %s", tt.in, a.Yocto(), tt.yocto)
+		}
+	}
+}
+
+func TestAmountFormat(t *testing.T) {
+	tests := []struct {
+		yocto      string
+		str        string
+		nearString string
+	}{
+		{"0", "0.0000 NEAR", "0"},
+		{"1", "0.0000 NEAR", "0.000000000000000000000001"},
+		{"1500000000000000000000000", "1.5000 NEAR", "1.5"},
+		{"1234567890000000000000000000", "1,234.5678 NEAR", "1234.56789"},
+		{"1000000000000000000000000000000", "1,000,000.0000 NEAR", "1000000"},
+	}
+	for _, tt := range tests {
+		a, err := ParseAmount(tt.yocto)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := a.String(); got != tt.str {
+			t.Errorf("String(%s) = %q, want %q", tt.yocto, got, tt.str)
+		}
+		if got := a.NearString(); got != tt.nearString {
+			t.Errorf("NearString(%s) = %q, want %q", tt.yocto, got, tt.nearString)
+		}
+		// NearString is exact, so it parses back to the same amount
+		back, err := ParseNear(a.NearString())
+		if err != nil || back.Cmp(a) != 0 {
+			t.Errorf("ParseNear(NearString(%s)) = %s, %v", tt.yocto, back.Yocto(), err)
+		}
+	}
+	negative := NearAmount(1).Sub(NearAmount(3).Div(2))
+	if got := negative.String(); got != "-0.5000 NEAR" {
+		t.Errorf("String of -0.5 NEAR = %q", got)
+	}
+	if got := negative.NearString(); got != "-0.5" {
+		t.Errorf("NearString of -0.5 NEAR = %q", got)
+	}
+}
+
+func TestAmountJSON(t *testing.T) {
+	var zero Amount
+	data, err := json.Marshal(zero)
+	if err != nil || string(data) != `"0"` {
+		t.Errorf("zero value marshals to %s, %v", data, err)
+	}
+	in := `{"amount":"123456789012345678901234567890"}`
+	var v struct {
+		Amount Amount `json:"amount"`
+	}
+	if err := json.Unmarshal([]byte(in), &v); err != nil {
+		t.Fatal(err)
+	}
+	out, _ := json.Marshal(v)
+	if string(out) != in {
+		t.Errorf("round trip = %s, want %s", out, in)
+	}
+	for _, bad := range []string{`{"amount":1}`, `{"amount":"-1"}`, `{"amount":"1.5"}`} {
+		if err := json.Unmarshal([]byte(bad), &v); err == nil {
+			t.Errorf("Unmarshal(%s) should fail", bad)
+		}
+	}
+}
+
+func TestAmountArithmetic(t *testing.T) {
+	a := NearAmount(10)
+	if got := a.MulFrac(105, 100).NearString(); got != "10.5" {
+		t.Errorf("MulFrac = %s", got)
+	}
+	if got := NearAmount(1).Ratio(NearAmount(4)); got != 0.25 {
+		t.Errorf("Ratio = %f", got)
+	}
+	if got := a.Ratio(Amount{}); got != 0 {
+		t.Errorf("Ratio by zero = %f", got)
+	}
+	if MinAmount(a, NearAmount(3)).Cmp(NearAmount(3)) != 0 || MaxAmount(a, NearAmount(3)).Cmp(a) != 0 {
+		t.Error("MinAmount or MaxAmount is wrong")
+	}
+	// Amounts are immutable
+	b := a.Add(NearAmount(1))
+	if a.Cmp(NearAmount(10)) != 0 || b.Cmp(NearAmount(11)) != 0 {
+		t.Errorf("Add changed its receiver: a = %s, b = %s", a, b)
+	}
+}
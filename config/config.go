@@ -0,0 +1,360 @@
+package config
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/rozum-dev/near-go-warchest/common"
+	"github.com/rozum-dev/near-go-warchest/rpc/signer"
+	"github.com/rozum-dev/near-go-warchest/services/api"
+	"github.com/rozum-dev/near-go-warchest/services/chatops"
+	"github.com/rozum-dev/near-go-warchest/services/notifier"
+	"github.com/rozum-dev/near-go-warchest/strategy"
+	"gopkg.in/yaml.v2"
+)
+
+// Config describes one pool with the top-level fields, or several pools
+// with Pools. The top-level network, rpc, credentials, backend,
+// unlock_policy, reserve, dry_run and strategy are the defaults for every
+// pool.
+type Config struct {
+	Network     string             `yaml:"network"`
+	RPC         []string           `yaml:"rpc"`
+	Networks    map[string]Network `yaml:"networks"`
+	Pool        common.AccountID   `yaml:"pool"`
+	Delegators  []common.AccountID `yaml:"delegators"`
+	Pools       []Pool             `yaml:"pools"`
+	Credentials string             `yaml:"credentials"`
+	Backend     string             `yaml:"backend"`
+	// withdraw, restake or none
+	UnlockPolicy string `yaml:"unlock_policy"`
+	// NEAR kept on every delegator account for gas and storage
+	Reserve     string            `yaml:"reserve"`
+	RepeatTime  int               `yaml:"repeat_time"`
+	DryRun      bool              `yaml:"dry_run"`
+	DecisionLog string            `yaml:"decision_log"`
+	StateFile   string            `yaml:"state_file"`
+	Strategy    Strategy          `yaml:"strategy"`
+	Metrics     Metrics           `yaml:"metrics"`
+	Notifiers   []notifier.Config `yaml:"notifiers"`
+	ChatOps     []chatops.Config  `yaml:"chatops"`
+	API         api.Config        `yaml:"api"`
+}
+
+type Network struct {
+	RPC []string `yaml:"rpc"`
+}
+
+// Pool is a staking pool supervised by the warchest. Empty fields are taken
+// from the top level of the config.
+type Pool struct {
+	Id           common.AccountID   `yaml:"id"`
+	Network      string             `yaml:"network"`
+	Delegators   []common.AccountID `yaml:"delegators"`
+	Credentials  string             `yaml:"credentials"`
+	Backend      string             `yaml:"backend"`
+	UnlockPolicy string             `yaml:"unlock_policy"`
+	Reserve      string             `yaml:"reserve"`
+	DryRun       *bool              `yaml:"dry_run"`
+	Strategy     *Strategy          `yaml:"strategy"`
+}
+
+// Strategy configures the staking strategy. The numbers are pointers so that
+// a pool can override the top-level value with zero.
+type Strategy struct {
+	Name string `yaml:"name"`
+	// Act only when fewer blocks than this are left in the epoch
+	Window *int `yaml:"window"`
+	// Unstake when the expected seats exceed the target by this factor
+	SeatCeiling *float64 `yaml:"seat_ceiling"`
+	// NEAR to keep above the target stake
+	Offset        string   `yaml:"offset"`
+	Seats         *int64   `yaml:"seats"`
+	BufferPercent *float64 `yaml:"buffer_percent"`
+}
+
+type Metrics struct {
+	Addr string `yaml:"addr"`
+}
+
+// Default returns the configuration used when no file is given.
+func Default() *Config {
+	p := strategy.DefaultParams()
+	return &Config{
+		Network:      "betanet",
+		RPC:          []string{"https://rpc.betanet.near.org"},
+		Credentials:  signer.DefaultCredentialsDir(),
+		Backend:      "native",
+		UnlockPolicy: strategy.KeepUnlocked,
+		Reserve:      "5",
+		RepeatTime:   120,
+		StateFile:    "warchest-state.json",
+		Strategy: Strategy{
+			Name:          strategy.OneSeatName,
+			Window:        &p.Window,
+			SeatCeiling:   &p.SeatCeiling,
+			Offset:        "100",
+			Seats:         &p.Seats,
+			BufferPercent: &p.BufferPercent,
+		},
+		Metrics: Metrics{
+			Addr: ":9444",
+		},
+	}
+}
+
+// Load reads a YAML config file on top of the defaults.
+func Load(path string) (*Config, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	c := Default()
+	if err := yaml.UnmarshalStrict(data, c); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return c, nil
+}
+
+// ApplyEnv overrides the config with the environment variables that are set.
+func (c *Config) ApplyEnv() error {
+	if v := os.Getenv("REPEAT_TIME"); v != "" {
+		t, err := strconv.Atoi(strings.TrimSpace(v))
+		if err != nil {
+			return fmt.Errorf("REPEAT_TIME: %v", err)
+		}
+		c.RepeatTime = t
+	}
+	if v := os.Getenv("NEAR_ENV"); v != "" {
+		c.Network = v
+	}
+	return nil
+}
+
+// Params converts the strategy section to strategy parameters. Fields that
+// are not set keep the default parameters.
+func (s *Strategy) Params() (strategy.Params, error) {
+	p := strategy.DefaultParams()
+	if s.Window != nil {
+		p.Window = *s.Window
+	}
+	if s.SeatCeiling != nil {
+		p.SeatCeiling = *s.SeatCeiling
+	}
+	if s.Seats != nil {
+		p.Seats = *s.Seats
+	}
+	if s.BufferPercent != nil {
+		p.BufferPercent = *s.BufferPercent
+	}
+	offset, err := common.ParseNear(s.Offset)
+	if err != nil {
+		return p, err
+	}
+	p.Offset = offset
+	return p, nil
+}
+
+// merge fills the fields that are not set in s from base.
+func (s Strategy) merge(base Strategy) Strategy {
+	if s.Name == "" {
+		s.Name = base.Name
+	}
+	if s.Window == nil {
+		s.Window = base.Window
+	}
+	if s.SeatCeiling == nil {
+		s.SeatCeiling = base.SeatCeiling
+	}
+	if s.Offset == "" {
+		s.Offset = base.Offset
+	}
+	if s.Seats == nil {
+		s.Seats = base.Seats
+	}
+	if s.BufferPercent == nil {
+		s.BufferPercent = base.BufferPercent
+	}
+	return s
+}
+
+// GetPools returns every pool with the top-level defaults filled in.
+func (c *Config) GetPools() []Pool {
+	pools := c.Pools
+	if len(pools) == 0 && c.Pool != "" {
+		pools = []Pool{{Id: c.Pool, Delegators: c.Delegators}}
+	}
+	out := make([]Pool, len(pools))
+	for i, p := range pools {
+		if p.Network == "" {
+			p.Network = c.Network
+		}
+		if p.Credentials == "" {
+			p.Credentials = c.Credentials
+		}
+		if p.Backend == "" {
+			p.Backend = c.Backend
+		}
+		if p.UnlockPolicy == "" {
+			p.UnlockPolicy = c.UnlockPolicy
+		}
+		if p.Reserve == "" {
+			p.Reserve = c.Reserve
+		}
+		if p.DryRun == nil {
+			dryRun := c.DryRun
+			p.DryRun = &dryRun
+		}
+		st := c.Strategy
+		if p.Strategy != nil {
+			st = p.Strategy.merge(c.Strategy)
+		}
+		p.Strategy = &st
+		out[i] = p
+	}
+	return out
+}
+
+// Endpoints returns the RPC endpoints of a network.
+func (c *Config) Endpoints(network string) []string {
+	if n, ok := c.Networks[network]; ok && len(n.RPC) > 0 {
+		return n.RPC
+	}
+	if network == c.Network {
+		return c.RPC
+	}
+	return nil
+}
+
+// ValidationError lists every problem found in the config.
+type ValidationError []string
+
+func (e ValidationError) Error() string {
+	return "invalid config:\n  " + strings.Join(e, "\n  ")
+}
+
+// Validate checks the config and reports all problems at once.
+func (c *Config) Validate() error {
+	var errs ValidationError
+	add := func(format string, a ...interface{}) {
+		errs = append(errs, fmt.Sprintf(format, a...))
+	}
+	if c.Pool != "" && len(c.Pools) > 0 {
+		add("pool: cannot be combined with pools")
+	}
+	pools := c.GetPools()
+	if len(pools) == 0 {
+		add("pool: must be set")
+	}
+	seen := make(map[common.AccountID]bool)
+	for i, p := range pools {
+		name := "pool"
+		if len(c.Pools) > 0 {
+			name = fmt.Sprintf("pools[%d]", i)
+		}
+		if p.Id == "" {
+			add("%s.id: must be set", name)
+		} else if err := p.Id.Validate(); err != nil {
+			add("%s.id: %v", name, err)
+		} else if p.Id.IsImplicit() {
+			add("%s.id: %q is an implicit account, which cannot be a staking pool", name, p.Id)
+		} else if p.Id.IsTopLevel() {
+			// The pool factory creates every pool as its sub-account
+			add("%s.id: %q is a top-level account, staking pools are like name.poolv1.near", name, p.Id)
+		} else if seen[p.Id] {
+			add("%s.id: duplicate pool %q", name, p.Id)
+		}
+		seen[p.Id] = true
+		if p.Network == "" {
+			add("%s.network: must be set", name)
+		} else if len(c.Endpoints(p.Network)) == 0 {
+			add("%s.network: no rpc endpoints for network %q", name, p.Network)
+		}
+		if len(p.Delegators) == 0 {
+			add("%s.delegators: at least one delegator is required", name)
+		}
+		for j, d := range p.Delegators {
+			if err := d.Validate(); err != nil {
+				add("%s.delegators[%d]: %v", name, j, err)
+			}
+		}
+		if p.Backend != "native" && p.Backend != "near-shell" {
+			add("%s.backend: must be native or near-shell, got %q", name, p.Backend)
+		}
+		switch p.UnlockPolicy {
+		case strategy.WithdrawUnlocked, strategy.RestakeUnlocked, strategy.KeepUnlocked:
+		default:
+			add("%s.unlock_policy: must be withdraw, restake or none, got %q", name, p.UnlockPolicy)
+		}
+		if _, err := common.ParseNear(p.Reserve); err != nil {
+			add("%s.reserve: %v", name, err)
+		}
+		st := p.Strategy
+		params, err := st.Params()
+		if err != nil {
+			add("%s.strategy.offset: %v", name, err)
+		}
+		if params.Window <= 0 {
+			add("%s.strategy.window: must be a positive number of blocks, got %d", name, params.Window)
+		}
+		if params.SeatCeiling < 1 {
+			add("%s.strategy.seat_ceiling: must be at least 1, got %f", name, params.SeatCeiling)
+		}
+		if err == nil {
+			if _, err := strategy.New(st.Name, params); err != nil {
+				add("%s.strategy: %v", name, err)
+			}
+		}
+	}
+	checkEndpoints := func(name string, endpoints []string) {
+		for i, u := range endpoints {
+			if !strings.HasPrefix(u, "http://") && !strings.HasPrefix(u, "https://") {
+				add("%s[%d]: %q is not an http(s) URL", name, i, u)
+			}
+		}
+	}
+	checkEndpoints("rpc", c.RPC)
+	for n, network := range c.Networks {
+		checkEndpoints(fmt.Sprintf("networks.%s.rpc", n), network.RPC)
+	}
+	if c.RepeatTime <= 0 {
+		add("repeat_time: must be a positive number of seconds, got %d", c.RepeatTime)
+	}
+	if c.StateFile == "" {
+		add("state_file: must be set")
+	}
+	if c.Metrics.Addr == "" {
+		add("metrics.addr: must be set")
+	}
+	for i, n := range c.Notifiers {
+		if _, err := notifier.New(n); err != nil {
+			add("notifiers[%d]: %v", i, err)
+		}
+	}
+	if c.API.Addr != "" {
+		if c.API.Token == "" {
+			add("api.token: must be set")
+		}
+		if (c.API.CertFile == "") != (c.API.KeyFile == "") {
+			add("api: cert_file and key_file must be set together")
+		}
+		if c.API.Addr == c.Metrics.Addr {
+			add("api.addr: must differ from metrics.addr")
+		}
+	}
+	for i, b := range c.ChatOps {
+		if _, err := chatops.New(b); err != nil {
+			add("chatops[%d]: %v", i, err)
+		}
+		if len(b.AllowedUsers) == 0 {
+			add("chatops[%d].allowed_users: at least one user is required", i)
+		}
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
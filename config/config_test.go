@@ -0,0 +1,97 @@
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/rozum-dev/near-go-warchest/common"
+)
+
+func TestValidatePoolId(t *testing.T) {
+	tests := []struct {
+		id  common.AccountID
+		err string
+	}{
+		{"my_pool.poolv1.near", ""},
+		{"98793cd91a3f870fb126f66285808c7e094afcfc4eda8a970f6648cdf0dbd6de", "implicit account"},
+		{"mypool", "top-level account"},
+		{"My_pool.poolv1.near", "must be lowercase"},
+	}
+	for _, tt := range tests {
+		c := Default()
+		c.Pool = tt.id
+		c.Delegators = []common.AccountID{"owner.near"}
+		err := c.Validate()
+		switch {
+		case tt.err == "" && err != nil:
+			t.Errorf("%s: %v", tt.id, err)
+		case tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)):
+			t.Errorf("%s: error %v, want %q", tt.id, err, tt.err)
+		}
+	}
+}
+
+func TestPoolStrategyOverridesWithZero(t *testing.T) {
+	c := Default()
+	c.Pools = []Pool{{Id: "a.poolv1.near"}, {Id: "b.poolv1.near"}}
+	zero := 0.0
+	c.Pools[1].Strategy = &Strategy{Name: "buffer", BufferPercent: &zero}
+	pools := c.GetPools()
+
+	a, err := pools[0].Strategy.Params()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a.BufferPercent != 5 {
+		t.Errorf("a: buffer %f, want the top-level 5", a.BufferPercent)
+	}
+	b, err := pools[1].Strategy.Params()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pools[1].Strategy.Name != "buffer" || b.BufferPercent != 0 || b.Window != a.Window {
+		t.Errorf("b: %s with buffer %f and window %d, want buffer with 0 and window %d", pools[1].Strategy.Name, b.BufferPercent, b.Window, a.Window)
+	}
+}
+
+func TestLoadPoolStrategy(t *testing.T) {
+	f, err := ioutil.TempFile("", "warchest-*.yml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	f.WriteString(`
+network: betanet
+delegators: [owner.near]
+strategy:
+  name: target-seats
+  seats: 3
+pools:
+  - id: a.poolv1.near
+  - id: b.poolv1.near
+    strategy:
+      seats: 0
+      window: 50
+`)
+	f.Close()
+	c, err := Load(f.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	pools := c.GetPools()
+	tests := []struct {
+		seats  int64
+		window int
+	}{
+		{3, 1000},
+		{0, 50},
+	}
+	for i, tt := range tests {
+		st := pools[i].Strategy
+		if st.Name != "target-seats" || *st.Seats != tt.seats || *st.Window != tt.window {
+			t.Errorf("%s: %s with %d seats and window %d, want %d seats and window %d", pools[i].Id, st.Name, *st.Seats, *st.Window, tt.seats, tt.window)
+		}
+	}
+}
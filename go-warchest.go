@@ -4,22 +4,37 @@ import (
 	"context"
 	"flag"
 	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/rozum-dev/near-go-warchest/common"
+	"github.com/rozum-dev/near-go-warchest/config"
 	"github.com/rozum-dev/near-go-warchest/near-shell/runner"
 	"github.com/rozum-dev/near-go-warchest/rpc"
 	nearapi "github.com/rozum-dev/near-go-warchest/rpc/client"
+	"github.com/rozum-dev/near-go-warchest/rpc/signer"
+	"github.com/rozum-dev/near-go-warchest/services/api"
+	"github.com/rozum-dev/near-go-warchest/services/chatops"
+	"github.com/rozum-dev/near-go-warchest/services/notifier"
 	prom "github.com/rozum-dev/near-go-warchest/services/prometheus"
+	"github.com/rozum-dev/near-go-warchest/store"
+	"github.com/rozum-dev/near-go-warchest/strategy"
 )
 
-type arrayFlags []string
+type arrayFlags []common.AccountID
 
 func (i *arrayFlags) String() string {
 	return "delegator ids"
 }
 
 func (i *arrayFlags) Set(value string) error {
-	*i = append(*i, value)
+	id, err := common.ParseAccountID(value)
+	if err != nil {
+		return err
+	}
+	*i = append(*i, id)
 	return nil
 }
 
@@ -30,31 +45,186 @@ func main() {
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
-	url := flag.String("url", "https://rpc.betanet.near.org", "Near JSON-RPC URL")
-	addr := flag.String("addr", ":9444", "listen address")
-	poolId := flag.String("accountId", "test", "Validator pool account id")
+	def := config.Default()
+	configPath := flag.String("config", "", "Path to a YAML config file")
+	url := flag.String("url", def.RPC[0], "Near JSON-RPC URL")
+	addr := flag.String("addr", def.Metrics.Addr, "listen address")
+	var poolId common.AccountID
+	flag.Var(&poolId, "accountId", "Validator pool account id")
 	flag.Var(&delegatorIds, "delegatorId", "Delegator ids.")
+	network := flag.String("network", def.Network, "Network id used to look up keys in the credentials directory")
+	credentialsDir := flag.String("credentials", def.Credentials, "Near credentials directory")
+	backend := flag.String("backend", def.Backend, "Transaction backend: native or near-shell")
+	strategyName := flag.String("strategy", def.Strategy.Name, "Staking strategy: one-seat, target-seats, buffer or stake-all")
+	targetSeats := flag.Int64("seats", *def.Strategy.Seats, "Number of seats for the target-seats strategy")
+	bufferPercent := flag.Float64("buffer", *def.Strategy.BufferPercent, "Percentage above the expected seat price for the buffer strategy")
+	reserve := flag.String("reserve", def.Reserve, "NEAR kept on every delegator account for gas above its storage, the rest can be deposited and staked")
+	dryRun := flag.Bool("dry-run", def.DryRun, "Decide and record pings and restakes without sending transactions")
+	decisionLogPath := flag.String("decision-log", def.DecisionLog, "Append every decision as a JSON line to this file")
+	stateFile := flag.String("state", def.StateFile, "File that keeps the runner state across restarts")
 
 	flag.Parse()
 	if len(flag.Args()) > 0 {
 		flag.Usage()
 	}
 
-	client := nearapi.NewClientWithContext(ctx, *url)
+	cfg := def
+	if *configPath != "" {
+		var err error
+		cfg, err = config.Load(*configPath)
+		if err != nil {
+			log.Fatalln(err)
+		}
+	}
+	// Environment variables and flags override the config file
+	if err := cfg.ApplyEnv(); err != nil {
+		log.Fatalln(err)
+	}
+	flag.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "url":
+			cfg.RPC = []string{*url}
+		case "addr":
+			cfg.Metrics.Addr = *addr
+		case "accountId":
+			cfg.Pool = poolId
+		case "delegatorId":
+			cfg.Delegators = delegatorIds
+		case "network":
+			cfg.Network = *network
+		case "credentials":
+			cfg.Credentials = *credentialsDir
+		case "backend":
+			cfg.Backend = *backend
+		case "strategy":
+			cfg.Strategy.Name = *strategyName
+		case "seats":
+			cfg.Strategy.Seats = targetSeats
+		case "buffer":
+			cfg.Strategy.BufferPercent = bufferPercent
+		case "reserve":
+			cfg.Reserve = *reserve
+		case "dry-run":
+			cfg.DryRun = *dryRun
+		case "decision-log":
+			cfg.DecisionLog = *decisionLogPath
+		case "state":
+			cfg.StateFile = *stateFile
+		}
+	})
+	if err := cfg.Validate(); err != nil {
+		log.Fatalln(err)
+	}
 
 	// Prometheus metrics
 	promMetrics := prom.NewPromMetrics()
 	// Run a metrics service
-	go promMetrics.RunMetricsService(*addr)
-
-	rpcMonitor := rpc.NewMonitor(client, *poolId)
-	resCh := make(chan *rpc.SubscrResult)
-	// Quota for a concurrent rpc requests
-	sem := make(common.Sem, 1)
-	// Run a remote rpc monitor
-	go rpcMonitor.Run(ctx, resCh, sem, promMetrics)
-
-	// Run a near-shell runner
-	runner := runner.NewRunner(*poolId, delegatorIds)
-	runner.Run(ctx, resCh, promMetrics, sem)
+	go promMetrics.RunMetricsService(cfg.Metrics.Addr)
+
+	decisions, err := runner.NewDecisionLog(1000, cfg.DecisionLog)
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	st, err := store.Open(cfg.StateFile)
+	if err != nil {
+		log.Fatalf("Failed to open state file %s: %s\n", cfg.StateFile, err)
+	}
+
+	var notifiers notifier.Multi
+	for _, c := range cfg.Notifiers {
+		n, err := notifier.New(c)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		notifiers = append(notifiers, n)
+	}
+	alerts := notifier.NewAsync(ctx, notifiers, 100)
+
+	// One rpc client per network
+	clients := make(map[string]*nearapi.Client)
+	var runners []*runner.Runner
+	for _, pool := range cfg.GetPools() {
+		client, ok := clients[pool.Network]
+		if !ok {
+			client = nearapi.NewClientWithContext(ctx, cfg.Endpoints(pool.Network)...)
+			clients[pool.Network] = client
+		}
+
+		var executor runner.Executor
+		switch pool.Backend {
+		case "native":
+			executor = runner.NewNativeExecutor(signer.NewSigner(client, pool.Credentials, pool.Network), st)
+		case "near-shell":
+			executor = runner.NewShellExecutor(pool.Network, pool.Credentials)
+		}
+
+		params, err := pool.Strategy.Params()
+		if err != nil {
+			log.Fatalln(err)
+		}
+		params.UnlockPolicy = pool.UnlockPolicy
+		s, err := strategy.New(pool.Strategy.Name, params)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		reserve, err := common.ParseNear(pool.Reserve)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		log.Printf("%s: using %s strategy on %s\n", pool.Id, s.Name(), pool.Network)
+		if *pool.DryRun {
+			log.Printf("%s: dry run, no transactions will be sent\n", pool.Id)
+		}
+
+		var delegators []string
+		for _, d := range pool.Delegators {
+			delegators = append(delegators, d.String())
+		}
+		poolMetrics := promMetrics.Pool(pool.Id.String())
+		rpcMonitor := rpc.NewMonitor(client, pool.Id.String(), time.Duration(cfg.RepeatTime)*time.Second, alerts, st)
+		resCh := make(chan *rpc.SubscrResult)
+		// The monitor and the runner of a pool take turns, pools do not wait
+		// for each other
+		sem := make(common.Sem, 1)
+		// Run a remote rpc monitor
+		go rpcMonitor.Run(ctx, resCh, sem, poolMetrics)
+
+		// Run a near-shell runner
+		r := runner.NewRunner(client, pool.Id.String(), delegators, executor, s, runner.Options{
+			DryRun:    *pool.DryRun,
+			Decisions: decisions,
+			Store:     st,
+			Notifier:  alerts,
+			Reserve:   reserve,
+		})
+		go r.Run(ctx, resCh, poolMetrics, sem)
+		runners = append(runners, r)
+	}
+
+	if cfg.API.Addr != "" {
+		var controllers []api.Controller
+		for _, r := range runners {
+			controllers = append(controllers, r)
+		}
+		go api.Run(ctx, cfg.API, api.NewServer(cfg.API.Token, controllers, decisions, st))
+	}
+
+	for _, c := range cfg.ChatOps {
+		t, err := chatops.New(c)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		log.Printf("Starting %s chat-ops bot\n", c.Type)
+		var controllers []chatops.Controller
+		for _, r := range runners {
+			controllers = append(controllers, r)
+		}
+		go chatops.NewBot(t, c.AllowedUsers, controllers).Run(ctx)
+	}
+
+	sigc := make(chan os.Signal, 1)
+	signal.Notify(sigc, syscall.SIGINT, syscall.SIGTERM)
+	<-sigc
+	log.Println("System kill")
 }
@@ -1,5 +1,8 @@
 module github.com/rozum-dev/near-go-warchest
 
-go 1.13
+go 1.14
 
-require github.com/prometheus/client_golang v1.7.0
+require (
+	github.com/prometheus/client_golang v1.7.0
+	gopkg.in/yaml.v2 v2.4.0
+)
@@ -1,28 +1,102 @@
 package helpers
 
 import (
+	"bytes"
 	"context"
 	"errors"
-	"fmt"
 	"os/exec"
+	"strings"
 	"time"
 )
 
-func Run(ctx context.Context, cmd string) (string, error) {
-	c, cancel := context.WithTimeout(ctx, 15*time.Second)
-	defer cancel()
+// Used for commands of a kind without a timeout of its own
+const DefaultTimeout = 15 * time.Second
+
+// Command is a program and its arguments, run without a shell. Kind groups
+// commands for timeouts, such as "ping" or "stake".
+type Command struct {
+	Kind string
+	Name string
+	Args []string
+}
+
+func (c Command) String() string {
+	parts := []string{c.Name}
+	for _, a := range c.Args {
+		if a == "" || strings.ContainsAny(a, " '\"{}") {
+			a = "'" + strings.Replace(a, "'", `'\''`, -1) + "'"
+		}
+		parts = append(parts, a)
+	}
+	return strings.Join(parts, " ")
+}
 
-	 fmt.Printf("Try to run command %s\n", cmd)
-	out, err := exec.CommandContext(c, "bash", "-c", cmd).Output()
+// Result is what a finished command left behind. ExitCode is -1 when the
+// command did not exit by itself.
+type Result struct {
+	Stdout   string
+	Stderr   string
+	ExitCode int
+	Duration time.Duration
+}
 
+// CommandRunner runs commands. A command that fails returns a *CmdError
+// together with its result.
+type CommandRunner interface {
+	Run(ctx context.Context, c Command) (*Result, error)
+}
+
+// ExecRunner runs commands as child processes.
+type ExecRunner struct {
+	// Timeouts by command kind
+	Timeouts map[string]time.Duration
+}
 
-	if c.Err() == context.DeadlineExceeded {
-		fmt.Printf("Command %s timed out\n", cmd)
-		return "", context.DeadlineExceeded
+func NewExecRunner(timeouts map[string]time.Duration) *ExecRunner {
+	return &ExecRunner{Timeouts: timeouts}
+}
+
+func (r *ExecRunner) timeout(kind string) time.Duration {
+	if t, ok := r.Timeouts[kind]; ok && t > 0 {
+		return t
 	}
+	return DefaultTimeout
+}
+
+func (r *ExecRunner) Run(ctx context.Context, c Command) (*Result, error) {
+	timeout := r.timeout(c.Kind)
+	cctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
 
-	if err != nil {
-		return "", errors.New(fmt.Sprintf("Failed to execute command: %s", cmd))
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(cctx, c.Name, c.Args...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	start := time.Now()
+	err := cmd.Run()
+	res := &Result{
+		Stdout:   stdout.String(),
+		Stderr:   stderr.String(),
+		Duration: time.Since(start),
+	}
+	if cmd.ProcessState != nil {
+		res.ExitCode = cmd.ProcessState.ExitCode()
+	} else {
+		res.ExitCode = -1
+	}
+
+	switch {
+	case err == nil:
+		return res, nil
+	case ctx.Err() != nil:
+		return res, &CmdError{Command: c, Result: res, Kind: ErrCanceled, Err: ctx.Err()}
+	case cctx.Err() == context.DeadlineExceeded:
+		return res, &CmdError{Command: c, Result: res, Kind: ErrTimeout, Err: context.DeadlineExceeded, Timeout: timeout}
+	}
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) {
+		// The program could not be started
+		return res, &CmdError{Command: c, Result: res, Kind: ErrNotStarted, Err: err}
 	}
-	return string(out), nil
+	return res, NewCmdError(c, res)
 }
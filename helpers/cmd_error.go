@@ -0,0 +1,118 @@
+package helpers
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// ErrorKind is why a command failed.
+type ErrorKind string
+
+const (
+	ErrUnknown    ErrorKind = "unknown"
+	ErrTimeout    ErrorKind = "timeout"
+	ErrCanceled   ErrorKind = "canceled"
+	ErrNotStarted ErrorKind = "not_started"
+	// near-shell failures
+	ErrKeyNotFound        ErrorKind = "key_not_found"
+	ErrNotEnoughBalance   ErrorKind = "not_enough_balance"
+	ErrContractPanic      ErrorKind = "contract_panic"
+	ErrAccountNotFound    ErrorKind = "account_not_found"
+	ErrInvalidTransaction ErrorKind = "invalid_transaction"
+	ErrNetwork            ErrorKind = "network"
+)
+
+// Longest message kept from the output
+const maxMessageLength = 300
+
+// CmdError is a failed command. Message is the relevant part of the output,
+// such as the panic message of a contract.
+type CmdError struct {
+	Command Command
+	Result  *Result
+	Kind    ErrorKind
+	Message string
+	// Set for ErrTimeout
+	Timeout time.Duration
+	Err     error
+}
+
+func (e *CmdError) Error() string {
+	msg := fmt.Sprintf("%s: %s", e.Command, e.Kind)
+	switch {
+	case e.Kind == ErrTimeout:
+		msg += fmt.Sprintf(" after %s", e.Timeout)
+	case e.Result != nil && e.Result.ExitCode > 0:
+		msg += fmt.Sprintf(" (exit code %d)", e.Result.ExitCode)
+	}
+	if e.Message != "" {
+		return msg + ": " + e.Message
+	}
+	if e.Err != nil {
+		return fmt.Sprintf("%s: %v", msg, e.Err)
+	}
+	return msg
+}
+
+func (e *CmdError) Unwrap() error {
+	return e.Err
+}
+
+// IsKind reports whether err is a failed command of the given kind.
+func IsKind(err error, kind ErrorKind) bool {
+	var e *CmdError
+	return errors.As(err, &e) && e.Kind == kind
+}
+
+var panicMessage = regexp.MustCompile(`Smart contract panicked: ([^"\n\\]*)`)
+
+// Output of near-shell by kind of failure, checked in order. A contract can
+// panic because of a low balance too, so panics come first.
+var failurePatterns = []struct {
+	kind     ErrorKind
+	patterns []string
+}{
+	{ErrContractPanic, []string{"Smart contract panicked", "FunctionCallError", "MethodNotFound"}},
+	{ErrKeyNotFound, []string{"no matching key pair found", "Can not sign transactions", "AccessKeyNotFound", "UNKNOWN_ACCESS_KEY"}},
+	{ErrNotEnoughBalance, []string{"NotEnoughBalance", "LackBalanceForState", "doesn't have enough balance"}},
+	{ErrAccountNotFound, []string{"AccountDoesNotExist", "SignerDoesNotExist", "does not exist while viewing", "UNKNOWN_ACCOUNT"}},
+	{ErrInvalidTransaction, []string{"InvalidNonce", "InvalidTxError", "Expired"}},
+	{ErrNetwork, []string{"FetchError", "ECONNREFUSED", "ECONNRESET", "ETIMEDOUT", "ENOTFOUND", "socket hang up", "503 Service Unavailable"}},
+}
+
+// NewCmdError classifies a command which exited with an error from its output.
+func NewCmdError(c Command, res *Result) *CmdError {
+	e := &CmdError{Command: c, Result: res, Kind: ErrUnknown}
+	output := res.Stderr + "\n" + res.Stdout
+	for _, f := range failurePatterns {
+		for _, p := range f.patterns {
+			if strings.Contains(output, p) {
+				e.Kind = f.kind
+				break
+			}
+		}
+		if e.Kind != ErrUnknown {
+			break
+		}
+	}
+	if m := panicMessage.FindStringSubmatch(output); e.Kind == ErrContractPanic && m != nil {
+		e.Message = strings.TrimSpace(m[1])
+	} else {
+		e.Message = lastLine(res.Stderr)
+		if e.Message == "" {
+			e.Message = lastLine(res.Stdout)
+		}
+	}
+	if len(e.Message) > maxMessageLength {
+		e.Message = e.Message[:maxMessageLength] + "..."
+	}
+	return e
+}
+
+func lastLine(s string) string {
+	lines := strings.Split(strings.TrimSpace(s), "\n")
+	return strings.TrimSpace(lines[len(lines)-1])
+}
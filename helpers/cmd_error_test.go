@@ -0,0 +1,137 @@
+package helpers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNewCmdError(t *testing.T) {
+	c := Command{Kind: "stake", Name: "near", Args: []string{"call", "pool.near", "stake"}}
+	tests := []struct {
+		name     string
+		stdout   string
+		stderr   string
+		kind     ErrorKind
+		message  string
+		exitCode int
+	}{
+		{
+			name:    "contract panic",
+			stderr:  `ServerTransactionError: {"FunctionCallError":{"HostError":{"GuestPanic":{"panic_msg":"..."}}}}` + "\nSmart contract panicked: The staking is paused\n",
+			kind:    ErrContractPanic,
+			message: "The staking is paused",
+		},
+		{
+			name:    "panic about the balance is a panic",
+			stderr:  "Smart contract panicked: Not enough unstaked balance to stake",
+			kind:    ErrContractPanic,
+			message: "Not enough unstaked balance to stake",
+		},
+		{
+			name:    "key not found",
+			stderr:  "Error: Can not sign transactions for account owner.near on network testnet, no matching key pair found in InMemorySigner.",
+			kind:    ErrKeyNotFound,
+			message: "Error: Can not sign transactions for account owner.near on network testnet, no matching key pair found in InMemorySigner.",
+		},
+		{
+			name:   "not enough balance",
+			stderr: "TypedError: Sender owner.near does not have enough balance\n  type: 'NotEnoughBalance'",
+			kind:   ErrNotEnoughBalance,
+		},
+		{
+			name:   "account not found",
+			stderr: "Error: Account pool.near does not exist while viewing",
+			kind:   ErrAccountNotFound,
+		},
+		{
+			name:   "invalid nonce",
+			stderr: "InvalidTxError: InvalidNonce { tx_nonce: 5, ak_nonce: 7 }",
+			kind:   ErrInvalidTransaction,
+		},
+		{
+			name:   "network",
+			stderr: "FetchError: request to https://rpc.testnet.near.org/ failed, reason: connect ECONNREFUSED",
+			kind:   ErrNetwork,
+		},
+		{
+			name:     "unknown uses the last line",
+			stdout:   "Scheduling a call\n",
+			stderr:   "something went wrong\n\n  at the end  \n",
+			kind:     ErrUnknown,
+			message:  "at the end",
+			exitCode: 3,
+		},
+		{
+			name:    "message from stdout without stderr",
+			stdout:  "first\nlast\n",
+			kind:    ErrUnknown,
+			message: "last",
+		},
+	}
+	for _, tt := range tests {
+		exitCode := tt.exitCode
+		if exitCode == 0 {
+			exitCode = 1
+		}
+		e := NewCmdError(c, &Result{Stdout: tt.stdout, Stderr: tt.stderr, ExitCode: exitCode})
+		if e.Kind != tt.kind {
+			t.Errorf("%s: kind %s, want %s", tt.name, e.Kind, tt.kind)
+		}
+		if tt.message != "" && e.Message != tt.message {
+			t.Errorf("%s: message %q, want %q", tt.name, e.Message, tt.message)
+		}
+		if want := fmt.Sprintf("(exit code %d)", exitCode); !strings.Contains(e.Error(), want) {
+			t.Errorf("%s: %q does not contain %q", tt.name, e.Error(), want)
+		}
+		if !IsKind(e, tt.kind) {
+			t.Errorf("%s: IsKind(%s) is false", tt.name, tt.kind)
+		}
+	}
+}
+
+func TestNewCmdErrorTruncatesMessage(t *testing.T) {
+	e := NewCmdError(Command{Name: "near"}, &Result{Stderr: strings.Repeat("x", 1000), ExitCode: 1})
+	if len(e.Message) != maxMessageLength+len("...") {
+		t.Errorf("message of %d bytes, want %d", len(e.Message), maxMessageLength+3)
+	}
+}
+
+func TestExecRunner(t *testing.T) {
+	r := NewExecRunner(map[string]time.Duration{"slow": 50 * time.Millisecond})
+	ctx := context.Background()
+
+	res, err := r.Run(ctx, Command{Kind: "slow", Name: "sleep", Args: []string{"5"}})
+	if !IsKind(err, ErrTimeout) {
+		t.Fatalf("sleep past the timeout: %v, want a timeout", err)
+	}
+	if res.ExitCode != -1 || !strings.Contains(err.Error(), "after 50ms") {
+		t.Errorf("timeout: exit code %d, error %q", res.ExitCode, err)
+	}
+
+	_, err = r.Run(ctx, Command{Name: "warchest-no-such-program"})
+	if !IsKind(err, ErrNotStarted) {
+		t.Errorf("missing program: %v, want not started", err)
+	}
+
+	res, err = r.Run(ctx, Command{Name: "sh", Args: []string{"-c", "echo 'no matching key pair found' >&2; exit 3"}})
+	if !IsKind(err, ErrKeyNotFound) {
+		t.Errorf("failed command: %v, want key not found", err)
+	}
+	if res.ExitCode != 3 || res.Stderr != "no matching key pair found\n" {
+		t.Errorf("failed command: exit code %d, stderr %q", res.ExitCode, res.Stderr)
+	}
+
+	cctx, cancel := context.WithCancel(ctx)
+	cancel()
+	if _, err = r.Run(cctx, Command{Name: "sleep", Args: []string{"5"}}); !IsKind(err, ErrCanceled) {
+		t.Errorf("canceled: %v, want canceled", err)
+	}
+
+	res, err = r.Run(ctx, Command{Name: "echo", Args: []string{"ok"}})
+	if err != nil || res.Stdout != "ok\n" || res.ExitCode != 0 {
+		t.Errorf("echo: %+v, %v", res, err)
+	}
+}
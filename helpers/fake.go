@@ -0,0 +1,85 @@
+package helpers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// FakeStep is the scripted outcome of one command. A step with a non-zero
+// ExitCode fails with the error the output classifies to, unless Err is set.
+type FakeStep struct {
+	// Substring the command line must contain, empty for any
+	Match  string
+	Stdout string
+	Stderr string
+	// Exit code, -1 with Err for a command that did not exit by itself
+	ExitCode int
+	Err      error
+}
+
+// FakeRunner answers commands with scripted steps in order, for tests of
+// code that runs commands. It never starts a process.
+type FakeRunner struct {
+	mu    sync.Mutex
+	steps []FakeStep
+	calls []Command
+}
+
+func NewFakeRunner(steps ...FakeStep) *FakeRunner {
+	return &FakeRunner{steps: steps}
+}
+
+// Add appends steps to the script.
+func (f *FakeRunner) Add(steps ...FakeStep) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.steps = append(f.steps, steps...)
+}
+
+// Calls returns the commands run so far.
+func (f *FakeRunner) Calls() []Command {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	out := make([]Command, len(f.calls))
+	copy(out, f.calls)
+	return out
+}
+
+// Pending returns the number of steps left.
+func (f *FakeRunner) Pending() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.steps)
+}
+
+func (f *FakeRunner) Run(ctx context.Context, c Command) (*Result, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls = append(f.calls, c)
+	if err := ctx.Err(); err != nil {
+		return &Result{ExitCode: -1}, &CmdError{Command: c, Kind: ErrCanceled, Err: err}
+	}
+	if len(f.steps) == 0 {
+		return nil, fmt.Errorf("fake runner: unexpected command %s", c)
+	}
+	step := f.steps[0]
+	if step.Match != "" && !strings.Contains(c.String(), step.Match) {
+		return nil, fmt.Errorf("fake runner: expected a command with %q, got %s", step.Match, c)
+	}
+	f.steps = f.steps[1:]
+	res := &Result{Stdout: step.Stdout, Stderr: step.Stderr, ExitCode: step.ExitCode}
+	switch {
+	case step.Err != nil:
+		if e, ok := step.Err.(*CmdError); ok {
+			ce := *e
+			ce.Command, ce.Result = c, res
+			return res, &ce
+		}
+		return res, &CmdError{Command: c, Result: res, Kind: ErrUnknown, Err: step.Err}
+	case step.ExitCode != 0:
+		return res, NewCmdError(c, res)
+	}
+	return res, nil
+}
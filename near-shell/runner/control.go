@@ -0,0 +1,230 @@
+package runner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/rozum-dev/near-go-warchest/common"
+	"github.com/rozum-dev/near-go-warchest/rpc"
+	prom "github.com/rozum-dev/near-go-warchest/services/prometheus"
+	"github.com/rozum-dev/near-go-warchest/strategy"
+)
+
+// Status is the latest view of the pool the runner acted on.
+type Status struct {
+	PoolId            string                   `json:"pool_id"`
+	Strategy          string                   `json:"strategy"`
+	DryRun            bool                     `json:"dry_run"`
+	Paused            bool                     `json:"paused"`
+	LatestBlockHeight int64                    `json:"latest_block_height"`
+	EpochStartHeight  int64                    `json:"epoch_start_height"`
+	EpochLength       int                      `json:"epoch_length"`
+	LeftBlocks        int                      `json:"left_blocks"`
+	LastPingedEpoch   int64                    `json:"last_pinged_epoch"`
+	CurrentStake      common.Amount            `json:"current_stake"`
+	NextStake         common.Amount            `json:"next_stake"`
+	ExpectedStake     common.Amount            `json:"expected_stake"`
+	ProducedBlocks    int64                    `json:"produced_blocks"`
+	ExpectedBlocks    int64                    `json:"expected_blocks"`
+	Proposed          bool                     `json:"proposed"`
+	KickedOut         bool                     `json:"kicked_out"`
+	CurrentSeatPrice  common.Amount            `json:"current_seat_price"`
+	NextSeatPrice     common.Amount            `json:"next_seat_price"`
+	ExpectedSeatPrice common.Amount            `json:"expected_seat_price"`
+	ExpectedSeats     float64                  `json:"expected_seats"`
+	StakedBalance     map[string]common.Amount `json:"staked_balance"`
+	UnstakedBalance   map[string]common.Amount `json:"unstaked_balance"`
+	// Account balances above the reserve
+	LiquidBalance map[string]common.Amount `json:"liquid_balance"`
+	// Epoch from which the unstaked balance of a delegator can be withdrawn
+	LockedUntil map[string]int64  `json:"locked_until"`
+	Result      *rpc.SubscrResult `json:"result"`
+	UpdatedAt   time.Time         `json:"updated_at"`
+}
+
+type command struct {
+	run  func(ctx context.Context, m *prom.PoolMetrics, epochStartHeight *int64) error
+	done chan error
+}
+
+func copyBalances(b map[string]common.Amount) map[string]common.Amount {
+	out := make(map[string]common.Amount, len(b))
+	for k, v := range b {
+		out[k] = v
+	}
+	return out
+}
+
+func (r *Runner) setStatus(res *rpc.SubscrResult, leftBlocks int, lastPingedEpoch int64) {
+	result := *res
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.status = Status{
+		PoolId:            r.poolId,
+		Strategy:          r.strategy.Name(),
+		DryRun:            r.dryRun,
+		LatestBlockHeight: res.LatestBlockHeight,
+		EpochStartHeight:  res.EpochStartHeight,
+		EpochLength:       res.EpochLength,
+		LeftBlocks:        leftBlocks,
+		LastPingedEpoch:   lastPingedEpoch,
+		CurrentStake:      res.CurrentStake,
+		NextStake:         res.NextStake,
+		ExpectedStake:     r.expectedStake,
+		ProducedBlocks:    res.ProducedBlocks,
+		ExpectedBlocks:    res.ExpectedBlocks,
+		Proposed:          res.Proposed,
+		KickedOut:         res.KickedOut,
+		CurrentSeatPrice:  r.currentSeatPrice,
+		NextSeatPrice:     r.nextSeatPrice,
+		ExpectedSeatPrice: r.expectedSeatPrice,
+		ExpectedSeats:     r.expectedStake.Ratio(r.expectedSeatPrice),
+		StakedBalance:     copyBalances(r.delegatorStakedBalance),
+		UnstakedBalance:   copyBalances(r.delegatorUnStakedBalance),
+		LiquidBalance:     copyBalances(r.delegatorLiquidBalance),
+		LockedUntil:       r.lockedUntil(),
+		Result:            &result,
+		UpdatedAt:         time.Now(),
+	}
+}
+
+func (r *Runner) PoolId() string {
+	return r.poolId
+}
+
+// Status returns the state of the last tick.
+func (r *Runner) Status() Status {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	s := r.status
+	s.Paused = r.paused
+	s.StakedBalance = copyBalances(s.StakedBalance)
+	s.UnstakedBalance = copyBalances(s.UnstakedBalance)
+	s.LiquidBalance = copyBalances(s.LiquidBalance)
+	s.LockedUntil = make(map[string]int64, len(r.status.LockedUntil))
+	for k, v := range r.status.LockedUntil {
+		s.LockedUntil[k] = v
+	}
+	return s
+}
+
+// Pause stops automatic restaking. Pings go on.
+func (r *Runner) Pause() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.paused = true
+}
+
+func (r *Runner) Resume() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.paused = false
+}
+
+func (r *Runner) Paused() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.paused
+}
+
+// exec runs f in the runner loop and waits for its result.
+func (r *Runner) exec(ctx context.Context, f func(ctx context.Context, m *prom.PoolMetrics, epochStartHeight *int64) error) error {
+	c := command{run: f, done: make(chan error, 1)}
+	select {
+	case r.commands <- c:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	select {
+	case err := <-c.done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Ping pings the pool now, through the same path as the ping at a new epoch.
+func (r *Runner) Ping(ctx context.Context, reason string) error {
+	return r.exec(ctx, func(ctx context.Context, m *prom.PoolMetrics, epochStartHeight *int64) error {
+		epoch := r.Status().EpochStartHeight
+		err := r.act(ctx, epoch, strategy.Intent{
+			Method:      "ping",
+			DelegatorId: r.defaultDelegatorId,
+			Reason:      reason,
+		}, m)
+		if err != nil {
+			return err
+		}
+		if epoch != 0 {
+			*epochStartHeight = epoch
+			if !r.dryRun {
+				r.savePingedEpoch(epoch)
+			}
+		}
+		return nil
+	})
+}
+
+// Restake runs the strategy on the last tick now, even when paused, and
+// returns the intents it acted on.
+func (r *Runner) Restake(ctx context.Context, reason string) ([]strategy.Intent, error) {
+	var intents []strategy.Intent
+	err := r.exec(ctx, func(ctx context.Context, m *prom.PoolMetrics, epochStartHeight *int64) error {
+		status := r.Status()
+		res := status.Result
+		if res == nil {
+			return errors.New("no data from the rpc monitor yet")
+		}
+		if !res.Proposed || res.KickedOut {
+			return errors.New("the pool is not in proposals")
+		}
+		if !r.resolvePending(ctx, res) {
+			return errors.New("waiting for pending transactions")
+		}
+		intents = r.strategy.Decide(r.snapshot(res, status.LeftBlocks))
+		for i := range intents {
+			intents[i].Reason = fmt.Sprintf("%s (%s)", intents[i].Reason, reason)
+		}
+		if !r.restake(ctx, res.EpochStartHeight, intents, m) {
+			return errors.New("restake failed, see the logs")
+		}
+		return nil
+	})
+	return intents, err
+}
+
+// Stake stakes amount from the unstaked balances of the delegators.
+func (r *Runner) Stake(ctx context.Context, amount common.Amount, reason string) error {
+	return r.manualRestake(ctx, "stake", amount, reason)
+}
+
+// Unstake unstakes amount from the staked balances of the delegators.
+func (r *Runner) Unstake(ctx context.Context, amount common.Amount, reason string) error {
+	return r.manualRestake(ctx, "unstake", amount, reason)
+}
+
+func (r *Runner) manualRestake(ctx context.Context, method string, amount common.Amount, reason string) error {
+	if amount.Sign() <= 0 {
+		return errors.New("amount must be positive")
+	}
+	return r.exec(ctx, func(ctx context.Context, m *prom.PoolMetrics, epochStartHeight *int64) error {
+		balances := r.delegatorUnStakedBalance
+		if method == "unstake" {
+			balances = r.delegatorStakedBalance
+		}
+		var available common.Amount
+		for _, b := range balances {
+			available = available.Add(b)
+		}
+		if available.Cmp(amount) < 0 {
+			return fmt.Errorf("only %s available to %s", available, method)
+		}
+		intents := strategy.Allocate(method, balances, amount, reason)
+		if !r.restake(ctx, r.Status().EpochStartHeight, intents, m) {
+			return fmt.Errorf("%s failed, see the logs", method)
+		}
+		return nil
+	})
+}
@@ -0,0 +1,78 @@
+package runner
+
+import (
+	"encoding/json"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/rozum-dev/near-go-warchest/common"
+)
+
+// Decision is a ping, stake or unstake the runner decided to make.
+type Decision struct {
+	Time             time.Time     `json:"time"`
+	PoolId           string        `json:"pool_id"`
+	EpochStartHeight int64         `json:"epoch_start_height"`
+	Method           string        `json:"method"`
+	DelegatorId      string        `json:"delegator_id"`
+	Amount           common.Amount `json:"amount"`
+	Reason           string        `json:"reason"`
+	Command          string        `json:"command"`
+	DryRun           bool          `json:"dry_run"`
+	Error            string        `json:"error,omitempty"`
+}
+
+// DecisionLog keeps the most recent decisions in memory and appends every
+// decision as a JSON line to a file, if one is given.
+type DecisionLog struct {
+	mu        sync.Mutex
+	size      int
+	decisions []Decision
+	file      *os.File
+}
+
+func NewDecisionLog(size int, path string) (*DecisionLog, error) {
+	l := &DecisionLog{size: size}
+	if path != "" {
+		f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+		if err != nil {
+			return nil, err
+		}
+		l.file = f
+	}
+	return l, nil
+}
+
+func (l *DecisionLog) Record(d Decision) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.decisions = append(l.decisions, d)
+	if len(l.decisions) > l.size {
+		l.decisions = l.decisions[len(l.decisions)-l.size:]
+	}
+	if l.file == nil {
+		return
+	}
+	b, err := json.Marshal(d)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	if _, err := l.file.Write(append(b, '\n')); err != nil {
+		log.Printf("Failed to write decision log: %s\n", err)
+	}
+}
+
+// Recent returns up to n most recent decisions, oldest first.
+func (l *DecisionLog) Recent(n int) []Decision {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if n <= 0 || n > len(l.decisions) {
+		n = len(l.decisions)
+	}
+	out := make([]Decision, n)
+	copy(out, l.decisions[len(l.decisions)-n:])
+	return out
+}
@@ -2,28 +2,31 @@ package runner
 
 import (
 	"context"
-	"fmt"
-	// "log"
+	"math/big"
+
 	"github.com/rozum-dev/near-go-warchest/common"
-	cmd "github.com/rozum-dev/near-go-warchest/helpers"
+	nearapi "github.com/rozum-dev/near-go-warchest/rpc/client"
 )
 
-func getDelegatorStakedBalance(ctx context.Context, poolId, delegatorId string) (int, error) {
-	r, err := cmd.Run(ctx, fmt.Sprintf(getStakedBalanceCmd, poolId, delegatorId))
-		// log.Printf("getStakedBalanceCmd, %s",fmt.Sprintf(getStakedBalanceCmd, poolId, delegatorId) )
-
-	if err != nil {
-		return 0, err
-	}
-	return common.GetStakeFromNearView(r), nil
+func getDelegatorStakedBalance(ctx context.Context, client *nearapi.Client, poolId, delegatorId string) (common.Amount, error) {
+	return client.GetAccountStakedBalance(ctx, poolId, delegatorId)
 }
 
-func getDelegatorUnStakedBalance(ctx context.Context, poolId, delegatorId string) (int, error) {
-	r, err := cmd.Run(ctx, fmt.Sprintf(getUnStakedBalanceCmd, poolId, delegatorId))
-		// log.Printf("getUnStakedBalanceCmd, %s",fmt.Sprintf(getUnStakedBalanceCmd, poolId, delegatorId) )
+// Balance an account keeps for each byte of its storage, storage_amount_per_byte
+// of the runtime config
+var storageAmountPerByte = new(big.Int).Exp(big.NewInt(10), big.NewInt(19), nil)
 
+// getDelegatorLiquidBalance returns the account balance above the amount its
+// storage locks and the reserve.
+func getDelegatorLiquidBalance(ctx context.Context, client *nearapi.Client, delegatorId string, reserve common.Amount) (common.Amount, error) {
+	account, err := client.ViewAccount(ctx, delegatorId)
 	if err != nil {
-		return 0, err
+		return common.Amount{}, err
 	}
-	return common.GetStakeFromNearView(r), nil
+	storage := common.NewAmount(new(big.Int).Mul(new(big.Int).SetUint64(account.StorageUsage), storageAmountPerByte))
+	return common.MaxAmount(account.Amount.Sub(storage).Sub(reserve), common.Amount{}), nil
+}
+
+func getDelegatorUnStakedBalance(ctx context.Context, client *nearapi.Client, poolId, delegatorId string) (common.Amount, error) {
+	return client.GetAccountUnstakedBalance(ctx, poolId, delegatorId)
 }
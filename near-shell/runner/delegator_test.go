@@ -0,0 +1,63 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/rozum-dev/near-go-warchest/common"
+	nearapi "github.com/rozum-dev/near-go-warchest/rpc/client"
+)
+
+func TestDelegatorLiquidBalance(t *testing.T) {
+	near := func(s string) common.Amount {
+		a, err := common.ParseNear(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return a
+	}
+	reserve := near("10")
+	tests := []struct {
+		name    string
+		amount  string
+		storage uint64
+		want    common.Amount
+	}{
+		{"above the reserve", near("25").Yocto(), 0, near("15")},
+		{"at the reserve", near("10").Yocto(), 0, common.Amount{}},
+		{"below the reserve", near("4").Yocto(), 0, common.Amount{}},
+		// 100 bytes lock 0.001 NEAR
+		{"above the storage and the reserve", near("25.001").Yocto(), 100, near("15")},
+		{"at the storage and the reserve", near("10.001").Yocto(), 100, common.Amount{}},
+		{"storage above the balance", near("0.0001").Yocto(), 100, common.Amount{}},
+	}
+	for _, tt := range tests {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprintf(w, `{"jsonrpc":"2.0","id":"dontcare","result":{"amount":"%s","locked":"0","code_hash":"11111111111111111111111111111111","storage_usage":%d,"block_height":1,"block_hash":"hash"}}`, tt.amount, tt.storage)
+		}))
+		client := nearapi.NewClientWithContext(context.Background(), srv.URL)
+		got, err := getDelegatorLiquidBalance(context.Background(), client, "owner.near", reserve)
+		srv.Close()
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if got.Cmp(tt.want) != 0 {
+			t.Errorf("%s: liquid balance %s, want %s", tt.name, got.Yocto(), tt.want.Yocto())
+		}
+	}
+}
+
+func TestDelegatorLiquidBalanceError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"jsonrpc":"2.0","id":"dontcare","error":{"name":"HANDLER_ERROR","cause":{"name":"UNKNOWN_ACCOUNT","info":{}},"code":-32000,"message":"Server error"}}`)
+	}))
+	defer srv.Close()
+	client := nearapi.NewClientWithContext(context.Background(), srv.URL)
+	if got, err := getDelegatorLiquidBalance(context.Background(), client, "owner.near", common.Amount{}); err == nil {
+		t.Errorf("liquid balance %s of an unknown account", got)
+	}
+}
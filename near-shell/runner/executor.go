@@ -0,0 +1,203 @@
+package runner
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"path/filepath"
+	"regexp"
+	"time"
+
+	"github.com/rozum-dev/near-go-warchest/common"
+
+	cmd "github.com/rozum-dev/near-go-warchest/helpers"
+	"github.com/rozum-dev/near-go-warchest/rpc/signer"
+	"github.com/rozum-dev/near-go-warchest/store"
+)
+
+// Executor performs state-changing calls on the staking pool contract:
+// ping, stake, unstake, deposit_and_stake and withdraw.
+// The amount is in yoctoNEAR and is ignored by ping.
+// Call returns the transaction hash when the backend knows it.
+type Executor interface {
+	Call(ctx context.Context, poolId, delegatorId, method, amount string) (string, error)
+}
+
+// NativeExecutor signs the transactions itself and sends them over JSON-RPC.
+// Signed transactions are kept in the store as pending until their outcome is known.
+type NativeExecutor struct {
+	signer *signer.Signer
+	store  *store.Store
+}
+
+func NewNativeExecutor(s *signer.Signer, st *store.Store) *NativeExecutor {
+	return &NativeExecutor{signer: s, store: st}
+}
+
+func (e *NativeExecutor) Call(ctx context.Context, poolId, delegatorId, method, amount string) (string, error) {
+	var args interface{}
+	var deposit *big.Int
+	switch method {
+	case "ping":
+		args = map[string]string{}
+	case "stake", "unstake", "withdraw":
+		args = map[string]string{"amount": amount}
+	case "deposit_and_stake":
+		args = map[string]string{}
+		d, ok := new(big.Int).SetString(amount, 10)
+		if !ok {
+			return "", fmt.Errorf("invalid deposit amount %q", amount)
+		}
+		deposit = d
+	default:
+		return "", fmt.Errorf("unsupported method %s", method)
+	}
+	signedTx, err := e.signer.SignFunctionCall(ctx, delegatorId, poolId, method, args, deposit)
+	if err != nil {
+		return "", err
+	}
+	hash := signedTx.Hash
+	if e.store != nil {
+		a, _ := common.ParseAmount(amount)
+		err := e.store.AddPendingTx(poolId, store.PendingTx{
+			Hash:        hash,
+			SignerId:    delegatorId,
+			Method:      method,
+			Amount:      a,
+			PublicKey:   signedTx.PublicKey,
+			Nonce:       signedTx.Nonce,
+			BlockHeight: signedTx.BlockHeight,
+			Time:        time.Now(),
+		})
+		if err != nil {
+			log.Printf("Failed to store pending transaction %s: %s\n", hash, err)
+		}
+	}
+	log.Printf("%s: sending %s transaction %s\n", delegatorId, method, hash)
+	res, err := e.signer.Send(ctx, signedTx.Data)
+	// Without an outcome the transaction stays pending until the runner finds
+	// out what happened to it
+	if e.store != nil && res != nil {
+		if err := e.store.RemovePendingTx(poolId, hash); err != nil {
+			log.Printf("Failed to remove pending transaction %s: %s\n", hash, err)
+		}
+	}
+	return hash, err
+}
+
+// Timeouts of near-shell calls. Stake and unstake wait for the transaction
+// to be executed, which takes longer than a ping.
+var shellTimeouts = map[string]time.Duration{
+	"ping":              30 * time.Second,
+	"stake":             60 * time.Second,
+	"unstake":           60 * time.Second,
+	"withdraw":          60 * time.Second,
+	"deposit_and_stake": 60 * time.Second,
+}
+
+// near-shell prints the hash of every transaction it sends
+var shellTxHash = regexp.MustCompile(`Transaction Id ([1-9A-HJ-NP-Za-km-z]{32,44})`)
+
+// Network ids near-shell accepts, such as mainnet, testnet or betanet
+var shellNetwork = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]*$`)
+
+// ShellExecutor sends the calls with near-shell on the network of the pool,
+// with the key of the delegator from the credentials directory.
+type ShellExecutor struct {
+	Runner      cmd.CommandRunner
+	Network     string
+	Credentials string
+}
+
+func NewShellExecutor(network, credentials string) *ShellExecutor {
+	return &ShellExecutor{Runner: cmd.NewExecRunner(shellTimeouts), Network: network, Credentials: credentials}
+}
+
+// shellCommand builds the near call command line for a pool method.
+// The ids are checked again, so nothing but an account id gets into an
+// argument.
+func shellCommand(network, credentials, poolId, delegatorId, method, amount string) (cmd.Command, error) {
+	for _, id := range []string{poolId, delegatorId} {
+		if _, err := common.ParseAccountID(id); err != nil {
+			return cmd.Command{}, err
+		}
+	}
+	if !shellNetwork.MatchString(network) {
+		return cmd.Command{}, fmt.Errorf("invalid network %q", network)
+	}
+	var args string
+	// Attached deposit in NEAR
+	var deposit []string
+	switch method {
+	case "ping":
+		args = "{}"
+	case "deposit_and_stake":
+		a, err := common.ParseAmount(amount)
+		if err != nil {
+			return cmd.Command{}, err
+		}
+		args = "{}"
+		deposit = []string{"--amount", a.NearString()}
+	case "stake", "unstake", "withdraw":
+		if _, err := common.ParseAmount(amount); err != nil {
+			return cmd.Command{}, err
+		}
+		data, err := json.Marshal(map[string]string{"amount": amount})
+		if err != nil {
+			return cmd.Command{}, err
+		}
+		args = string(data)
+	default:
+		return cmd.Command{}, errors.New("near-shell backend does not support " + method)
+	}
+	argv := append([]string{"call", poolId, method, args}, deposit...)
+	argv = append(argv, "--accountId", delegatorId, "--networkId", network)
+	if credentials != "" {
+		argv = append(argv, "--keyPath", filepath.Join(credentials, network, delegatorId+".json"))
+	}
+	return cmd.Command{
+		Kind: method,
+		Name: "near",
+		Args: argv,
+	}, nil
+}
+
+func (e *ShellExecutor) Call(ctx context.Context, poolId, delegatorId, method, amount string) (string, error) {
+	c, err := shellCommand(e.Network, e.Credentials, poolId, delegatorId, method, amount)
+	if err != nil {
+		return "", err
+	}
+	log.Printf("%s: running %s\n", delegatorId, c)
+	res, err := e.Runner.Run(ctx, c)
+	var hash string
+	if res != nil {
+		if m := shellTxHash.FindStringSubmatch(res.Stdout); m != nil {
+			hash = m[1]
+		}
+	}
+	var cmdErr *cmd.CmdError
+	if errors.As(err, &cmdErr) && cmdErr.Result != nil {
+		log.Printf("%s: %s failed after %s, exit code %d\nstdout: %s\nstderr: %s\n", delegatorId, method,
+			cmdErr.Result.Duration.Round(time.Millisecond), cmdErr.Result.ExitCode, cmdErr.Result.Stdout, cmdErr.Result.Stderr)
+	} else if res != nil && err == nil {
+		log.Printf("%s: %s done in %s\n", delegatorId, method, res.Duration.Round(time.Millisecond))
+	}
+	return hash, err
+}
+
+// describeCall formats a pool call the way near-shell would run it.
+func describeCall(poolId, delegatorId, method, amount string) string {
+	switch method {
+	case "ping":
+		return fmt.Sprintf("near call %s ping '{}' --accountId %s", poolId, delegatorId)
+	case "deposit_and_stake":
+		if a, err := common.ParseAmount(amount); err == nil {
+			amount = a.NearString()
+		}
+		return fmt.Sprintf("near call %s deposit_and_stake '{}' --amount %s --accountId %s", poolId, amount, delegatorId)
+	}
+	return fmt.Sprintf("near call %s %s '{\"amount\": \"%s\"}' --accountId %s", poolId, method, amount, delegatorId)
+}
@@ -0,0 +1,109 @@
+package runner
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/rozum-dev/near-go-warchest/common"
+	cmd "github.com/rozum-dev/near-go-warchest/helpers"
+)
+
+func TestShellCommand(t *testing.T) {
+	tenNear := common.NearAmount(10).Yocto()
+	key := []string{"--accountId", "owner.near", "--networkId", "mainnet", "--keyPath", "/keys/mainnet/owner.near.json"}
+	tests := []struct {
+		method string
+		amount string
+		want   []string
+	}{
+		{"ping", "", append([]string{"call", "pool.near", "ping", "{}"}, key...)},
+		{"stake", tenNear, append([]string{"call", "pool.near", "stake", `{"amount":"` + tenNear + `"}`}, key...)},
+		{"unstake", "1", append([]string{"call", "pool.near", "unstake", `{"amount":"1"}`}, key...)},
+		{"withdraw", tenNear, append([]string{"call", "pool.near", "withdraw", `{"amount":"` + tenNear + `"}`}, key...)},
+		{"deposit_and_stake", "12250000000000000000000000", append([]string{"call", "pool.near", "deposit_and_stake", "{}", "--amount", "12.25"}, key...)},
+	}
+	for _, tt := range tests {
+		c, err := shellCommand("mainnet", "/keys", "pool.near", "owner.near", tt.method, tt.amount)
+		if err != nil {
+			t.Errorf("%s: %v", tt.method, err)
+			continue
+		}
+		if c.Name != "near" || c.Kind != tt.method {
+			t.Errorf("%s: command %s of kind %s", tt.method, c.Name, c.Kind)
+		}
+		if !reflect.DeepEqual(c.Args, tt.want) {
+			t.Errorf("%s: args %q, want %q", tt.method, c.Args, tt.want)
+		}
+	}
+}
+
+func TestShellCommandNetworks(t *testing.T) {
+	// Pools on two networks use their own network and keys
+	tests := []struct {
+		network, credentials string
+		want                 []string
+	}{
+		{"testnet", "/keys", []string{"--accountId", "owner.near", "--networkId", "testnet", "--keyPath", "/keys/testnet/owner.near.json"}},
+		{"betanet", "/other", []string{"--accountId", "owner.near", "--networkId", "betanet", "--keyPath", "/other/betanet/owner.near.json"}},
+		// near-shell finds the key itself
+		{"mainnet", "", []string{"--accountId", "owner.near", "--networkId", "mainnet"}},
+	}
+	for _, tt := range tests {
+		c, err := shellCommand(tt.network, tt.credentials, "pool.near", "owner.near", "ping", "")
+		if err != nil {
+			t.Errorf("%s: %v", tt.network, err)
+			continue
+		}
+		if got := c.Args[4:]; !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: args %q, want %q", tt.network, got, tt.want)
+		}
+	}
+}
+
+func TestShellCommandRejects(t *testing.T) {
+	tests := []struct {
+		name                                         string
+		network, poolId, delegatorId, method, amount string
+	}{
+		{"option as pool", "mainnet", "--help", "owner.near", "ping", ""},
+		{"quote in delegator", "mainnet", "pool.near", "owner'.near", "ping", ""},
+		{"amount with json", "mainnet", "pool.near", "owner.near", "stake", `1", "x": "2`},
+		{"negative amount", "mainnet", "pool.near", "owner.near", "unstake", "-1"},
+		{"bad deposit", "mainnet", "pool.near", "owner.near", "deposit_and_stake", "12.5"},
+		{"unknown method", "mainnet", "pool.near", "owner.near", "unstake_all", ""},
+		{"no network", "", "pool.near", "owner.near", "ping", ""},
+		{"option as network", "--help", "pool.near", "owner.near", "ping", ""},
+		{"path in network", "../mainnet", "pool.near", "owner.near", "ping", ""},
+	}
+	for _, tt := range tests {
+		if c, err := shellCommand(tt.network, "/keys", tt.poolId, tt.delegatorId, tt.method, tt.amount); err == nil {
+			t.Errorf("%s: built %s", tt.name, c)
+		}
+	}
+}
+
+func TestShellExecutorCall(t *testing.T) {
+	fake := cmd.NewFakeRunner(
+		cmd.FakeStep{Match: "ping", Stdout: "Transaction Id 8s2qPjKFoB1TUYkCFvGPnBW7Q9Cjts8mfCn8GjnbMaZM\nTo see the transaction..."},
+		cmd.FakeStep{Match: "stake", Stderr: "Smart contract panicked: The staking is paused", ExitCode: 1},
+	)
+	e := &ShellExecutor{Runner: fake, Network: "mainnet"}
+	ctx := context.Background()
+
+	hash, err := e.Call(ctx, "pool.near", "owner.near", "ping", "")
+	if err != nil || hash != "8s2qPjKFoB1TUYkCFvGPnBW7Q9Cjts8mfCn8GjnbMaZM" {
+		t.Errorf("ping: hash %q, error %v", hash, err)
+	}
+	_, err = e.Call(ctx, "pool.near", "owner.near", "stake", "1")
+	if !cmd.IsKind(err, cmd.ErrContractPanic) {
+		t.Errorf("stake: %v, want a contract panic", err)
+	}
+	// Nothing is run for a call that cannot be built
+	if _, err := e.Call(ctx, "pool.near", "owner.near", "unstake_all", ""); err == nil {
+		t.Error("unstake_all: no error")
+	}
+	if n := len(fake.Calls()); n != 2 || fake.Pending() != 0 {
+		t.Errorf("%d commands run, %d steps left", n, fake.Pending())
+	}
+}
@@ -1,61 +1,30 @@
 package runner
 
 import (
-	"context"
 	"log"
 
-	"github.com/rozum-dev/near-go-warchest/common"
-	cmd "github.com/rozum-dev/near-go-warchest/helpers"
 	"github.com/prometheus/client_golang/prometheus"
+	"github.com/rozum-dev/near-go-warchest/rpc"
 )
 
-func (r *Runner) fetchPrices(ctx context.Context, nextSeatPriceGauge, expectedSeatPriceGauge prometheus.Gauge) bool {
-	if r.currentSeatPrice == 0 {
-		// Current seat price
-		csp, err := getSeatPrice(ctx, currentSeatPriceCmd)
-		if err != nil {
-			log.Println("Failed to get currentSeatPrice")
-			if r.currentSeatPrice == 0 {
-				return false
-			}
-		} else {
-			r.currentSeatPrice = csp
-		}
-		log.Printf("Current seat price %d\n", r.currentSeatPrice)
-	}
-	// Next seat price
-	nsp, err := getSeatPrice(ctx, nextSeatPriceCmd)
-	if err != nil {
-		log.Println("Failed to get nextSeatPrice")
-		if r.nextSeatPrice == 0 {
-			return false
-		}
-	} else {
-		r.nextSeatPrice = nsp
-	}
-	log.Printf("Next seat price %d\n", r.nextSeatPrice)
-	nextSeatPriceGauge.Set(float64(r.nextSeatPrice))
-
-	// Expected seat price
-	esp, err := getSeatPrice(ctx, proposalsSeatPriceCmd)
-	if err != nil {
-		log.Println("Failed to get expectedSeatPrice")
-		if r.expectedSeatPrice == 0 {
+// fetchPrices takes the seat prices calculated by the monitor and keeps
+// the previous ones when the monitor could not calculate them.
+func (r *Runner) fetchPrices(res *rpc.SubscrResult, nextSeatPriceGauge, expectedSeatPriceGauge prometheus.Gauge) bool {
+	if res.SeatPrices == nil {
+		log.Println("Failed to get seat prices")
+		if r.expectedSeatPrice.IsZero() {
 			return false
 		}
 	} else {
-		r.expectedSeatPrice = esp
+		r.currentSeatPrice = res.SeatPrices.Current
+		r.nextSeatPrice = res.SeatPrices.Next
+		r.expectedSeatPrice = res.SeatPrices.Expected
+		r.saveSeatPrices()
 	}
-	log.Printf("Expected seat price %d\n", r.expectedSeatPrice)
-	expectedSeatPriceGauge.Set(float64(r.expectedSeatPrice))
+	log.Printf("Current seat price %s\n", r.currentSeatPrice)
+	log.Printf("Next seat price %s\n", r.nextSeatPrice)
+	nextSeatPriceGauge.Set(r.nextSeatPrice.Near())
+	log.Printf("Expected seat price %s\n", r.expectedSeatPrice)
+	expectedSeatPriceGauge.Set(r.expectedSeatPrice.Near())
 	return true
 }
-
-func getSeatPrice(ctx context.Context, command string) (int, error) {
-	r, err := cmd.Run(ctx, command)
-	if err != nil {
-		log.Printf("Failed to run %s", command)
-		return 0, err
-	}
-	return common.GetIntFromString(r), nil
-}
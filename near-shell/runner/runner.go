@@ -2,34 +2,76 @@ package runner
 
 import (
 	"context"
-	"fmt"
 	"log"
-	"os"
-	"os/signal"
-	"syscall"
+	"sync"
 
 	"github.com/rozum-dev/near-go-warchest/common"
-	cmd "github.com/rozum-dev/near-go-warchest/helpers"
 	"github.com/rozum-dev/near-go-warchest/rpc"
+	nearapi "github.com/rozum-dev/near-go-warchest/rpc/client"
+	"github.com/rozum-dev/near-go-warchest/services/notifier"
 	prom "github.com/rozum-dev/near-go-warchest/services/prometheus"
+	"github.com/rozum-dev/near-go-warchest/store"
+	"github.com/rozum-dev/near-go-warchest/strategy"
 )
 
 type Runner struct {
-	poolId, defaultDelegatorId                         string
-	delegatorIds                                       []string
-	expectedStake                                      int
-	rpcSuccess, rpcFailed                              int
-	delegatorStakedBalance, delegatorUnStakedBalance   map[string]int
-	currentSeatPrice, nextSeatPrice, expectedSeatPrice int
+	poolId, defaultDelegatorId                       string
+	delegatorIds                                     []string
+	expectedStake                                    common.Amount
+	rpcSuccess, rpcFailed                            int
+	delegatorStakedBalance, delegatorUnStakedBalance map[string]common.Amount
+	// Account balances above the reserve, for deposit_and_stake
+	delegatorLiquidBalance                             map[string]common.Amount
+	reserve                                            common.Amount
+	currentSeatPrice, nextSeatPrice, expectedSeatPrice common.Amount
+	client                                             *nearapi.Client
+	executor                                           Executor
+	strategy                                           strategy.Strategy
+	dryRun                                             bool
+	// Dry run decisions already recorded in the epoch
+	dryRunEpoch   int64
+	dryRunDecided map[string]bool
+	decisions     *DecisionLog
+	store         *store.Store
+	notifier      notifier.Notifier
+	// Number of the current epoch and when the unstaked balances unlock
+	epochHeight int64
+	unlocks     map[string]store.Unlock
+	// Unstaked balances the pool confirmed as available on this tick
+	withdrawable map[string]common.Amount
+
+	// Operator commands, run between two ticks
+	commands chan command
+	mu       sync.Mutex
+	paused   bool
+	status   Status
+}
+
+type Options struct {
+	// Decide and record, but never send a transaction
+	DryRun    bool
+	Decisions *DecisionLog
+	// Keeps the state across restarts, optional
+	Store *store.Store
+	// Alerts on failed pings and on restakes, optional
+	Notifier notifier.Notifier
+	// Kept on every delegator account for gas and storage, the rest of the
+	// account balance can be deposited and staked
+	Reserve common.Amount
 }
 
-func NewRunner(poolId string, delegatorIds []string) *Runner {
+func NewRunner(client *nearapi.Client, poolId string, delegatorIds []string, executor Executor, s strategy.Strategy, opts Options) *Runner {
+	if opts.Notifier == nil {
+		opts.Notifier = notifier.Nop{}
+	}
 	var defaultDelegatorId string
-	delegatorStakedBalance := make(map[string]int)
-	delegatorUnStakedBalance := make(map[string]int)
+	delegatorStakedBalance := make(map[string]common.Amount)
+	delegatorUnStakedBalance := make(map[string]common.Amount)
+	delegatorLiquidBalance := make(map[string]common.Amount)
 	for _, delegatorId := range delegatorIds {
-		delegatorStakedBalance[delegatorId] = 0
-		delegatorUnStakedBalance[delegatorId] = 0
+		delegatorStakedBalance[delegatorId] = common.Amount{}
+		delegatorUnStakedBalance[delegatorId] = common.Amount{}
+		delegatorLiquidBalance[delegatorId] = common.Amount{}
 		defaultDelegatorId = delegatorId
 	}
 	return &Runner{
@@ -38,17 +80,35 @@ func NewRunner(poolId string, delegatorIds []string) *Runner {
 		defaultDelegatorId:       defaultDelegatorId,
 		delegatorStakedBalance:   delegatorStakedBalance,
 		delegatorUnStakedBalance: delegatorUnStakedBalance,
+		delegatorLiquidBalance:   delegatorLiquidBalance,
+		reserve:                  opts.Reserve,
+		client:                   client,
+		executor:                 executor,
+		strategy:                 s,
+		dryRun:                   opts.DryRun,
+		decisions:                opts.Decisions,
+		store:                    opts.Store,
+		notifier:                 opts.Notifier,
+		unlocks:                  make(map[string]store.Unlock),
+		commands:                 make(chan command),
+		status: Status{
+			PoolId:          poolId,
+			Strategy:        s.Name(),
+			DryRun:          opts.DryRun,
+			StakedBalance:   map[string]common.Amount{},
+			UnstakedBalance: map[string]common.Amount{},
+			LiquidBalance:   map[string]common.Amount{},
+			LockedUntil:     map[string]int64{},
+		},
 	}
 }
 
-func (r *Runner) Run(ctx context.Context, resCh chan *rpc.SubscrResult, m *prom.PromMetrics, sem common.Sem) {
-
-	sigc := make(chan os.Signal, 1)
-	signal.Notify(sigc, syscall.SIGINT, syscall.SIGTERM)
+func (r *Runner) Run(ctx context.Context, resCh chan *rpc.SubscrResult, m *prom.PoolMetrics, sem common.Sem) {
 
 	var notInProposals bool
-	var epochStartHeight int64
-	var leftBlocksPrev, estimatedBlocksCountPerReq int // per 90 sec
+	var leftBlocksPrev int
+	// Last pinged epoch, the last block seen and blocks per request
+	epochStartHeight, cache, estimatedBlocksCountPerReq := r.restore()
 	for {
 		select {
 		case res := <-resCh:
@@ -56,64 +116,89 @@ func (r *Runner) Run(ctx context.Context, resCh chan *rpc.SubscrResult, m *prom.
 			if res.Err != nil {
 				r.rpcFailed++
 				log.Println("Failed to connect to RPC")
-				if r.rpcSuccess > 0 {
-					log.Println("Using cache...")
-					res.LatestBlockHeight = res.LatestBlockHeight + int64(estimatedBlocksCountPerReq)
-					// Estimated new epoch
-					if res.LatestBlockHeight >= res.EpochStartHeight+int64(res.EpochLength) {
-						res.EpochStartHeight += int64(res.EpochLength)
-					}
-				} else {
+				if cache == nil {
 					sem.Release()
 					continue
 				}
+				log.Println("Using cache...")
+				estimated := *cache
+				estimated.Err = nil
+				estimated.LatestBlockHeight += int64(estimatedBlocksCountPerReq)
+				// Estimated new epoch
+				if estimated.LatestBlockHeight >= estimated.EpochStartHeight+int64(estimated.EpochLength) {
+					estimated.EpochStartHeight += int64(estimated.EpochLength)
+					if estimated.EpochHeight > 0 {
+						estimated.EpochHeight++
+					}
+				}
+				res = &estimated
+			} else {
+				r.rpcSuccess++
 			}
-			r.rpcSuccess++
 			if epochStartHeight == 0 {
 				epochStartHeight = res.EpochStartHeight
-				leftBlocksPrev = int(res.EpochStartHeight) - int(res.LatestBlockHeight) + res.EpochLength
 			}
 			leftBlocks := int(res.EpochStartHeight) - int(res.LatestBlockHeight) + res.EpochLength
-			estimatedBlocksCountPerReq = leftBlocksPrev - leftBlocks
+			if leftBlocksPrev != 0 && leftBlocksPrev > leftBlocks {
+				estimatedBlocksCountPerReq = leftBlocksPrev - leftBlocks
+			}
 			leftBlocksPrev = leftBlocks
+			cache = res
+			r.saveChain(res, estimatedBlocksCountPerReq)
 			log.Printf("LatestBlockHeight: %d\n", res.LatestBlockHeight)
 			log.Printf("EpochStartHeight: %d\n", res.EpochStartHeight)
 			log.Printf("Left Blocks: %d\n", leftBlocks)
 
-			r.expectedStake = getExpectedStake(ctx, r.poolId)
-			if r.expectedStake != 0 {
-				log.Printf("Expected stake: %d\n", r.expectedStake)
+			if res.Proposed {
+				r.expectedStake = res.ExpectedStake
+				log.Printf("Expected stake: %s\n", r.expectedStake)
 				notInProposals = false
-				m.ExpectedStakeGauge.Set(float64(r.expectedStake))
+				m.ExpectedStakeGauge.Set(r.expectedStake.Near())
 			} else {
 				log.Printf("You are not in proposals\n")
 				notInProposals = true
 			}
-			log.Printf("Current stake: %d\n", res.CurrentStake)
-			log.Printf("Next stake: %d\n", res.NextStake)
+			log.Printf("Current stake: %s\n", res.CurrentStake)
+			log.Printf("Next stake: %s\n", res.NextStake)
 
 			// multiple delegator accounts
-			var totalDelegatorsStakedBalance, totalDelegatorsUnStakedBalance int
+			var totalDelegatorsStakedBalance, totalDelegatorsUnStakedBalance, totalDelegatorsLiquidBalance common.Amount
 			for _, delegatorId := range r.delegatorIds {
-				dsb, err := getDelegatorStakedBalance(ctx, r.poolId, delegatorId)
-				if err == nil {
+				dsb, err := getDelegatorStakedBalance(ctx, r.client, r.poolId, delegatorId)
+				if err != nil {
+					log.Println(err)
+				} else {
 					r.delegatorStakedBalance[delegatorId] = dsb
-					totalDelegatorsStakedBalance += dsb
+					totalDelegatorsStakedBalance = totalDelegatorsStakedBalance.Add(dsb)
 				}
-				log.Printf("%s staked balance: %d\n", delegatorId, dsb)
+				log.Printf("%s staked balance: %s\n", delegatorId, dsb)
 
-				dusb, err := getDelegatorUnStakedBalance(ctx, r.poolId, delegatorId)
-				if err == nil {
+				dusb, err := getDelegatorUnStakedBalance(ctx, r.client, r.poolId, delegatorId)
+				if err != nil {
+					log.Println(err)
+				} else {
 					r.delegatorUnStakedBalance[delegatorId] = dusb
-					totalDelegatorsUnStakedBalance += dusb
+					totalDelegatorsUnStakedBalance = totalDelegatorsUnStakedBalance.Add(dusb)
+				}
+				log.Printf("%s unstaked balance: %s\n", delegatorId, dusb)
+
+				dlb, err := getDelegatorLiquidBalance(ctx, r.client, delegatorId, r.reserve)
+				if err != nil {
+					log.Println(err)
+				} else {
+					r.delegatorLiquidBalance[delegatorId] = dlb
+					totalDelegatorsLiquidBalance = totalDelegatorsLiquidBalance.Add(dlb)
 				}
-				log.Printf("%s unstaked balance: %d\n", delegatorId, dusb)
+				log.Printf("%s liquid balance: %s\n", delegatorId, dlb)
 			}
-			m.DStakedBalanceGauge.Set(float64(totalDelegatorsStakedBalance))
-			m.DUnStakedBalanceGauge.Set(float64(totalDelegatorsUnStakedBalance))
+			m.DStakedBalanceGauge.Set(totalDelegatorsStakedBalance.Near())
+			m.DUnStakedBalanceGauge.Set(totalDelegatorsUnStakedBalance.Near())
+			m.DLiquidBalanceGauge.Set(totalDelegatorsLiquidBalance.Near())
+			r.epochHeight = epoch(res)
+			r.checkUnlocks(ctx, m)
 
 			m.LeftBlocksGauge.Set(float64(leftBlocks))
-			m.StakeAmountGauge.Set(float64(res.CurrentStake))
+			m.StakeAmountGauge.Set(res.CurrentStake.Near())
 			m.RestakeGauge.Set(0)
 			m.PingGauge.Set(0)
 
@@ -121,21 +206,34 @@ func (r *Runner) Run(ctx context.Context, resCh chan *rpc.SubscrResult, m *prom.
 				// New epoch
 				// If the new epoch then ping
 				log.Println("Starting ping...")
-				command := fmt.Sprintf(pingCmd, r.poolId, r.defaultDelegatorId)
-				_, err := cmd.Run(ctx, command)
+				err := r.act(ctx, res.EpochStartHeight, strategy.Intent{
+					Method:      "ping",
+					DelegatorId: r.defaultDelegatorId,
+					Reason:      "new epoch",
+				}, m)
 				if err != nil {
+					log.Println(err)
 					m.PingGauge.Set(0)
+					r.notify(ctx, notifier.PingFailed, err.Error())
 				} else {
-					log.Printf("Success: %s\n", command)
+					log.Printf("%s: Success ping %s\n", r.defaultDelegatorId, r.poolId)
 					epochStartHeight = res.EpochStartHeight
-					if res.CurrentStake == 0 {
+					// A dry run ping must not stop the real one after a restart
+					if !r.dryRun {
+						r.savePingedEpoch(epochStartHeight)
+					}
+					if res.CurrentStake.IsZero() {
 						m.PingGauge.Set(float64(100000))
 					} else {
-						m.PingGauge.Set(float64(res.CurrentStake))
+						m.PingGauge.Set(res.CurrentStake.Near())
 					}
 				}
 			}
-			if !r.fetchPrices(ctx, m.NextSeatPriceGauge, m.ExpectedSeatPriceGauge) {
+			pricesOk := r.fetchPrices(res, m.NextSeatPriceGauge, m.ExpectedSeatPriceGauge)
+			r.setStatus(res, leftBlocks, epochStartHeight)
+			// A transaction whose outcome is not known yet may still move the stake
+			settled := r.resolvePending(ctx, res)
+			if !pricesOk {
 				sem.Release()
 				continue
 			}
@@ -145,38 +243,59 @@ func (r *Runner) Run(ctx context.Context, resCh chan *rpc.SubscrResult, m *prom.
 				continue
 			}
 
-			// Seats calculation
-			seats := float64(r.expectedStake) / float64(r.expectedSeatPrice)
-			log.Printf("Expected seats: %f", seats)
-
-			if leftBlocks > 1000 {
-				log.Printf("Too early tp stake/unstake, left blocks = %d", leftBlocks)
+			if r.Paused() {
+				log.Println("Restaking is paused")
+				sem.Release()
+				continue
 			}
 
-			if seats > 1.001 && leftBlocks < 1000 {
-				log.Printf("You retain %f seats\n", seats)
-				tokensAmountMap := getTokensAmountToRestake("unstake", r.delegatorStakedBalance, r.expectedStake, r.expectedSeatPrice)
-				if len(tokensAmountMap) == 0 {
-					log.Printf("You don't have enough staked balance\n")
-					sem.Release()
-					continue
-				}
-				// Run near unstake
-				restake(ctx, r.poolId, "unstake", tokensAmountMap, m.RestakeGauge, m.StakeAmountGauge)
-			} else if seats < 1.0 && leftBlocks < 1000 {
-				log.Printf("You don't have enough stake to get one seat: %f\n", seats)
-				tokensAmountMap := getTokensAmountToRestake("stake", r.delegatorUnStakedBalance, r.expectedStake, r.expectedSeatPrice)
-				// Run near stake
-				restake(ctx, r.poolId, "stake", tokensAmountMap, m.RestakeGauge, m.StakeAmountGauge)
-			} else if seats >= 1.0 && seats < 1.001 {
-				log.Println("I'm okay with a stake")
+			if !settled {
+				log.Println("Waiting for pending transactions")
+				sem.Release()
+				continue
 			}
+
+			intents := r.strategy.Decide(r.snapshot(res, leftBlocks))
+			// Run near stake/unstake
+			r.restake(ctx, res.EpochStartHeight, intents, m)
+			sem.Release()
+		case c := <-r.commands:
+			sem.Acquare()
+			c.done <- c.run(ctx, m, &epochStartHeight)
 			sem.Release()
 		case <-ctx.Done():
 			return
-		case <-sigc:
-			log.Println("System kill")
-			os.Exit(0)
 		}
 	}
 }
+
+func (r *Runner) snapshot(res *rpc.SubscrResult, leftBlocks int) *strategy.Snapshot {
+	var numSeats int
+	if res.Protocol != nil {
+		numSeats = res.Protocol.NumSeats()
+	}
+	return &strategy.Snapshot{
+		PoolId:            r.poolId,
+		EpochStartHeight:  res.EpochStartHeight,
+		EpochLength:       res.EpochLength,
+		LeftBlocks:        leftBlocks,
+		NumSeats:          numSeats,
+		CurrentSeatPrice:  r.currentSeatPrice,
+		NextSeatPrice:     r.nextSeatPrice,
+		ExpectedSeatPrice: r.expectedSeatPrice,
+		CurrentStake:      res.CurrentStake,
+		NextStake:         res.NextStake,
+		ExpectedStake:     r.expectedStake,
+		StakedBalance:     r.delegatorStakedBalance,
+		UnstakedBalance:   r.delegatorUnStakedBalance,
+		LiquidBalance:     r.delegatorLiquidBalance,
+		Unlocked:          r.withdrawable,
+	}
+}
+
+func (r *Runner) notify(ctx context.Context, kind, message string) {
+	err := r.notifier.Notify(ctx, notifier.Event{Kind: kind, PoolId: r.poolId, Message: message})
+	if err != nil {
+		log.Println(err)
+	}
+}
@@ -4,126 +4,111 @@ import (
 	"context"
 	"fmt"
 	"log"
-	"sort"
-	"strings"
+	"strconv"
+	"time"
 
-	"github.com/rozum-dev/near-go-warchest/common"
-	cmd "github.com/rozum-dev/near-go-warchest/helpers"
-	"github.com/prometheus/client_golang/prometheus"
+	"github.com/rozum-dev/near-go-warchest/services/notifier"
+	prom "github.com/rozum-dev/near-go-warchest/services/prometheus"
+	"github.com/rozum-dev/near-go-warchest/store"
+	"github.com/rozum-dev/near-go-warchest/strategy"
 )
 
-func restake(ctx context.Context, poolId, method string, tokensAmountMap map[string]int, restakeGauge, stakeAmountGauge prometheus.Gauge) bool {
-	if len(tokensAmountMap) == 0 {
+func (r *Runner) restake(ctx context.Context, epochStartHeight int64, intents []strategy.Intent, m *prom.PoolMetrics) bool {
+	if len(intents) == 0 {
 		return false
 	}
-	for delegatorId, delegatorBalance := range tokensAmountMap {
-		tokensAmountStr := common.GetStringFromStake(delegatorBalance)
-		stakeAmountGauge.Set(float64(delegatorBalance))
+	for _, intent := range intents {
+		if !r.dryRun && r.sent(epochStartHeight, intent) {
+			log.Printf("%s: %s %s was already sent in this epoch, skipping\n", intent.DelegatorId, intent.Method, intent.Amount)
+			continue
+		}
+		m.StakeAmountGauge.Set(intent.Amount.Near())
 
-		log.Printf("%s: Starting %s %d NEAR\n", delegatorId, method, delegatorBalance)
-		err := runStake(ctx, poolId, method, tokensAmountStr, delegatorId)
+		log.Printf("%s: Starting %s %s: %s\n", intent.DelegatorId, intent.Method, intent.Amount, intent.Reason)
+		succeeded, failed := notifier.StakeSucceeded, notifier.StakeFailed
+		if intent.Method == "withdraw" {
+			succeeded, failed = notifier.WithdrawSucceeded, notifier.WithdrawFailed
+		}
+		err := r.act(ctx, epochStartHeight, intent, m)
 		if err != nil {
+			log.Println(err)
+			r.notify(ctx, failed, fmt.Sprintf("%s %s from %s failed: %s", intent.Method, intent.Amount, intent.DelegatorId, err))
 			return false
 		}
-		log.Printf("%s: Success %sd %d NEAR\n", delegatorId, method, delegatorBalance)
-		restakeGauge.Set(float64(delegatorBalance))
+		if !r.dryRun {
+			log.Printf("%s: Success %s %s\n", intent.DelegatorId, pastTense(intent.Method), intent.Amount)
+			if intent.Method != "withdraw" {
+				m.RestakeGauge.Set(intent.Amount.Near())
+			}
+			r.notify(ctx, succeeded, fmt.Sprintf("%s %s from %s: %s", pastTense(intent.Method), intent.Amount, intent.DelegatorId, intent.Reason))
+		}
 	}
 
 	return true
 }
 
-func runStake(ctx context.Context, poolId, method, amount, delegatorId string) error {
-	_, err := cmd.Run(ctx, fmt.Sprintf(stakeCmd, poolId, method, amount, delegatorId))
-	log.Printf("Stake | unstake %s \n", fmt.Sprintf(stakeCmd, poolId, method, amount, delegatorId))
-	if err != nil {
-		log.Println(err)
-		return err
+// act makes a single call on the pool and records the decision.
+// In dry-run mode the call is only recorded.
+func (r *Runner) act(ctx context.Context, epochStartHeight int64, intent strategy.Intent, m *prom.PoolMetrics) error {
+	d := Decision{
+		Time:             time.Now(),
+		PoolId:           r.poolId,
+		EpochStartHeight: epochStartHeight,
+		Method:           intent.Method,
+		DelegatorId:      intent.DelegatorId,
+		Amount:           intent.Amount,
+		Reason:           intent.Reason,
+		Command:          describeCall(r.poolId, intent.DelegatorId, intent.Method, intent.Amount.Yocto()),
+		DryRun:           r.dryRun,
+	}
+	var err error
+	if r.dryRun {
+		log.Printf("Dry run, skipping: %s\n", d.Command)
+		// The balances do not change in a dry run, so the same decision
+		// comes back every tick
+		if r.dryRunDecided == nil || r.dryRunEpoch != epochStartHeight {
+			r.dryRunEpoch = epochStartHeight
+			r.dryRunDecided = make(map[string]bool)
+		}
+		key := d.Method + " " + d.DelegatorId + " " + d.Amount.Yocto()
+		if r.dryRunDecided[key] {
+			return nil
+		}
+		r.dryRunDecided[key] = true
+	} else {
+		var txHash string
+		txHash, err = r.executor.Call(ctx, r.poolId, intent.DelegatorId, intent.Method, intent.Amount.Yocto())
+		if err == nil && intent.Method == "unstake" {
+			r.unstaked(intent.DelegatorId)
+		}
+		if err == nil {
+			r.saveAction(epochStartHeight, store.Action{
+				Time:        d.Time,
+				Method:      intent.Method,
+				DelegatorId: intent.DelegatorId,
+				Amount:      intent.Amount,
+				TxHash:      txHash,
+			})
+		}
 	}
-	return nil
-}
-
-func getExpectedStake(ctx context.Context, accountId string) int {
-	currentProp, err := cmd.Run(ctx, fmt.Sprintf(proposalsCmd, accountId))
 	if err != nil {
-		log.Printf("Failed to run proposalsCmd, %s",fmt.Sprintf(proposalsCmd, accountId) )
-		return 0
+		d.Error = err.Error()
 	}
-	if currentProp != "" {
-		sa := strings.Split(currentProp, "|")
-		if len(sa) >= 4 {
-			s := sa[3]
-			if len(strings.Fields(s)) > 1 {
-				return common.GetIntFromString(strings.Fields(s)[2])
-			} else {
-				return common.GetIntFromString(strings.Fields(s)[0])
-			}
-		}
+	if r.decisions != nil {
+		r.decisions.Record(d)
 	}
-	return 0
+	dryRun := strconv.FormatBool(r.dryRun)
+	m.DecisionsCounter.WithLabelValues(intent.Method, dryRun).Inc()
+	m.DecisionAmountGauge.WithLabelValues(intent.Method, intent.DelegatorId, dryRun).Set(intent.Amount.Near())
+	return err
 }
 
-type delegator struct {
-	delegatorBalance int
-	delegatorId      string
-}
-
-type entries []delegator
-
-func (s entries) Len() int           { return len(s) }
-func (s entries) Less(i, j int) bool { return s[i].delegatorBalance < s[j].delegatorBalance }
-func (s entries) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
-
-func getTokensAmountToRestake(method string, delegatorBalances map[string]int, expectedStake, expectedSeatPrice int) map[string]int {
-	var delegatorBalancesSorted entries
-	for k, v := range delegatorBalances {
-		delegatorBalancesSorted = append(delegatorBalancesSorted, delegator{delegatorBalance: v, delegatorId: k})
-	}
-
-	sort.Sort(sort.Reverse(delegatorBalancesSorted))
-
-	tokensAmountMap := make(map[string]int)
-	var balances []int
-	for _, v := range delegatorBalancesSorted {
-		var tokensAmount int
-		// Stake
-		if method == "stake" {
-			tokensAmount = expectedSeatPrice - expectedStake + 100
-			var sumOfStake int
-			if len(balances) > 0 {
-				for _, v := range balances {
-					sumOfStake += v
-				}
-				sumOfStake += v.delegatorBalance
-				if sumOfStake > tokensAmount {
-					overage := sumOfStake - tokensAmount
-					if v.delegatorBalance-overage == 0 {
-						return tokensAmountMap
-					}
-					tokensAmountMap[v.delegatorId] = v.delegatorBalance - overage
-					return tokensAmountMap
-				}
-			}
-
-			if tokensAmount > v.delegatorBalance {
-				log.Printf("%s not enough balance to stake %d NEAR\n", v.delegatorId, tokensAmount)
-				tokensAmountMap[v.delegatorId] = v.delegatorBalance
-				balances = append(balances, v.delegatorBalance)
-				continue
-			}
-			tokensAmountMap[v.delegatorId] = tokensAmount
-			return tokensAmountMap
-		} else {
-			// Unstake
-			offset := 100
-			for tokensAmount < v.delegatorBalance-offset && expectedStake-tokensAmount > expectedSeatPrice+offset {
-				tokensAmount += offset
-			}
-			if tokensAmount == 0 {
-				break
-			}
-			tokensAmountMap[v.delegatorId] = tokensAmount
-			expectedStake -= tokensAmount
-		}
+func pastTense(method string) string {
+	switch method {
+	case "withdraw":
+		return "withdrew"
+	case "deposit_and_stake":
+		return "deposited and staked"
 	}
-	return tokensAmountMap
+	return method + "d"
 }
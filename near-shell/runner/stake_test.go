@@ -0,0 +1,108 @@
+package runner
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/rozum-dev/near-go-warchest/common"
+	prom "github.com/rozum-dev/near-go-warchest/services/prometheus"
+	"github.com/rozum-dev/near-go-warchest/store"
+	"github.com/rozum-dev/near-go-warchest/strategy"
+)
+
+type failingExecutor struct{}
+
+func (failingExecutor) Call(ctx context.Context, poolId, delegatorId, method, amount string) (string, error) {
+	return "", errors.New("no transactions in a dry run")
+}
+
+// countingExecutor succeeds and counts the calls.
+type countingExecutor struct {
+	calls int
+}
+
+func (e *countingExecutor) Call(ctx context.Context, poolId, delegatorId, method, amount string) (string, error) {
+	e.calls++
+	return "hash", nil
+}
+
+func TestRestakeSkipsCallsSentBeforeARestart(t *testing.T) {
+	dir, err := ioutil.TempDir("", "warchest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	st, err := store.Open(filepath.Join(dir, "state.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Sent by the run before the restart
+	err = st.AddAction("pool.near", 100, store.Action{Time: time.Now(), Method: "stake", DelegatorId: "owner.near", Amount: common.NearAmount(10), TxHash: "sent"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	e := &countingExecutor{}
+	r := NewRunner(nil, "pool.near", []string{"owner.near"}, e, strategy.StakeAll{}, Options{Store: st})
+	m := prom.NewPromMetrics().Pool("pool.near")
+	stake := strategy.Intent{Method: "stake", DelegatorId: "owner.near", Amount: common.NearAmount(10)}
+	steps := []struct {
+		name   string
+		epoch  int64
+		intent strategy.Intent
+		calls  int
+	}{
+		{"sent before the restart", 100, stake, 0},
+		{"another amount", 100, strategy.Intent{Method: "stake", DelegatorId: "owner.near", Amount: common.NearAmount(11)}, 1},
+		{"another method", 100, strategy.Intent{Method: "unstake", DelegatorId: "owner.near", Amount: common.NearAmount(10)}, 2},
+		{"sent after the restart", 100, strategy.Intent{Method: "unstake", DelegatorId: "owner.near", Amount: common.NearAmount(10)}, 2},
+		{"next epoch", 200, stake, 3},
+	}
+	for _, s := range steps {
+		r.restake(context.Background(), s.epoch, []strategy.Intent{s.intent}, m)
+		if e.calls != s.calls {
+			t.Errorf("%s: %d calls, want %d", s.name, e.calls, s.calls)
+		}
+	}
+}
+
+func TestDryRunRecordsEachDecisionOncePerEpoch(t *testing.T) {
+	decisions, _ := NewDecisionLog(100, "")
+	r := NewRunner(nil, "pool.near", []string{"owner.near"}, failingExecutor{}, strategy.StakeAll{}, Options{
+		DryRun:    true,
+		Decisions: decisions,
+	})
+	m := prom.NewPromMetrics().Pool("pool.near")
+	stake := strategy.Intent{Method: "stake", DelegatorId: "owner.near", Amount: common.NearAmount(10)}
+	steps := []struct {
+		epoch  int64
+		intent strategy.Intent
+	}{
+		{100, stake},
+		{100, stake},
+		// Another amount is another decision
+		{100, strategy.Intent{Method: "stake", DelegatorId: "owner.near", Amount: common.NearAmount(11)}},
+		{100, stake},
+		// A new epoch records it again
+		{200, stake},
+		{200, stake},
+	}
+	for _, s := range steps {
+		if err := r.act(context.Background(), s.epoch, s.intent, m); err != nil {
+			t.Fatalf("dry run act: %v", err)
+		}
+	}
+	if got := len(decisions.Recent(0)); got != 3 {
+		t.Errorf("recorded %d decisions, want 3", got)
+	}
+	for _, d := range decisions.Recent(0) {
+		if !d.DryRun {
+			t.Errorf("decision %+v is not marked as a dry run", d)
+		}
+	}
+}
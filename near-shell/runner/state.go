@@ -0,0 +1,173 @@
+package runner
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/rozum-dev/near-go-warchest/rpc"
+	"github.com/rozum-dev/near-go-warchest/store"
+	"github.com/rozum-dev/near-go-warchest/strategy"
+)
+
+// Blocks during which a transaction stays valid when the node does not say,
+// about a day on mainnet
+const defaultTxValidityPeriod = 86400
+
+// Pending transactions stored without their block height expire after a day
+const pendingTxExpiration = 24 * time.Hour
+
+// restore loads the seat prices from the store and returns the last pinged epoch,
+// the last block seen and the estimated number of blocks between two requests.
+func (r *Runner) restore() (int64, *rpc.SubscrResult, int) {
+	if r.store == nil {
+		return 0, nil, 0
+	}
+	st := r.store.Pool(r.poolId)
+	if st.SeatPrices != nil {
+		r.currentSeatPrice = st.SeatPrices.Current
+		r.nextSeatPrice = st.SeatPrices.Next
+		r.expectedSeatPrice = st.SeatPrices.Expected
+	}
+	var cache *rpc.SubscrResult
+	var blocksPerTick int
+	if st.Chain != nil {
+		cache = &rpc.SubscrResult{
+			LatestBlockHeight: st.Chain.LatestBlockHeight,
+			EpochStartHeight:  st.Chain.EpochStartHeight,
+			EpochLength:       st.Chain.EpochLength,
+		}
+		blocksPerTick = st.Chain.BlocksPerTick
+	}
+	for delegatorId, u := range st.Unlocks {
+		r.unlocks[delegatorId] = u
+	}
+	log.Printf("%s: restored state, last pinged epoch %d, %d pending transactions\n", r.poolId, st.LastPingedEpoch, len(st.PendingTxs))
+	return st.LastPingedEpoch, cache, blocksPerTick
+}
+
+// resolvePending looks up the outcome of the transactions whose result was
+// never received. It returns false while some of them can still be executed.
+func (r *Runner) resolvePending(ctx context.Context, res *rpc.SubscrResult) bool {
+	if r.store == nil {
+		return true
+	}
+	resolved := true
+	for _, tx := range r.store.Pool(r.poolId).PendingTxs {
+		outcome, err := r.client.TxStatus(ctx, tx.Hash, tx.SignerId)
+		if outcome == nil && err != nil {
+			if !r.pendingTxExpired(ctx, tx, res) {
+				log.Printf("%s: transaction %s is still unknown: %s\n", tx.SignerId, tx.Hash, err)
+				resolved = false
+				continue
+			}
+			log.Printf("%s: transaction %s expired\n", tx.SignerId, tx.Hash)
+		} else if err != nil {
+			log.Printf("%s: %s\n", tx.SignerId, err)
+		} else {
+			log.Printf("%s: pending %s transaction %s succeeded\n", tx.SignerId, tx.Method, tx.Hash)
+			if tx.Method == "unstake" {
+				r.unstaked(tx.SignerId)
+			}
+			r.saveAction(res.EpochStartHeight, store.Action{
+				Time:        tx.Time,
+				Method:      tx.Method,
+				DelegatorId: tx.SignerId,
+				Amount:      tx.Amount,
+				TxHash:      tx.Hash,
+			})
+		}
+		if err := r.store.RemovePendingTx(r.poolId, tx.Hash); err != nil {
+			log.Println(err)
+		}
+	}
+	return resolved
+}
+
+// pendingTxExpired tells whether a transaction that is not on chain can no
+// longer be included: its block hash is too old or its nonce was used by another
+// transaction of the same key.
+func (r *Runner) pendingTxExpired(ctx context.Context, tx store.PendingTx, res *rpc.SubscrResult) bool {
+	if tx.BlockHeight == 0 {
+		return time.Since(tx.Time) >= pendingTxExpiration
+	}
+	validity := int64(defaultTxValidityPeriod)
+	if res.Protocol != nil && res.Protocol.TransactionValidityPeriod > 0 {
+		validity = res.Protocol.TransactionValidityPeriod
+	}
+	if res.LatestBlockHeight > int64(tx.BlockHeight)+validity {
+		return true
+	}
+	if tx.PublicKey == "" {
+		return false
+	}
+	ak, err := r.client.ViewAccessKey(ctx, tx.SignerId, tx.PublicKey)
+	if err != nil || ak.Nonce < tx.Nonce {
+		return false
+	}
+	// The nonce may be the one of this transaction if it was included meanwhile
+	_, err = r.client.TxStatus(ctx, tx.Hash, tx.SignerId)
+	return err != nil
+}
+
+func (r *Runner) saveChain(res *rpc.SubscrResult, blocksPerTick int) {
+	if r.store == nil {
+		return
+	}
+	err := r.store.SetChain(r.poolId, store.Chain{
+		LatestBlockHeight: res.LatestBlockHeight,
+		EpochStartHeight:  res.EpochStartHeight,
+		EpochLength:       res.EpochLength,
+		BlocksPerTick:     blocksPerTick,
+	})
+	if err != nil {
+		log.Printf("Failed to save state: %s\n", err)
+	}
+}
+
+func (r *Runner) savePingedEpoch(epochStartHeight int64) {
+	if r.store == nil {
+		return
+	}
+	if err := r.store.SetLastPingedEpoch(r.poolId, epochStartHeight); err != nil {
+		log.Printf("Failed to save state: %s\n", err)
+	}
+}
+
+func (r *Runner) saveSeatPrices() {
+	if r.store == nil {
+		return
+	}
+	err := r.store.SetSeatPrices(r.poolId, store.SeatPrices{
+		Current:  r.currentSeatPrice,
+		Next:     r.nextSeatPrice,
+		Expected: r.expectedSeatPrice,
+	})
+	if err != nil {
+		log.Printf("Failed to save state: %s\n", err)
+	}
+}
+
+// sent tells whether the same call was already sent in the epoch, also by a
+// run before a restart, so it is not made twice while the node does not show
+// its outcome in the balances yet.
+func (r *Runner) sent(epochStartHeight int64, intent strategy.Intent) bool {
+	if r.store == nil {
+		return false
+	}
+	for _, a := range r.store.Pool(r.poolId).Actions[epochStartHeight] {
+		if a.Method == intent.Method && a.DelegatorId == intent.DelegatorId && a.Amount.Cmp(intent.Amount) == 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *Runner) saveAction(epochStartHeight int64, a store.Action) {
+	if r.store == nil {
+		return
+	}
+	if err := r.store.AddAction(r.poolId, epochStartHeight, a); err != nil {
+		log.Printf("Failed to save state: %s\n", err)
+	}
+}
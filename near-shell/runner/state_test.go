@@ -0,0 +1,169 @@
+package runner
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/rozum-dev/near-go-warchest/common"
+	"github.com/rozum-dev/near-go-warchest/rpc"
+	nearapi "github.com/rozum-dev/near-go-warchest/rpc/client"
+	"github.com/rozum-dev/near-go-warchest/rpc/signer"
+	"github.com/rozum-dev/near-go-warchest/rpc/simnet"
+	"github.com/rozum-dev/near-go-warchest/store"
+	"github.com/rozum-dev/near-go-warchest/strategy"
+)
+
+const (
+	testPoolId  = "pool.simnet"
+	testOwnerId = "owner.simnet"
+)
+
+type simFixture struct {
+	net    *simnet.Network
+	signer *signer.Signer
+	store  *store.Store
+	runner *Runner
+}
+
+func newSimFixture(t *testing.T) *simFixture {
+	dir, err := ioutil.TempDir("", "warchest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	cfg := simnet.DefaultConfig()
+	cfg.BlockTime = 0
+	net := simnet.New(cfg)
+	pub, err := simnet.WriteKey(dir, cfg.ChainId, testOwnerId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	net.AddAccount(testOwnerId, common.NearAmount(1000), pub)
+	net.AddPool(testPoolId, testOwnerId, "ed25519:"+testPoolId)
+	net.Deposit(testPoolId, testOwnerId, common.NearAmount(100))
+	srv := httptest.NewServer(net)
+	t.Cleanup(srv.Close)
+
+	client := nearapi.NewClientWithContext(context.Background(), srv.URL)
+	st, err := store.Open(filepath.Join(dir, "state.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := signer.NewSigner(client, dir, cfg.ChainId)
+	r := NewRunner(client, testPoolId, []string{testOwnerId}, NewNativeExecutor(s, st), strategy.StakeAll{}, Options{Store: st})
+	return &simFixture{net: net, signer: s, store: st, runner: r}
+}
+
+// sign signs a call of method with 1 NEAR and stores it as pending without sending it.
+func (f *simFixture) sign(t *testing.T, method string) *signer.SignedTx {
+	tx, err := f.signer.SignFunctionCall(context.Background(), testOwnerId, testPoolId, method, map[string]string{"amount": common.NearAmount(1).Yocto()}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = f.store.AddPendingTx(testPoolId, store.PendingTx{
+		Hash:        tx.Hash,
+		SignerId:    testOwnerId,
+		Method:      method,
+		Amount:      common.NearAmount(1),
+		PublicKey:   tx.PublicKey,
+		Nonce:       tx.Nonce,
+		BlockHeight: tx.BlockHeight,
+		Time:        time.Now(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tx
+}
+
+func (f *simFixture) resolve() bool {
+	return f.runner.resolvePending(context.Background(), &rpc.SubscrResult{
+		LatestBlockHeight: f.net.Height(),
+		EpochStartHeight:  f.net.EpochStartHeight(),
+		Protocol:          &nearapi.ProtocolConfigResult{TransactionValidityPeriod: 1000},
+	})
+}
+
+func TestPendingTxExpiresWithItsBlockHash(t *testing.T) {
+	f := newSimFixture(t)
+	f.sign(t, "stake")
+
+	f.net.Advance(1000)
+	if f.resolve() {
+		t.Fatal("resolved a transaction that can still be included")
+	}
+	if n := len(f.store.Pool(testPoolId).PendingTxs); n != 1 {
+		t.Fatalf("%d pending transactions, want 1", n)
+	}
+
+	f.net.Advance(10)
+	if !f.resolve() {
+		t.Fatal("an expired transaction is still pending")
+	}
+	st := f.store.Pool(testPoolId)
+	if len(st.PendingTxs) != 0 || len(st.Actions) != 0 {
+		t.Errorf("expired transaction left %d pending and %d actions", len(st.PendingTxs), len(st.Actions))
+	}
+}
+
+func TestPendingTxSupersededByLaterNonce(t *testing.T) {
+	f := newSimFixture(t)
+	f.sign(t, "stake")
+	if _, err := f.signer.FunctionCall(context.Background(), testOwnerId, testPoolId, "ping", map[string]string{}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if !f.resolve() {
+		t.Fatal("a transaction whose nonce was used is still pending")
+	}
+	if n := len(f.store.Pool(testPoolId).PendingTxs); n != 0 {
+		t.Errorf("%d pending transactions, want 0", n)
+	}
+}
+
+func TestPendingTxSucceeded(t *testing.T) {
+	f := newSimFixture(t)
+	tx := f.sign(t, "stake")
+	if _, err := f.signer.Send(context.Background(), tx.Data); err != nil {
+		t.Fatal(err)
+	}
+	if !f.resolve() {
+		t.Fatal("an executed transaction is still pending")
+	}
+	st := f.store.Pool(testPoolId)
+	if len(st.PendingTxs) != 0 {
+		t.Errorf("%d pending transactions, want 0", len(st.PendingTxs))
+	}
+	actions := st.Actions[f.net.EpochStartHeight()]
+	if len(actions) != 1 || actions[0].TxHash != tx.Hash || actions[0].Method != "stake" {
+		t.Errorf("actions %+v, want the stake %s", actions, tx.Hash)
+	}
+}
+
+func TestPendingUnstakeSucceededLocksTheBalance(t *testing.T) {
+	f := newSimFixture(t)
+	if _, err := f.signer.FunctionCall(context.Background(), testOwnerId, testPoolId, "stake", map[string]string{"amount": common.NearAmount(10).Yocto()}, nil); err != nil {
+		t.Fatal(err)
+	}
+	tx := f.sign(t, "unstake")
+	if _, err := f.signer.Send(context.Background(), tx.Data); err != nil {
+		t.Fatal(err)
+	}
+	f.runner.epochHeight = 7
+	if !f.resolve() {
+		t.Fatal("an executed transaction is still pending")
+	}
+	want := store.Unlock{Epoch: 7 + numEpochsToUnlock}
+	if u := f.runner.unlocks[testOwnerId]; u != want {
+		t.Errorf("unlock %+v, want %+v", u, want)
+	}
+	// A restarted runner still waits for the same epoch
+	if u := f.store.Pool(testPoolId).Unlocks[testOwnerId]; u != want {
+		t.Errorf("stored unlock %+v, want %+v", u, want)
+	}
+}
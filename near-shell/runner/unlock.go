@@ -0,0 +1,117 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/rozum-dev/near-go-warchest/common"
+	"github.com/rozum-dev/near-go-warchest/rpc"
+	"github.com/rozum-dev/near-go-warchest/services/notifier"
+	prom "github.com/rozum-dev/near-go-warchest/services/prometheus"
+	"github.com/rozum-dev/near-go-warchest/store"
+)
+
+// Epochs before unstaked tokens can be withdrawn, as in the staking pool contract
+const numEpochsToUnlock = 4
+
+// epoch returns the number of the epoch. Nodes which do not send it get a
+// number counted in epoch lengths, which only works for comparing epochs.
+func epoch(res *rpc.SubscrResult) int64 {
+	if res.EpochHeight > 0 {
+		return res.EpochHeight
+	}
+	if res.EpochLength > 0 {
+		return res.EpochStartHeight / int64(res.EpochLength)
+	}
+	return 0
+}
+
+func (r *Runner) setUnlock(delegatorId string, u store.Unlock) {
+	r.unlocks[delegatorId] = u
+	if r.store == nil {
+		return
+	}
+	if err := r.store.SetUnlock(r.poolId, delegatorId, u); err != nil {
+		log.Printf("Failed to save state: %s\n", err)
+	}
+}
+
+func (r *Runner) removeUnlock(delegatorId string) {
+	if _, ok := r.unlocks[delegatorId]; !ok {
+		return
+	}
+	delete(r.unlocks, delegatorId)
+	if r.store == nil {
+		return
+	}
+	if err := r.store.RemoveUnlock(r.poolId, delegatorId); err != nil {
+		log.Printf("Failed to save state: %s\n", err)
+	}
+}
+
+// unstaked locks the whole unstaked balance of the delegator again.
+func (r *Runner) unstaked(delegatorId string) {
+	r.setUnlock(delegatorId, store.Unlock{
+		Epoch:     r.epochHeight + numEpochsToUnlock,
+		Estimated: r.epochHeight == 0,
+	})
+}
+
+// checkUnlocks updates when the unstaked balances can be withdrawn. The pool
+// is asked once the unlock epoch is reached, for balances the warchest did not
+// unstake, and on every tick for available balances, which an unstake from
+// outside the warchest locks again.
+func (r *Runner) checkUnlocks(ctx context.Context, m *prom.PoolMetrics) {
+	r.withdrawable = make(map[string]common.Amount)
+	for _, delegatorId := range r.delegatorIds {
+		gauge := m.LockedUntilEpochGauge.WithLabelValues(delegatorId)
+		u, tracked := r.unlocks[delegatorId]
+		if r.delegatorUnStakedBalance[delegatorId].IsZero() {
+			r.removeUnlock(delegatorId)
+			gauge.Set(0)
+			continue
+		}
+		if tracked && !u.Available && r.epochHeight < u.Epoch {
+			gauge.Set(float64(u.Epoch))
+			continue
+		}
+		available, err := r.client.IsAccountUnstakedBalanceAvailable(ctx, r.poolId, delegatorId)
+		if err != nil {
+			log.Println(err)
+			continue
+		}
+		if !available {
+			// Unstaked before the warchest knew, so at most the full delay
+			// is left, or the epoch was guessed wrong
+			next := r.epochHeight + numEpochsToUnlock
+			if tracked && !u.Available {
+				next = r.epochHeight + 1
+			}
+			log.Printf("%s: unstaked balance %s is locked, estimated until epoch %d\n", delegatorId, r.delegatorUnStakedBalance[delegatorId], next)
+			r.setUnlock(delegatorId, store.Unlock{Epoch: next, Estimated: true})
+			gauge.Set(float64(next))
+			continue
+		}
+		gauge.Set(0)
+		r.withdrawable[delegatorId] = r.delegatorUnStakedBalance[delegatorId]
+		if u.Available {
+			continue
+		}
+		msg := fmt.Sprintf("unstaked balance %s of %s can be withdrawn", r.delegatorUnStakedBalance[delegatorId], delegatorId)
+		log.Printf("%s: %s\n", r.poolId, msg)
+		r.notify(ctx, notifier.UnstakeUnlocked, msg)
+		r.setUnlock(delegatorId, store.Unlock{Epoch: r.epochHeight, Available: true})
+	}
+}
+
+// lockedUntil returns the unlock epochs of the balances which are still locked.
+func (r *Runner) lockedUntil() map[string]int64 {
+	out := make(map[string]int64)
+	for delegatorId, u := range r.unlocks {
+		if !u.Available && !r.delegatorUnStakedBalance[delegatorId].IsZero() {
+			out[delegatorId] = u.Epoch
+		}
+	}
+	return out
+}
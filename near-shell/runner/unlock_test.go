@@ -0,0 +1,80 @@
+package runner
+
+import (
+	"context"
+	"testing"
+
+	"github.com/rozum-dev/near-go-warchest/common"
+	"github.com/rozum-dev/near-go-warchest/rpc/simnet"
+	"github.com/rozum-dev/near-go-warchest/services/notifier"
+	prom "github.com/rozum-dev/near-go-warchest/services/prometheus"
+)
+
+type recordingNotifier struct {
+	events []notifier.Event
+}
+
+func (n *recordingNotifier) Notify(ctx context.Context, e notifier.Event) error {
+	n.events = append(n.events, e)
+	return nil
+}
+
+func (n *recordingNotifier) count(kind string) int {
+	var c int
+	for _, e := range n.events {
+		if e.Kind == kind {
+			c++
+		}
+	}
+	return c
+}
+
+func TestCheckUnlocksAsksThePoolAgain(t *testing.T) {
+	f := newSimFixture(t)
+	alerts := &recordingNotifier{}
+	r := f.runner
+	r.notifier = alerts
+	m := prom.NewPromMetrics().Pool(testPoolId)
+	ctx := context.Background()
+	check := func() {
+		_, unstaked := f.net.PoolBalance(testPoolId, testOwnerId)
+		r.delegatorUnStakedBalance[testOwnerId] = unstaked
+		r.epochHeight = f.net.EpochStartHeight() / simnet.DefaultConfig().EpochLength
+		r.checkUnlocks(ctx, m)
+	}
+
+	// The deposit is available right away and is reported once
+	check()
+	check()
+	if got := r.withdrawable[testOwnerId]; got.Cmp(common.NearAmount(100)) != 0 {
+		t.Fatalf("withdrawable %s, want 100 NEAR", got)
+	}
+	if n := alerts.count(notifier.UnstakeUnlocked); n != 1 {
+		t.Errorf("%d unlock alerts, want 1", n)
+	}
+
+	// An unstake from outside the warchest locks the balance again
+	for _, method := range []string{"stake", "unstake"} {
+		args := map[string]string{"amount": common.NearAmount(10).Yocto()}
+		if _, err := f.signer.FunctionCall(ctx, testOwnerId, testPoolId, method, args, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	check()
+	if len(r.withdrawable) != 0 {
+		t.Errorf("withdrawable %v after an unstake", r.withdrawable)
+	}
+	if u := r.unlocks[testOwnerId]; u.Available {
+		t.Errorf("unlock %+v is still available", u)
+	}
+
+	// The next alert comes when the new unstake unlocks
+	f.net.AdvanceEpochs(numEpochsToUnlock)
+	check()
+	if _, ok := r.withdrawable[testOwnerId]; !ok {
+		t.Error("balance not withdrawable after the unstaking delay")
+	}
+	if n := alerts.count(notifier.UnstakeUnlocked); n != 2 {
+		t.Errorf("%d unlock alerts, want 2", n)
+	}
+}
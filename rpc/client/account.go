@@ -0,0 +1,32 @@
+package nearapi
+
+import (
+	"context"
+
+	"github.com/rozum-dev/near-go-warchest/common"
+)
+
+type AccountResult struct {
+	// Liquid balance
+	Amount common.Amount `json:"amount"`
+	// Balance locked as validator stake
+	Locked       common.Amount `json:"locked"`
+	CodeHash     string        `json:"code_hash"`
+	StorageUsage uint64        `json:"storage_usage"`
+	BlockHeight  uint64        `json:"block_height"`
+	BlockHash    string        `json:"block_hash"`
+}
+
+// ViewAccount returns the balances of an account at the final block.
+func (c *Client) ViewAccount(ctx context.Context, accountId string) (*AccountResult, error) {
+	var r AccountResult
+	err := c.call(ctx, "query", map[string]string{
+		"request_type": "view_account",
+		"finality":     "final",
+		"account_id":   accountId,
+	}, &r)
+	if err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
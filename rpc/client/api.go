@@ -4,32 +4,28 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
-	"fmt"
+	"errors"
 	"io/ioutil"
 	"log"
 	"net"
 	"net/http"
-	"strings"
 	"time"
 )
 
 type StatusResult struct {
-	Status struct {
-		Version struct {
-			Version string `json:"version"`
-			Build   string `json:"build"`
-		} `json:"version"`
-		ChainId string `json:"chain_id"`
-		RpcAddr string `json:"rpc_addr"`
-		//Validators []string `json:"validators"`
-		SyncInfo struct {
-			LatestBlockHash   string `json:"latest_block_hash"`
-			LatestBlockHeight uint64 `json:"latest_block_height"`
-			LatestStateRoot   string `json:"latest_state_root"`
-			LatestBlockTime   string `json:"latest_block_time"`
-			Syncing           bool   `json:"syncing"`
-		} `json:"sync_info"`
-	} `json:"result_status"`
+	Version struct {
+		Version string `json:"version"`
+		Build   string `json:"build"`
+	} `json:"version"`
+	ChainId  string `json:"chain_id"`
+	RpcAddr  string `json:"rpc_addr"`
+	SyncInfo struct {
+		LatestBlockHash   string `json:"latest_block_hash"`
+		LatestBlockHeight uint64 `json:"latest_block_height"`
+		LatestStateRoot   string `json:"latest_state_root"`
+		LatestBlockTime   string `json:"latest_block_time"`
+		Syncing           bool   `json:"syncing"`
+	} `json:"sync_info"`
 }
 
 type Validator struct {
@@ -38,42 +34,46 @@ type Validator struct {
 	Stake     string `json:"stake"`
 }
 
-type ValidatorsResult struct {
-	Validators struct {
-		CurrentValidators []struct {
-			Validator
-			IsSlashed         bool  `json:"is_slashed"`
-			Shards            []int `json:"shards"`
-			NumProducedBlocks int64 `json:"num_produced_blocks"`
-			NumExpectedBlocks int64 `json:"num_expected_blocks"`
-		} `json:"current_validators"`
-		NextValidators []struct {
-			Validator
-			Shards []int `json:"shards"`
-		} `json:"next_validators"`
-		CurrentProposals []struct {
-			Validator
-		} `json:"current_proposals"`
-		EpochStartHeight int64 `json:"epoch_start_height"`
-		PrevEpochKickOut []struct {
-			AccountId string                            `json:"account_id"`
-			Reason    map[string]map[string]interface{} `json:"reason"`
-		} `json:"prev_epoch_kickout"`
-	} `json:"result_validators"`
+type CurrentValidator struct {
+	Validator
+	IsSlashed         bool  `json:"is_slashed"`
+	Shards            []int `json:"shards"`
+	NumProducedBlocks int64 `json:"num_produced_blocks"`
+	NumExpectedBlocks int64 `json:"num_expected_blocks"`
+	NumProducedChunks int64 `json:"num_produced_chunks"`
+	NumExpectedChunks int64 `json:"num_expected_chunks"`
+}
+
+type NextValidator struct {
+	Validator
+	Shards []int `json:"shards"`
 }
 
-type Result struct {
-	StatusResult
-	ValidatorsResult
+type ValidatorsResult struct {
+	CurrentValidators []CurrentValidator `json:"current_validators"`
+	NextValidators    []NextValidator    `json:"next_validators"`
+	CurrentProposals  []Validator        `json:"current_proposals"`
+	EpochStartHeight  int64              `json:"epoch_start_height"`
+	// Number of the epoch, 0 from nodes which do not send it
+	EpochHeight      int64     `json:"epoch_height"`
+	PrevEpochKickOut []Kickout `json:"prev_epoch_kickout"`
 }
 
+// Timeout of one request to one endpoint
+const requestTimeout = 10 * time.Second
+
+// broadcast_tx_commit waits for the transaction, the node gives up after 10s
+const commitTimeout = 30 * time.Second
+
+// Client sends requests to the healthiest of its endpoints and fails over to
+// the next one when an endpoint cannot answer.
 type Client struct {
 	httpClient *http.Client
-	Endpoint   string
+	endpoints  *endpoints
 	ctx        context.Context
 }
 
-func NewClientWithContext(ctx context.Context, endpoint string) *Client {
+func NewClientWithContext(ctx context.Context, urls ...string) *Client {
 	var netTransport = &http.Transport{
 		Dial: (&net.Dialer{
 			Timeout: 12 * time.Second,
@@ -81,86 +81,157 @@ func NewClientWithContext(ctx context.Context, endpoint string) *Client {
 		TLSHandshakeTimeout: 12 * time.Second,
 	}
 
-	timeout := time.Duration(12 * time.Second)
 	httpClient := &http.Client{
-		Timeout:   timeout,
 		Transport: netTransport,
 	}
-	return &Client{
-		Endpoint:   endpoint,
+	c := &Client{
+		endpoints:  newEndpoints(urls),
 		httpClient: httpClient,
 		ctx:        ctx,
 	}
+	if len(urls) > 1 {
+		go c.probe()
+	}
+	return c
 }
 
-func (c *Client) do(method string, params interface{}) (string, error) {
-	payload, err := json.Marshal(map[string]string{
-		"query": method,
-	})
+// Status returns the status of the node.
+func (c *Client) Status(ctx context.Context) (*StatusResult, error) {
+	var r StatusResult
+	if err := c.call(ctx, "status", []string{}, &r); err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
 
-	if params != "" {
-		type Payload struct {
-			JsonRPC string      `json:"jsonrpc"`
-			Id      string      `json:"id"`
-			Method  string      `json:"method"`
-			Params  interface{} `json:"params"`
+// Validators returns the validators of the epoch with the given block, or of
+// the latest epoch when blockHeight is 0.
+func (c *Client) Validators(ctx context.Context, blockHeight uint64) (*ValidatorsResult, error) {
+	params := []interface{}{nil}
+	if blockHeight != 0 {
+		params = []interface{}{blockHeight}
+	}
+	var r ValidatorsResult
+	if err := c.call(ctx, "validators", params, &r); err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+type rpcResponse struct {
+	Result json.RawMessage `json:"result"`
+	Error  *RPCError       `json:"error"`
+}
+
+// call runs a JSON-RPC method and decodes its result into out.
+func (c *Client) call(ctx context.Context, method string, params interface{}, out interface{}) error {
+	body, err := c.do(ctx, method, params)
+	if err != nil {
+		return err
+	}
+	var r rpcResponse
+	if err := json.Unmarshal(body, &r); err != nil {
+		return &DecodeError{Method: method, Err: err}
+	}
+	if r.Error != nil {
+		r.Error.Method = method
+		return r.Error
+	}
+	if len(r.Result) == 0 {
+		return &DecodeError{Method: method, Err: errors.New("no result")}
+	}
+	if err := json.Unmarshal(r.Result, out); err != nil {
+		return &DecodeError{Method: method, Err: err}
+	}
+	return nil
+}
+
+// do sends the request to the endpoints from the healthiest one until one of
+// them answers. A node that is not synced or lacks the block counts as not
+// answering.
+func (c *Client) do(ctx context.Context, method string, params interface{}) ([]byte, error) {
+	payload, err := json.Marshal(struct {
+		JsonRPC string      `json:"jsonrpc"`
+		Id      string      `json:"id"`
+		Method  string      `json:"method"`
+		Params  interface{} `json:"params"`
+	}{
+		JsonRPC: "2.0",
+		Id:      "dontcare",
+		Method:  method,
+		Params:  params,
+	})
+	if err != nil {
+		return nil, err
+	}
+	var lastErr error
+	for i, ep := range c.endpoints.ordered() {
+		if i > 0 {
+			log.Printf("%s, failing over to %s\n", lastErr, Redact(ep.url))
 		}
-		p := Payload{
-			JsonRPC: "2.0",
-			Id:      "dontcare",
-			Method:  method,
-			Params:  params,
+		body, err := c.send(ctx, ep, method, payload)
+		if err == nil {
+			return body, nil
 		}
-		payload, err = json.Marshal(p)
-		if err != nil {
-			log.Println(err)
+		lastErr = err
+		if ctx.Err() != nil {
+			return nil, &TransportError{Endpoint: Redact(ep.url), Err: ctx.Err()}
 		}
 	}
-	ctx, cancel := context.WithTimeout(c.ctx, 10*time.Second)
+	if lastErr == nil {
+		return nil, &TransportError{Err: errors.New("no rpc endpoints")}
+	}
+	return nil, lastErr
+}
+
+// send makes one request to ep and records how it went.
+func (c *Client) send(ctx context.Context, ep *endpoint, method string, payload []byte) ([]byte, error) {
+	timeout := requestTimeout
+	if method == "broadcast_tx_commit" {
+		timeout = commitTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
 	defer cancel()
 
-	req, err := http.NewRequest("POST", c.Endpoint, bytes.NewBuffer(payload))
-	req.WithContext(ctx)
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Add("User-Agent", "Go-Warchest Bot")
+	name := Redact(ep.url)
+	req, err := http.NewRequest("POST", ep.url, bytes.NewReader(payload))
 	if err != nil {
-		log.Fatalln(err)
+		return nil, &TransportError{Endpoint: name, Err: err}
 	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Add("User-Agent", "Go-Warchest Bot")
 
+	start := time.Now()
 	r, err := c.httpClient.Do(req)
 	if err != nil {
-		log.Printf("Failed to connect to %s\n", c.Endpoint)
-		return "", nil
+		c.endpoints.record(ep, 0, true)
+		return nil, &TransportError{Endpoint: name, Err: err}
 	}
 	defer r.Body.Close()
 	body, err := ioutil.ReadAll(r.Body)
 	if err != nil {
-		log.Printf("Failed to read body: %s\n", c.Endpoint)
-		return "", nil
+		c.endpoints.record(ep, 0, true)
+		return nil, &TransportError{Endpoint: name, Err: err}
 	}
-
-	//log.Printf("body %s\n %s\n", method, body)
-
-	return string(body), nil
-}
-
-func (c *Client) Get(method string, variables interface{}) (*Result, error) {
-	res, err := c.do(method, variables)
-	if err != nil {
-		return nil, err
+	var res rpcResponse
+	decoded := json.Unmarshal(body, &res) == nil
+	// Newer nodes answer JSON-RPC errors with 4xx and 5xx
+	if r.StatusCode/100 != 2 && (!decoded || res.Error == nil) {
+		c.endpoints.record(ep, 0, true)
+		if len(body) > 200 {
+			body = body[:200]
+		}
+		return nil, &HTTPError{Endpoint: name, StatusCode: r.StatusCode, Status: r.Status, Body: string(body)}
 	}
-	var d Result
-
-	//log.Printf("res1 %s\n %s\n", method, res)
-
-	res = strings.Replace(res, "result", fmt.Sprintf("%s_%s", "result", method), -1)
-	r := bytes.NewReader([]byte(res))
-	err2 := json.NewDecoder(r).Decode(&d)
-	if err2 != nil {
-		log.Println(err2)
-		return nil, err2
+	if decoded && res.Error != nil && endpointCauses[res.Error.Cause] {
+		c.endpoints.record(ep, 0, true)
+		res.Error.Method = method
+		return nil, res.Error
 	}
-	//log.Printf("res2 %s\n %s\n", method, res)
-
-	return &d, nil
+	c.endpoints.record(ep, time.Since(start), false)
+	if method == "status" {
+		c.endpoints.recordHeight(ep, body)
+	}
+	return body, nil
 }
@@ -0,0 +1,179 @@
+package nearapi
+
+import (
+	"encoding/json"
+	"log"
+	"net/url"
+	"sort"
+	"sync"
+	"time"
+)
+
+// How often every endpoint is asked for its status when there are several
+const probeInterval = 30 * time.Second
+
+// Weight of the newest sample in the latency and error rate averages
+const healthSmoothing = 0.2
+
+type endpoint struct {
+	url     string
+	latency time.Duration
+	// Share of failed requests, smoothed
+	errorRate float64
+	height    uint64
+	// Requests sent, to leave the averages alone until the first sample
+	requests int64
+}
+
+// EndpointHealth is how well an RPC endpoint is doing. Health is 1 for a fast
+// endpoint at the best known height without errors and falls towards 0 with
+// latency, errors and block-height lag.
+type EndpointHealth struct {
+	URL       string        `json:"url"`
+	Health    float64       `json:"health"`
+	Latency   time.Duration `json:"latency"`
+	ErrorRate float64       `json:"error_rate"`
+	Height    uint64        `json:"height"`
+	Lag       uint64        `json:"lag"`
+}
+
+// endpoints scores the endpoints of a client.
+type endpoints struct {
+	mu   sync.Mutex
+	list []*endpoint
+}
+
+func newEndpoints(urls []string) *endpoints {
+	e := &endpoints{}
+	for _, u := range urls {
+		e.list = append(e.list, &endpoint{url: u})
+	}
+	return e
+}
+
+func (e *endpoints) maxHeight() uint64 {
+	var h uint64
+	for _, ep := range e.list {
+		if ep.height > h {
+			h = ep.height
+		}
+	}
+	return h
+}
+
+func (e *endpoints) health(ep *endpoint, maxHeight uint64) EndpointHealth {
+	h := EndpointHealth{
+		URL:       Redact(ep.url),
+		Latency:   ep.latency,
+		ErrorRate: ep.errorRate,
+		Height:    ep.height,
+	}
+	if ep.height < maxHeight {
+		h.Lag = maxHeight - ep.height
+	}
+	h.Health = (1 - ep.errorRate) / (1 + float64(h.Lag)/10) / (1 + ep.latency.Seconds())
+	return h
+}
+
+// ordered returns the endpoints from the healthiest one. Equally healthy
+// endpoints keep the configured order.
+func (e *endpoints) ordered() []*endpoint {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	maxHeight := e.maxHeight()
+	scores := make(map[*endpoint]float64)
+	for _, ep := range e.list {
+		scores[ep] = e.health(ep, maxHeight).Health
+	}
+	out := make([]*endpoint, len(e.list))
+	copy(out, e.list)
+	sort.SliceStable(out, func(i, j int) bool {
+		return scores[out[i]] > scores[out[j]]
+	})
+	return out
+}
+
+func (e *endpoints) record(ep *endpoint, latency time.Duration, failed bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	sample := 0.0
+	if failed {
+		sample = 1
+	}
+	if ep.requests == 0 {
+		ep.errorRate = sample
+		if !failed {
+			ep.latency = latency
+		}
+	} else {
+		ep.errorRate += healthSmoothing * (sample - ep.errorRate)
+		if !failed {
+			ep.latency += time.Duration(healthSmoothing * float64(latency-ep.latency))
+		}
+	}
+	ep.requests++
+}
+
+// recordHeight reads the latest block height out of a status response.
+func (e *endpoints) recordHeight(ep *endpoint, body []byte) {
+	var r struct {
+		Result struct {
+			SyncInfo struct {
+				LatestBlockHeight uint64 `json:"latest_block_height"`
+			} `json:"sync_info"`
+		} `json:"result"`
+	}
+	if err := json.Unmarshal(body, &r); err != nil || r.Result.SyncInfo.LatestBlockHeight == 0 {
+		return
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	ep.height = r.Result.SyncInfo.LatestBlockHeight
+}
+
+func (e *endpoints) snapshot() []EndpointHealth {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	maxHeight := e.maxHeight()
+	var out []EndpointHealth
+	for _, ep := range e.list {
+		out = append(out, e.health(ep, maxHeight))
+	}
+	return out
+}
+
+// Health returns the health of every endpoint in the configured order.
+func (c *Client) Health() []EndpointHealth {
+	return c.endpoints.snapshot()
+}
+
+// probe asks every endpoint for its status, so the ones not in use are
+// scored too.
+func (c *Client) probe() {
+	ticker := time.NewTicker(probeInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			for _, ep := range c.endpoints.list {
+				if _, err := c.send(c.ctx, ep, "status", []byte(`{"jsonrpc":"2.0","id":"dontcare","method":"status","params":[]}`)); err != nil {
+					log.Printf("RPC endpoint health check: %s\n", err)
+				}
+			}
+		case <-c.ctx.Done():
+			return
+		}
+	}
+}
+
+// Redact drops the credentials and query of an endpoint URL, which may hold
+// an API key.
+func Redact(endpoint string) string {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return "invalid url"
+	}
+	u.User = nil
+	u.RawQuery = ""
+	return u.String()
+}
@@ -0,0 +1,145 @@
+package nearapi
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestEndpointHealth(t *testing.T) {
+	e := newEndpoints([]string{"http://fast", "http://slow", "http://failing", "http://behind", "http://new"})
+	fast, slow, failing, behind := e.list[0], e.list[1], e.list[2], e.list[3]
+	e.record(fast, 100*time.Millisecond, false)
+	e.record(slow, 2*time.Second, false)
+	e.record(failing, 100*time.Millisecond, false)
+	e.record(failing, 0, true)
+	e.record(behind, 100*time.Millisecond, false)
+	fast.height, slow.height, failing.height, behind.height = 1000, 1000, 1000, 950
+
+	health := make(map[string]EndpointHealth)
+	for _, h := range e.snapshot() {
+		health[h.URL] = h
+	}
+	tests := []struct {
+		url       string
+		health    float64
+		errorRate float64
+		lag       uint64
+	}{
+		{"http://fast", 1 / 1.1, 0, 0},
+		{"http://slow", 1 / 3.0, 0, 0},
+		// The failure moves the error rate a fifth of the way
+		{"http://failing", 0.8 / 1.1, 0.2, 0},
+		{"http://behind", 1 / 6.0 / 1.1, 0, 50},
+		// Not asked yet
+		{"http://new", 1 / 101.0, 0, 1000},
+	}
+	for _, tt := range tests {
+		h := health[tt.url]
+		if diff := h.Health - tt.health; diff > 1e-9 || diff < -1e-9 {
+			t.Errorf("%s: health %f, want %f", tt.url, h.Health, tt.health)
+		}
+		if diff := h.ErrorRate - tt.errorRate; diff > 1e-9 || diff < -1e-9 {
+			t.Errorf("%s: error rate %f, want %f", tt.url, h.ErrorRate, tt.errorRate)
+		}
+		if h.Lag != tt.lag {
+			t.Errorf("%s: lag %d, want %d", tt.url, h.Lag, tt.lag)
+		}
+	}
+
+	var order []string
+	for _, ep := range e.ordered() {
+		order = append(order, ep.url)
+	}
+	want := []string{"http://fast", "http://failing", "http://slow", "http://behind", "http://new"}
+	if fmt.Sprint(order) != fmt.Sprint(want) {
+		t.Errorf("order %v, want %v", order, want)
+	}
+}
+
+func TestEndpointsKeepConfiguredOrder(t *testing.T) {
+	e := newEndpoints([]string{"http://a", "http://b", "http://c"})
+	for i, ep := range e.ordered() {
+		if ep != e.list[i] {
+			t.Fatalf("endpoint %d is %s", i, ep.url)
+		}
+	}
+}
+
+// rpcServer answers every request with body and counts the requests.
+func rpcServer(t *testing.T, status int, body string) (*httptest.Server, *int) {
+	var n int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n++
+		w.WriteHeader(status)
+		fmt.Fprint(w, body)
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &n
+}
+
+const statusBody = `{"jsonrpc":"2.0","id":"dontcare","result":{"chain_id":"simnet","sync_info":{"latest_block_height":42}}}`
+
+func TestFailover(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		body   string
+	}{
+		{"not synced", http.StatusOK, `{"jsonrpc":"2.0","id":"dontcare","error":{"name":"HANDLER_ERROR","cause":{"name":"NOT_SYNCED_YET"},"code":-32000,"message":"Server error"}}`},
+		{"unknown block", http.StatusBadRequest, `{"jsonrpc":"2.0","id":"dontcare","error":{"name":"HANDLER_ERROR","cause":{"name":"UNKNOWN_BLOCK","info":{}},"code":-32000,"message":"Server error"}}`},
+		{"no synced blocks", http.StatusOK, `{"jsonrpc":"2.0","id":"dontcare","error":{"name":"HANDLER_ERROR","cause":{"name":"NO_SYNCED_BLOCKS"},"code":-32000,"message":"Server error"}}`},
+		{"http error", http.StatusBadGateway, `<html>bad gateway</html>`},
+	}
+	for _, tt := range tests {
+		bad, badRequests := rpcServer(t, tt.status, tt.body)
+		good, goodRequests := rpcServer(t, http.StatusOK, statusBody)
+		c := NewClientWithContext(context.Background(), bad.URL, good.URL)
+		for i := 0; i < 2; i++ {
+			res, err := c.Status(context.Background())
+			if err != nil {
+				t.Fatalf("%s: %v", tt.name, err)
+			}
+			if res.SyncInfo.LatestBlockHeight != 42 {
+				t.Errorf("%s: height %d", tt.name, res.SyncInfo.LatestBlockHeight)
+			}
+		}
+		// The failed endpoint goes to the back after the first request
+		if *badRequests != 1 || *goodRequests != 2 {
+			t.Errorf("%s: %d requests to the failing endpoint, %d to the good one", tt.name, *badRequests, *goodRequests)
+		}
+	}
+}
+
+func TestNoFailoverOnRequestErrors(t *testing.T) {
+	first, firstRequests := rpcServer(t, http.StatusOK, `{"jsonrpc":"2.0","id":"dontcare","error":{"name":"HANDLER_ERROR","cause":{"name":"UNKNOWN_ACCOUNT"},"code":-32000,"message":"Server error"}}`)
+	second, secondRequests := rpcServer(t, http.StatusOK, statusBody)
+	c := NewClientWithContext(context.Background(), first.URL, second.URL)
+	_, err := c.ViewAccount(context.Background(), "nobody.near")
+	if !IsCause(err, UnknownAccount) {
+		t.Errorf("error %v, want %s", err, UnknownAccount)
+	}
+	if *firstRequests != 1 || *secondRequests != 0 {
+		t.Errorf("%d and %d requests, want only the first endpoint", *firstRequests, *secondRequests)
+	}
+}
+
+func TestAllEndpointsNotSynced(t *testing.T) {
+	body := `{"jsonrpc":"2.0","id":"dontcare","error":{"name":"HANDLER_ERROR","cause":{"name":"NOT_SYNCED_YET"},"code":-32000,"message":"Server error"}}`
+	a, _ := rpcServer(t, http.StatusOK, body)
+	b, _ := rpcServer(t, http.StatusOK, body)
+	c := NewClientWithContext(context.Background(), a.URL, b.URL)
+	_, err := c.Status(context.Background())
+	if !IsCause(err, NotSyncedYet) {
+		t.Fatalf("error %v, want %s", err, NotSyncedYet)
+	}
+	if IsUnreachable(err) {
+		t.Error("a node that answered is reported unreachable")
+	}
+	if got := err.Error(); got != "status: HANDLER_ERROR NOT_SYNCED_YET: Server error" {
+		t.Errorf("error %q", got)
+	}
+}
@@ -0,0 +1,142 @@
+package nearapi
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// Causes of JSON-RPC errors, as named by nearcore
+const (
+	UnknownBlock       = "UNKNOWN_BLOCK"
+	UnknownEpoch       = "UNKNOWN_EPOCH"
+	UnknownChunk       = "UNKNOWN_CHUNK"
+	UnknownAccount     = "UNKNOWN_ACCOUNT"
+	UnknownAccessKey   = "UNKNOWN_ACCESS_KEY"
+	UnknownTransaction = "UNKNOWN_TRANSACTION"
+	InvalidAccount     = "INVALID_ACCOUNT"
+	InvalidTransaction = "INVALID_TRANSACTION"
+	NoContractCode     = "NO_CONTRACT_CODE"
+	ContractExecution  = "CONTRACT_EXECUTION_ERROR"
+	NotSyncedYet       = "NOT_SYNCED_YET"
+	NoSyncedBlocks     = "NO_SYNCED_BLOCKS"
+	TimeoutError       = "TIMEOUT_ERROR"
+	ParseError         = "PARSE_ERROR"
+	MethodNotFound     = "METHOD_NOT_FOUND"
+	InternalError      = "INTERNAL_ERROR"
+)
+
+// Causes that only tell about the node that answered, such as a node which
+// is still syncing. Another endpoint may answer the same request.
+var endpointCauses = map[string]bool{
+	NotSyncedYet:   true,
+	NoSyncedBlocks: true,
+	UnknownBlock:   true,
+}
+
+// TransportError means the endpoint could not be reached or the response
+// could not be read.
+type TransportError struct {
+	Endpoint string
+	Err      error
+}
+
+func (e *TransportError) Error() string {
+	return fmt.Sprintf("%s: %v", e.Endpoint, e.Err)
+}
+
+func (e *TransportError) Unwrap() error {
+	return e.Err
+}
+
+// HTTPError is a response with an unexpected HTTP status and no JSON-RPC error.
+type HTTPError struct {
+	Endpoint   string
+	StatusCode int
+	Status     string
+	Body       string
+}
+
+func (e *HTTPError) Error() string {
+	if e.Body == "" {
+		return fmt.Sprintf("%s: http %s", e.Endpoint, e.Status)
+	}
+	return fmt.Sprintf("%s: http %s: %s", e.Endpoint, e.Status, e.Body)
+}
+
+// RPCError is the error object of a JSON-RPC response. Name is the kind of
+// error, such as HANDLER_ERROR, and Cause the exact reason, such as
+// UNKNOWN_BLOCK. Older nodes send only the code, message and data.
+type RPCError struct {
+	Method  string          `json:"-"`
+	Name    string          `json:"name"`
+	Cause   string          `json:"-"`
+	Code    int             `json:"code"`
+	Message string          `json:"message"`
+	Data    json.RawMessage `json:"data"`
+	Info    json.RawMessage `json:"-"`
+}
+
+func (e *RPCError) Error() string {
+	msg := fmt.Sprintf("%s: %s", e.Method, e.Message)
+	if e.Cause != "" {
+		msg = fmt.Sprintf("%s: %s %s", e.Method, e.Name, e.Cause)
+	}
+	if len(e.Info) > 0 && string(e.Info) != "null" && string(e.Info) != "{}" {
+		return fmt.Sprintf("%s %s", msg, e.Info)
+	}
+	if len(e.Data) > 0 && string(e.Data) != "null" {
+		return fmt.Sprintf("%s: %s", msg, e.Data)
+	}
+	if e.Cause != "" && e.Message != "" {
+		return fmt.Sprintf("%s: %s", msg, e.Message)
+	}
+	return msg
+}
+
+// UnmarshalJSON reads the cause, which is {"name": ..., "info": ...}.
+func (e *RPCError) UnmarshalJSON(data []byte) error {
+	type rpcError RPCError
+	var v struct {
+		rpcError
+		Cause struct {
+			Name string          `json:"name"`
+			Info json.RawMessage `json:"info"`
+		} `json:"cause"`
+	}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	*e = RPCError(v.rpcError)
+	e.Cause = v.Cause.Name
+	e.Info = v.Cause.Info
+	return nil
+}
+
+// DecodeError means the response is not the JSON the method returns.
+type DecodeError struct {
+	Method string
+	Err    error
+}
+
+func (e *DecodeError) Error() string {
+	return fmt.Sprintf("%s: decode response: %v", e.Method, e.Err)
+}
+
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
+
+// IsCause reports whether err is a JSON-RPC error with the given cause.
+func IsCause(err error, cause string) bool {
+	var e *RPCError
+	return errors.As(err, &e) && e.Cause == cause
+}
+
+// IsUnreachable reports whether err means no endpoint could answer, as
+// opposed to a node answering with an error.
+func IsUnreachable(err error) bool {
+	var te *TransportError
+	var he *HTTPError
+	return errors.As(err, &te) || errors.As(err, &he)
+}
@@ -0,0 +1,108 @@
+package nearapi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestRPCErrorDecoding(t *testing.T) {
+	tests := []struct {
+		name  string
+		body  string
+		cause string
+		text  string
+	}{
+		{
+			name:  "structured error with info",
+			body:  `{"name":"HANDLER_ERROR","cause":{"name":"UNKNOWN_ACCOUNT","info":{"requested_account_id":"nobody.near","block_height":42}},"code":-32000,"message":"Server error","data":"account nobody.near does not exist while viewing"}`,
+			cause: UnknownAccount,
+			text:  `view_account: HANDLER_ERROR UNKNOWN_ACCOUNT {"requested_account_id":"nobody.near","block_height":42}`,
+		},
+		{
+			name:  "structured error with empty info uses the data",
+			body:  `{"name":"HANDLER_ERROR","cause":{"name":"UNKNOWN_BLOCK","info":{}},"code":-32000,"message":"Server error","data":"DB Not Found Error"}`,
+			cause: UnknownBlock,
+			text:  `view_account: HANDLER_ERROR UNKNOWN_BLOCK: "DB Not Found Error"`,
+		},
+		{
+			name:  "structured error with only a message",
+			body:  `{"name":"REQUEST_VALIDATION_ERROR","cause":{"name":"PARSE_ERROR"},"code":-32700,"message":"Parse error"}`,
+			cause: ParseError,
+			text:  `view_account: REQUEST_VALIDATION_ERROR PARSE_ERROR: Parse error`,
+		},
+		{
+			name: "older node",
+			body: `{"code":-32000,"message":"Server error","data":"Timeout"}`,
+			text: `view_account: Server error: "Timeout"`,
+		},
+		{
+			name: "older node without data",
+			body: `{"code":-32601,"message":"Method not found","data":null}`,
+			text: `view_account: Method not found`,
+		},
+	}
+	for _, tt := range tests {
+		var e RPCError
+		if err := json.Unmarshal([]byte(tt.body), &e); err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		e.Method = "view_account"
+		if e.Cause != tt.cause {
+			t.Errorf("%s: cause %q, want %q", tt.name, e.Cause, tt.cause)
+		}
+		if got := e.Error(); got != tt.text {
+			t.Errorf("%s: %q, want %q", tt.name, got, tt.text)
+		}
+		wrapped := fmt.Errorf("pool.near: %w", &e)
+		if tt.cause != "" && !IsCause(wrapped, tt.cause) {
+			t.Errorf("%s: IsCause(%s) is false through a wrap", tt.name, tt.cause)
+		}
+		if IsUnreachable(wrapped) {
+			t.Errorf("%s: an answer is reported unreachable", tt.name)
+		}
+	}
+}
+
+func TestErrorKinds(t *testing.T) {
+	tests := []struct {
+		err         error
+		unreachable bool
+		text        string
+	}{
+		{&TransportError{Endpoint: "https://rpc.near.org", Err: context.DeadlineExceeded}, true, "https://rpc.near.org: context deadline exceeded"},
+		{&HTTPError{Endpoint: "https://rpc.near.org", StatusCode: 502, Status: "502 Bad Gateway"}, true, "https://rpc.near.org: http 502 Bad Gateway"},
+		{&HTTPError{Endpoint: "https://rpc.near.org", StatusCode: 429, Status: "429 Too Many Requests", Body: "slow down"}, true, "https://rpc.near.org: http 429 Too Many Requests: slow down"},
+		{&DecodeError{Method: "status", Err: errors.New("no result")}, false, "status: decode response: no result"},
+	}
+	for _, tt := range tests {
+		if got := IsUnreachable(fmt.Errorf("wrapped: %w", tt.err)); got != tt.unreachable {
+			t.Errorf("%s: unreachable %v, want %v", tt.text, got, tt.unreachable)
+		}
+		if got := tt.err.Error(); got != tt.text {
+			t.Errorf("%q, want %q", got, tt.text)
+		}
+	}
+	if !errors.Is(&TransportError{Err: context.Canceled}, context.Canceled) {
+		t.Error("a transport error does not unwrap")
+	}
+}
+
+func TestCallDecodesErrors(t *testing.T) {
+	srv, _ := rpcServer(t, 200, `{"jsonrpc":"2.0","id":"dontcare","error":{"name":"HANDLER_ERROR","cause":{"name":"UNKNOWN_ACCESS_KEY","info":{}},"code":-32000,"message":"Server error"}}`)
+	c := NewClientWithContext(context.Background(), srv.URL)
+	_, err := c.ViewAccessKey(context.Background(), "owner.near", "ed25519:key")
+	if !IsCause(err, UnknownAccessKey) {
+		t.Errorf("error %v, want %s", err, UnknownAccessKey)
+	}
+
+	srv, _ = rpcServer(t, 200, `{"jsonrpc":"2.0","id":"dontcare"}`)
+	c = NewClientWithContext(context.Background(), srv.URL)
+	var d *DecodeError
+	if _, err := c.Status(context.Background()); !errors.As(err, &d) {
+		t.Errorf("error %v, want a decode error", err)
+	}
+}
@@ -0,0 +1,254 @@
+package nearapi
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/rozum-dev/near-go-warchest/common"
+)
+
+// Kickout reason kinds, as named by nearcore
+const (
+	NotEnoughBlocksKind       = "NotEnoughBlocks"
+	NotEnoughChunksKind       = "NotEnoughChunks"
+	NotEnoughStakeKind        = "NotEnoughStake"
+	DidNotGetASeatKind        = "DidNotGetASeat"
+	UnstakedKind              = "Unstaked"
+	SlashedKind               = "Slashed"
+	ProtocolVersionTooOldKind = "ProtocolVersionTooOld"
+)
+
+// KickoutKinds lists every known kickout reason.
+var KickoutKinds = []string{
+	NotEnoughBlocksKind,
+	NotEnoughChunksKind,
+	NotEnoughStakeKind,
+	DidNotGetASeatKind,
+	UnstakedKind,
+	SlashedKind,
+	ProtocolVersionTooOldKind,
+}
+
+// KickoutReason is why a validator was kicked out of an epoch.
+type KickoutReason interface {
+	Kind() string
+	String() string
+	// Hint tells the operator how to get the seat back.
+	Hint() string
+}
+
+type NotEnoughBlocks struct {
+	Produced int64 `json:"produced"`
+	Expected int64 `json:"expected"`
+}
+
+func (NotEnoughBlocks) Kind() string { return NotEnoughBlocksKind }
+
+func (r NotEnoughBlocks) String() string {
+	return fmt.Sprintf("produced %d of %d expected blocks", r.Produced, r.Expected)
+}
+
+func (NotEnoughBlocks) Hint() string {
+	return "check that the node is synced, online and not overloaded, then restake to send a new proposal"
+}
+
+type NotEnoughChunks struct {
+	Produced int64 `json:"produced"`
+	Expected int64 `json:"expected"`
+}
+
+func (NotEnoughChunks) Kind() string { return NotEnoughChunksKind }
+
+func (r NotEnoughChunks) String() string {
+	return fmt.Sprintf("produced %d of %d expected chunks", r.Produced, r.Expected)
+}
+
+func (NotEnoughChunks) Hint() string {
+	return "check that the node tracks its shards and keeps up with the network, then restake to send a new proposal"
+}
+
+type NotEnoughStake struct {
+	Stake     common.Amount `json:"stake_u128"`
+	Threshold common.Amount `json:"threshold_u128"`
+}
+
+func (NotEnoughStake) Kind() string { return NotEnoughStakeKind }
+
+func (r NotEnoughStake) String() string {
+	return fmt.Sprintf("stake %s is below the threshold %s", r.Stake, r.Threshold)
+}
+
+func (r NotEnoughStake) Hint() string {
+	return fmt.Sprintf("stake %s more", r.Threshold.Sub(r.Stake))
+}
+
+// UnmarshalJSON accepts the stake and threshold fields of older nodes too.
+func (r *NotEnoughStake) UnmarshalJSON(data []byte) error {
+	var v struct {
+		StakeU128     *common.Amount `json:"stake_u128"`
+		ThresholdU128 *common.Amount `json:"threshold_u128"`
+		Stake         *common.Amount `json:"stake"`
+		Threshold     *common.Amount `json:"threshold"`
+	}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	for _, a := range []*common.Amount{v.StakeU128, v.Stake} {
+		if a != nil {
+			r.Stake = *a
+		}
+	}
+	for _, a := range []*common.Amount{v.ThresholdU128, v.Threshold} {
+		if a != nil {
+			r.Threshold = *a
+		}
+	}
+	return nil
+}
+
+type DidNotGetASeat struct{}
+
+func (DidNotGetASeat) Kind() string { return DidNotGetASeatKind }
+
+func (DidNotGetASeat) String() string {
+	return "the stake was below the seat price"
+}
+
+func (DidNotGetASeat) Hint() string {
+	return "stake above the expected seat price before the end of the epoch"
+}
+
+type Unstaked struct{}
+
+func (Unstaked) Kind() string { return UnstakedKind }
+
+func (Unstaked) String() string {
+	return "the validator unstaked"
+}
+
+func (Unstaked) Hint() string {
+	return "stake again to send a new proposal"
+}
+
+type Slashed struct{}
+
+func (Slashed) Kind() string { return SlashedKind }
+
+func (Slashed) String() string {
+	return "the validator was slashed"
+}
+
+func (Slashed) Hint() string {
+	return "make sure the validator key is not used by two nodes at once, then stake again"
+}
+
+type ProtocolVersionTooOld struct {
+	Version        int `json:"version"`
+	NetworkVersion int `json:"network_version"`
+}
+
+func (ProtocolVersionTooOld) Kind() string { return ProtocolVersionTooOldKind }
+
+func (r ProtocolVersionTooOld) String() string {
+	return fmt.Sprintf("the node runs protocol version %d, the network runs %d", r.Version, r.NetworkVersion)
+}
+
+func (r ProtocolVersionTooOld) Hint() string {
+	return fmt.Sprintf("upgrade the node to protocol version %d", r.NetworkVersion)
+}
+
+// UnknownKickout is a reason this version does not know about.
+type UnknownKickout struct {
+	Name string          `json:"name"`
+	Data json.RawMessage `json:"data,omitempty"`
+}
+
+// Kind is "Unknown" for a reason whose name could not be read.
+func (r UnknownKickout) Kind() string {
+	if r.Name == "" {
+		return "Unknown"
+	}
+	return r.Name
+}
+
+func (r UnknownKickout) String() string {
+	if len(r.Data) == 0 {
+		return r.Kind()
+	}
+	return fmt.Sprintf("%s %s", r.Kind(), r.Data)
+}
+
+func (UnknownKickout) Hint() string {
+	return "check the validator logs"
+}
+
+// Kickout is an entry of prev_epoch_kickout.
+type Kickout struct {
+	AccountId string        `json:"account_id"`
+	Reason    KickoutReason `json:"reason"`
+}
+
+// UnmarshalJSON decodes the reason, which is a plain string for the variants
+// without fields, such as "Slashed", and an object with one key otherwise,
+// such as {"NotEnoughBlocks": {"produced": 1, "expected": 2}}.
+func (k *Kickout) UnmarshalJSON(data []byte) error {
+	var v struct {
+		AccountId string          `json:"account_id"`
+		Reason    json.RawMessage `json:"reason"`
+	}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	k.AccountId = v.AccountId
+	k.Reason = decodeReason(v.Reason)
+	return nil
+}
+
+// decodeReason keeps a reason it cannot read as an UnknownKickout with the raw
+// JSON, so that a new or changed variant does not fail the whole validators
+// response.
+func decodeReason(raw json.RawMessage) KickoutReason {
+	var name string
+	var fields json.RawMessage
+	if err := json.Unmarshal(raw, &name); err != nil {
+		var obj map[string]json.RawMessage
+		if err := json.Unmarshal(raw, &obj); err != nil || len(obj) != 1 {
+			return UnknownKickout{Data: raw}
+		}
+		for n, f := range obj {
+			name, fields = n, f
+		}
+	}
+	var reason KickoutReason
+	var err error
+	switch name {
+	case NotEnoughBlocksKind:
+		var r NotEnoughBlocks
+		err = json.Unmarshal(fields, &r)
+		reason = r
+	case NotEnoughChunksKind:
+		var r NotEnoughChunks
+		err = json.Unmarshal(fields, &r)
+		reason = r
+	case NotEnoughStakeKind:
+		var r NotEnoughStake
+		err = json.Unmarshal(fields, &r)
+		reason = r
+	case ProtocolVersionTooOldKind:
+		var r ProtocolVersionTooOld
+		err = json.Unmarshal(fields, &r)
+		reason = r
+	case DidNotGetASeatKind:
+		reason = DidNotGetASeat{}
+	case UnstakedKind:
+		reason = Unstaked{}
+	case SlashedKind:
+		reason = Slashed{}
+	default:
+		reason = UnknownKickout{Name: name, Data: fields}
+	}
+	if err != nil {
+		return UnknownKickout{Name: name, Data: fields}
+	}
+	return reason
+}
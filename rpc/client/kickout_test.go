@@ -0,0 +1,76 @@
+package nearapi
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/rozum-dev/near-go-warchest/common"
+)
+
+func TestKickoutUnmarshal(t *testing.T) {
+	stake, _ := common.ParseAmount("1000")
+	threshold, _ := common.ParseAmount("2000")
+	tests := []struct {
+		reason string
+		want   KickoutReason
+	}{
+		{`{"NotEnoughBlocks": {"produced": 1, "expected": 2}}`, NotEnoughBlocks{Produced: 1, Expected: 2}},
+		{`{"NotEnoughChunks": {"produced": 3, "expected": 4}}`, NotEnoughChunks{Produced: 3, Expected: 4}},
+		{`{"NotEnoughStake": {"stake_u128": "1000", "threshold_u128": "2000"}}`, NotEnoughStake{Stake: stake, Threshold: threshold}},
+		// Nodes before the _u128 fields
+		{`{"NotEnoughStake": {"stake": "1000", "threshold": "2000"}}`, NotEnoughStake{Stake: stake, Threshold: threshold}},
+		{`{"ProtocolVersionTooOld": {"version": 44, "network_version": 45}}`, ProtocolVersionTooOld{Version: 44, NetworkVersion: 45}},
+		{`"DidNotGetASeat"`, DidNotGetASeat{}},
+		{`"Unstaked"`, Unstaked{}},
+		{`"Slashed"`, Slashed{}},
+		// Unknown variants keep their data
+		{`"Jailed"`, UnknownKickout{Name: "Jailed"}},
+		{`{"Jailed": {"until": 5}}`, UnknownKickout{Name: "Jailed", Data: json.RawMessage(`{"until": 5}`)}},
+		// Malformed reasons are kept as raw JSON
+		{`{"NotEnoughBlocks": {"produced": "many"}}`, UnknownKickout{Name: "NotEnoughBlocks", Data: json.RawMessage(`{"produced": "many"}`)}},
+		{`{"NotEnoughStake": {"stake_u128": "-1"}}`, UnknownKickout{Name: "NotEnoughStake", Data: json.RawMessage(`{"stake_u128": "-1"}`)}},
+		{`"NotEnoughBlocks"`, UnknownKickout{Name: "NotEnoughBlocks"}},
+		{`{"Slashed": {}, "Unstaked": {}}`, UnknownKickout{Data: json.RawMessage(`{"Slashed": {}, "Unstaked": {}}`)}},
+		{`42`, UnknownKickout{Data: json.RawMessage(`42`)}},
+	}
+	for _, tt := range tests {
+		var k Kickout
+		data := `{"account_id": "pool.near", "reason": ` + tt.reason + `}`
+		if err := json.Unmarshal([]byte(data), &k); err != nil {
+			t.Errorf("%s: %v", tt.reason, err)
+			continue
+		}
+		if k.AccountId != "pool.near" {
+			t.Errorf("%s: account %q", tt.reason, k.AccountId)
+		}
+		if !reflect.DeepEqual(k.Reason, tt.want) {
+			t.Errorf("%s: decoded %#v, want %#v", tt.reason, k.Reason, tt.want)
+		}
+	}
+}
+
+func TestKickoutListKeepsOddEntries(t *testing.T) {
+	var v struct {
+		Kickouts []Kickout `json:"prev_epoch_kickout"`
+	}
+	data := `{"prev_epoch_kickout": [
+		{"account_id": "a.near", "reason": {"Future": [1, 2]}},
+		{"account_id": "b.near", "reason": "Slashed"}
+	]}`
+	if err := json.Unmarshal([]byte(data), &v); err != nil {
+		t.Fatal(err)
+	}
+	if len(v.Kickouts) != 2 {
+		t.Fatalf("%d kickouts, want 2", len(v.Kickouts))
+	}
+	if got := v.Kickouts[0].Reason.String(); got != "Future [1, 2]" {
+		t.Errorf("unknown reason %q", got)
+	}
+	if got := v.Kickouts[1].Reason.Kind(); got != SlashedKind {
+		t.Errorf("second reason %q", got)
+	}
+	if got := (UnknownKickout{Data: json.RawMessage(`42`)}).Kind(); got != "Unknown" {
+		t.Errorf("kind of a nameless reason %q", got)
+	}
+}
@@ -0,0 +1,47 @@
+package nearapi
+
+import "context"
+
+type ProtocolConfigResult struct {
+	ProtocolVersion                 int      `json:"protocol_version"`
+	ChainId                         string   `json:"chain_id"`
+	EpochLength                     int64    `json:"epoch_length"`
+	NumBlockProducerSeats           int      `json:"num_block_producer_seats"`
+	AvgHiddenValidatorSeatsPerShard []int    `json:"avg_hidden_validator_seats_per_shard"`
+	MinimumStakeRatio               [2]int64 `json:"minimum_stake_ratio"`
+	// Percent of expected blocks and chunks a validator must produce
+	BlockProducerKickoutThreshold int `json:"block_producer_kickout_threshold"`
+	ChunkProducerKickoutThreshold int `json:"chunk_producer_kickout_threshold"`
+	// Blocks after its block hash during which a transaction can be included
+	TransactionValidityPeriod int64 `json:"transaction_validity_period"`
+}
+
+// NumSeats is the number of block producer seats including hidden validator seats.
+func (p *ProtocolConfigResult) NumSeats() int {
+	n := p.NumBlockProducerSeats
+	for _, s := range p.AvgHiddenValidatorSeatsPerShard {
+		n += s
+	}
+	return n
+}
+
+// ProtocolConfig returns the protocol config at the final block.
+func (c *Client) ProtocolConfig(ctx context.Context) (*ProtocolConfigResult, error) {
+	var r ProtocolConfigResult
+	err := c.call(ctx, "EXPERIMENTAL_protocol_config", map[string]string{"finality": "final"}, &r)
+	if err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+// GenesisConfig returns the genesis config, which has the same fields as the
+// protocol config at genesis. Older nodes have no EXPERIMENTAL_protocol_config.
+func (c *Client) GenesisConfig(ctx context.Context) (*ProtocolConfigResult, error) {
+	var r ProtocolConfigResult
+	err := c.call(ctx, "EXPERIMENTAL_genesis_config", []string{}, &r)
+	if err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
@@ -0,0 +1,66 @@
+package nearapi
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+)
+
+type CallFunctionResult struct {
+	Result      []byte   `json:"result"`
+	Logs        []string `json:"logs"`
+	BlockHeight uint64   `json:"block_height"`
+	BlockHash   string   `json:"block_hash"`
+	Error       string   `json:"error"`
+}
+
+// UnmarshalJSON decodes "result" as an array of numbers, which is how the RPC returns the bytes.
+func (r *CallFunctionResult) UnmarshalJSON(data []byte) error {
+	var raw struct {
+		Result      []int    `json:"result"`
+		Logs        []string `json:"logs"`
+		BlockHeight uint64   `json:"block_height"`
+		BlockHash   string   `json:"block_hash"`
+		Error       string   `json:"error"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	r.Result = make([]byte, len(raw.Result))
+	for i, b := range raw.Result {
+		r.Result[i] = byte(b)
+	}
+	r.Logs = raw.Logs
+	r.BlockHeight = raw.BlockHeight
+	r.BlockHash = raw.BlockHash
+	r.Error = raw.Error
+	return nil
+}
+
+// CallFunction calls a view method of a contract at the final block and
+// decodes the JSON value it returns into out.
+func (c *Client) CallFunction(ctx context.Context, accountId, method string, args interface{}, out interface{}) error {
+	argsJson, err := json.Marshal(args)
+	if err != nil {
+		return err
+	}
+	var r CallFunctionResult
+	err = c.call(ctx, "query", map[string]string{
+		"request_type": "call_function",
+		"finality":     "final",
+		"account_id":   accountId,
+		"method_name":  method,
+		"args_base64":  base64.StdEncoding.EncodeToString(argsJson),
+	}, &r)
+	if err != nil {
+		return err
+	}
+	// Older nodes put the error into the result
+	if r.Error != "" {
+		return &RPCError{Method: accountId + "." + method, Name: "HANDLER_ERROR", Cause: ContractExecution, Message: r.Error}
+	}
+	if err := json.Unmarshal(r.Result, out); err != nil {
+		return &DecodeError{Method: accountId + "." + method, Err: err}
+	}
+	return nil
+}
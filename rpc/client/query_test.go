@@ -0,0 +1,131 @@
+package nearapi
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/rozum-dev/near-go-warchest/common"
+)
+
+// viewServer answers view calls with result and records the last request params.
+func viewServer(t *testing.T, result string) (*httptest.Server, *map[string]string) {
+	params := make(map[string]string)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		var req struct {
+			Params map[string]string `json:"params"`
+		}
+		if err := json.Unmarshal(body, &req); err != nil {
+			t.Errorf("request %s: %v", body, err)
+		}
+		params = req.Params
+		fmt.Fprintf(w, `{"jsonrpc":"2.0","id":"dontcare","result":%s}`, result)
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &params
+}
+
+// bytesResult encodes a view result as the RPC does, as an array of numbers.
+func bytesResult(value string) string {
+	var nums []int
+	for _, b := range []byte(value) {
+		nums = append(nums, int(b))
+	}
+	out, _ := json.Marshal(nums)
+	return fmt.Sprintf(`{"result":%s,"logs":[],"block_height":42,"block_hash":"hash"}`, out)
+}
+
+func TestCallFunctionArgs(t *testing.T) {
+	srv, params := viewServer(t, bytesResult(`"10000000000000000000000000"`))
+	c := NewClientWithContext(context.Background(), srv.URL)
+	balance, err := c.GetAccountStakedBalance(context.Background(), "pool.near", "owner.near")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if balance.Cmp(common.NearAmount(10)) != 0 {
+		t.Errorf("balance %s, want 10 NEAR", balance)
+	}
+	want := map[string]string{
+		"request_type": "call_function",
+		"finality":     "final",
+		"account_id":   "pool.near",
+		"method_name":  "get_account_staked_balance",
+		"args_base64":  base64.StdEncoding.EncodeToString([]byte(`{"account_id":"owner.near"}`)),
+	}
+	if fmt.Sprint(*params) != fmt.Sprint(want) {
+		t.Errorf("params %v, want %v", *params, want)
+	}
+}
+
+func TestCallFunctionResults(t *testing.T) {
+	ctx := context.Background()
+	tests := []struct {
+		name  string
+		value string
+		call  func(c *Client) (interface{}, error)
+		want  string
+	}{
+		{"bool", `true`, func(c *Client) (interface{}, error) { return c.IsStakingPaused(ctx, "pool.near") }, "true"},
+		{"string", `"ed25519:key"`, func(c *Client) (interface{}, error) { return c.GetStakingKey(ctx, "pool.near") }, "ed25519:key"},
+		{"number", `12`, func(c *Client) (interface{}, error) { return c.GetNumberOfAccounts(ctx, "pool.near") }, "12"},
+		{"object", `{"numerator":10,"denominator":100}`, func(c *Client) (interface{}, error) { return c.GetRewardFeeFraction(ctx, "pool.near") }, "10/100"},
+		{"amount", `"1"`, func(c *Client) (interface{}, error) {
+			a, err := c.GetTotalStakedBalance(ctx, "pool.near")
+			return a.Yocto(), err
+		}, "1"},
+	}
+	for _, tt := range tests {
+		srv, _ := viewServer(t, bytesResult(tt.value))
+		got, err := tt.call(NewClientWithContext(ctx, srv.URL))
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if s := fmt.Sprint(got); s != tt.want {
+			t.Errorf("%s: %s, want %s", tt.name, s, tt.want)
+		}
+	}
+}
+
+func TestCallFunctionErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		result string
+		cause  string
+		decode bool
+	}{
+		{"error in the result", `{"error":"wasm execution failed with error: FunctionCallError(MethodResolveError(MethodNotFound))","logs":[],"block_height":42,"block_hash":"hash"}`, ContractExecution, false},
+		{"not json", bytesResult(`not json`), "", true},
+		{"wrong type", bytesResult(`"yes"`), "", true},
+	}
+	for _, tt := range tests {
+		srv, _ := viewServer(t, tt.result)
+		c := NewClientWithContext(context.Background(), srv.URL)
+		_, err := c.IsStakingPaused(context.Background(), "pool.near")
+		if err == nil {
+			t.Errorf("%s: no error", tt.name)
+			continue
+		}
+		if tt.cause != "" && !IsCause(err, tt.cause) {
+			t.Errorf("%s: error %v, want %s", tt.name, err, tt.cause)
+		}
+		var d *DecodeError
+		if errors.As(err, &d) != tt.decode {
+			t.Errorf("%s: error %v, decode error %v", tt.name, err, tt.decode)
+		}
+	}
+
+	// The error of a node that puts it into the RPC error
+	srv, _ := rpcServer(t, http.StatusOK, `{"jsonrpc":"2.0","id":"dontcare","error":{"name":"HANDLER_ERROR","cause":{"name":"CONTRACT_EXECUTION_ERROR","info":{"vm_error":"MethodNotFound"}},"code":-32000,"message":"Server error"}}`)
+	c := NewClientWithContext(context.Background(), srv.URL)
+	if _, err := c.GetOwnerId(context.Background(), "pool.near"); !IsCause(err, ContractExecution) {
+		t.Errorf("error %v, want %s", err, ContractExecution)
+	}
+}
@@ -0,0 +1,92 @@
+package nearapi
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/rozum-dev/near-go-warchest/common"
+)
+
+// Staking pool contract views.
+
+type accountArgs struct {
+	AccountId string `json:"account_id"`
+}
+
+func (c *Client) GetAccountStakedBalance(ctx context.Context, poolId, accountId string) (common.Amount, error) {
+	var balance common.Amount
+	err := c.CallFunction(ctx, poolId, "get_account_staked_balance", accountArgs{accountId}, &balance)
+	return balance, err
+}
+
+func (c *Client) GetAccountUnstakedBalance(ctx context.Context, poolId, accountId string) (common.Amount, error) {
+	var balance common.Amount
+	err := c.CallFunction(ctx, poolId, "get_account_unstaked_balance", accountArgs{accountId}, &balance)
+	return balance, err
+}
+
+func (c *Client) GetAccountTotalBalance(ctx context.Context, poolId, accountId string) (common.Amount, error) {
+	var balance common.Amount
+	err := c.CallFunction(ctx, poolId, "get_account_total_balance", accountArgs{accountId}, &balance)
+	return balance, err
+}
+
+func (c *Client) IsAccountUnstakedBalanceAvailable(ctx context.Context, poolId, accountId string) (bool, error) {
+	var available bool
+	err := c.CallFunction(ctx, poolId, "is_account_unstaked_balance_available", accountArgs{accountId}, &available)
+	return available, err
+}
+
+func (c *Client) GetTotalStakedBalance(ctx context.Context, poolId string) (common.Amount, error) {
+	var balance common.Amount
+	err := c.CallFunction(ctx, poolId, "get_total_staked_balance", struct{}{}, &balance)
+	return balance, err
+}
+
+func (c *Client) GetNumberOfAccounts(ctx context.Context, poolId string) (uint64, error) {
+	var n uint64
+	err := c.CallFunction(ctx, poolId, "get_number_of_accounts", struct{}{}, &n)
+	return n, err
+}
+
+// RewardFeeFraction is the share of the rewards the pool owner takes.
+type RewardFeeFraction struct {
+	Numerator   uint64 `json:"numerator"`
+	Denominator uint64 `json:"denominator"`
+}
+
+// Ratio returns the fee from 0 to 1.
+func (f RewardFeeFraction) Ratio() float64 {
+	if f.Denominator == 0 {
+		return 0
+	}
+	return float64(f.Numerator) / float64(f.Denominator)
+}
+
+func (f RewardFeeFraction) String() string {
+	return fmt.Sprintf("%d/%d", f.Numerator, f.Denominator)
+}
+
+func (c *Client) GetRewardFeeFraction(ctx context.Context, poolId string) (RewardFeeFraction, error) {
+	var fee RewardFeeFraction
+	err := c.CallFunction(ctx, poolId, "get_reward_fee_fraction", struct{}{}, &fee)
+	return fee, err
+}
+
+func (c *Client) GetOwnerId(ctx context.Context, poolId string) (string, error) {
+	var owner string
+	err := c.CallFunction(ctx, poolId, "get_owner_id", struct{}{}, &owner)
+	return owner, err
+}
+
+func (c *Client) GetStakingKey(ctx context.Context, poolId string) (string, error) {
+	var key string
+	err := c.CallFunction(ctx, poolId, "get_staking_key", struct{}{}, &key)
+	return key, err
+}
+
+func (c *Client) IsStakingPaused(ctx context.Context, poolId string) (bool, error) {
+	var paused bool
+	err := c.CallFunction(ctx, poolId, "is_staking_paused", struct{}{}, &paused)
+	return paused, err
+}
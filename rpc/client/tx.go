@@ -0,0 +1,114 @@
+package nearapi
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+)
+
+type AccessKeyResult struct {
+	Nonce       uint64          `json:"nonce"`
+	Permission  json.RawMessage `json:"permission"`
+	BlockHeight uint64          `json:"block_height"`
+	BlockHash   string          `json:"block_hash"`
+	Error       string          `json:"error"`
+}
+
+// ViewAccessKey returns the nonce of the key and a recent final block hash.
+func (c *Client) ViewAccessKey(ctx context.Context, accountId, publicKey string) (*AccessKeyResult, error) {
+	var r AccessKeyResult
+	err := c.call(ctx, "query", map[string]string{
+		"request_type": "view_access_key",
+		"finality":     "final",
+		"account_id":   accountId,
+		"public_key":   publicKey,
+	}, &r)
+	if err != nil {
+		return nil, err
+	}
+	// Older nodes put the error into the result
+	if r.Error != "" {
+		return nil, &RPCError{Method: "view_access_key", Name: "HANDLER_ERROR", Cause: UnknownAccessKey, Message: r.Error}
+	}
+	return &r, nil
+}
+
+type ExecutionStatus struct {
+	SuccessValue     *string         `json:"SuccessValue,omitempty"`
+	SuccessReceiptId *string         `json:"SuccessReceiptId,omitempty"`
+	Failure          json.RawMessage `json:"Failure,omitempty"`
+}
+
+type ExecutionOutcome struct {
+	Id      string `json:"id"`
+	Outcome struct {
+		Logs       []string        `json:"logs"`
+		GasBurnt   uint64          `json:"gas_burnt"`
+		Status     ExecutionStatus `json:"status"`
+		ExecutorId string          `json:"executor_id"`
+		ReceiptIds []string        `json:"receipt_ids"`
+	} `json:"outcome"`
+}
+
+type FinalExecutionOutcome struct {
+	Status      ExecutionStatus `json:"status"`
+	Transaction struct {
+		Hash       string `json:"hash"`
+		SignerId   string `json:"signer_id"`
+		ReceiverId string `json:"receiver_id"`
+	} `json:"transaction"`
+	TransactionOutcome ExecutionOutcome   `json:"transaction_outcome"`
+	ReceiptsOutcome    []ExecutionOutcome `json:"receipts_outcome"`
+}
+
+// Failed reports whether the transaction or any of its receipts failed.
+func (o *FinalExecutionOutcome) Failed() bool {
+	if len(o.Status.Failure) > 0 {
+		return true
+	}
+	for _, r := range o.ReceiptsOutcome {
+		if len(r.Outcome.Status.Failure) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// BroadcastTxCommit sends a Borsh-serialized SignedTransaction and waits until it is executed.
+func (c *Client) BroadcastTxCommit(ctx context.Context, signedTx []byte) (*FinalExecutionOutcome, error) {
+	var r FinalExecutionOutcome
+	err := c.call(ctx, "broadcast_tx_commit", []string{base64.StdEncoding.EncodeToString(signedTx)}, &r)
+	if err != nil {
+		return nil, err
+	}
+	if r.Failed() {
+		return &r, fmt.Errorf("transaction %s failed: %s", r.Transaction.Hash, r.failure())
+	}
+	return &r, nil
+}
+
+// TxStatus returns the outcome of a transaction sent by senderId.
+func (c *Client) TxStatus(ctx context.Context, hash, senderId string) (*FinalExecutionOutcome, error) {
+	var r FinalExecutionOutcome
+	err := c.call(ctx, "tx", []string{hash, senderId}, &r)
+	if err != nil {
+		return nil, err
+	}
+	if r.Failed() {
+		return &r, fmt.Errorf("transaction %s failed: %s", r.Transaction.Hash, r.failure())
+	}
+	return &r, nil
+}
+
+func (o *FinalExecutionOutcome) failure() string {
+	if len(o.Status.Failure) > 0 {
+		return string(o.Status.Failure)
+	}
+	for _, r := range o.ReceiptsOutcome {
+		if len(r.Outcome.Status.Failure) > 0 {
+			return string(r.Outcome.Status.Failure)
+		}
+	}
+	return ""
+}
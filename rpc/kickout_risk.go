@@ -0,0 +1,77 @@
+package rpc
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/rozum-dev/near-go-warchest/services/notifier"
+)
+
+// Used when the protocol config has no kickout thresholds
+const defaultKickoutThreshold = 90
+
+// No projection before this share of the epoch, the counts are too small
+const minEpochProgress = 0.05
+
+// The recent rate needs this many expected blocks or chunks, otherwise one
+// missed block would look like a stopped node
+const minRecentExpected = 10
+
+// production projects the end-of-epoch ratio of produced to expected blocks
+// or chunks. The recent rate is applied to the rest of the epoch, so a node
+// that just stopped shows up long before the ratio of the whole epoch drops.
+type production struct {
+	name               string
+	epoch              int64
+	produced, expected int64
+	warnedEpoch        int64
+}
+
+// project returns the projected percentage at the end of the epoch, or false
+// while it is too early to tell.
+func (p *production) project(epoch, produced, expected int64, progress float64) (float64, bool) {
+	rate := 0.0
+	if expected > 0 {
+		rate = float64(produced) / float64(expected)
+	}
+	if p.epoch != epoch || expected < p.expected || produced < p.produced {
+		p.epoch, p.produced, p.expected = epoch, produced, expected
+	} else if expected-p.expected >= minRecentExpected {
+		rate = float64(produced-p.produced) / float64(expected-p.expected)
+		p.produced, p.expected = produced, expected
+	}
+	if expected == 0 || progress < minEpochProgress {
+		return 0, false
+	}
+	if progress > 1 {
+		progress = 1
+	}
+	total := float64(expected) / progress
+	remaining := total - float64(expected)
+	return (float64(produced) + rate*remaining) / total * 100, true
+}
+
+// checkProduction warns once per epoch when the projection of blocks or
+// chunks falls below the kickout threshold.
+func (m *Monitor) checkProduction(ctx context.Context, p *production, epoch, produced, expected int64, progress float64, threshold int) float64 {
+	projected, ok := p.project(epoch, produced, expected, progress)
+	if !ok {
+		return 0
+	}
+	if threshold <= 0 {
+		threshold = defaultKickoutThreshold
+	}
+	log.Printf("Produced %d of %d expected %s, %.1f%% projected at the end of the epoch\n", produced, expected, p.name, projected)
+	if projected >= float64(threshold) {
+		return projected
+	}
+	msg := fmt.Sprintf("produced %d of %d expected %s, %.1f%% projected at the end of the epoch, below the kickout threshold of %d%%. Check the node now",
+		produced, expected, p.name, projected, threshold)
+	log.Printf("Kickout risk: %s\n", msg)
+	if p.warnedEpoch != epoch {
+		p.warnedEpoch = epoch
+		m.notify(ctx, notifier.KickoutRisk, msg)
+	}
+	return projected
+}
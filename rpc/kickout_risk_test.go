@@ -0,0 +1,89 @@
+package rpc
+
+import (
+	"context"
+	"testing"
+
+	"github.com/rozum-dev/near-go-warchest/services/notifier"
+)
+
+type sample struct {
+	epoch, produced, expected int64
+	progress                  float64
+}
+
+func TestProductionProject(t *testing.T) {
+	tests := []struct {
+		name    string
+		samples []sample
+		// Projection after the last sample
+		want float64
+		ok   bool
+	}{
+		{"too early", []sample{{1, 5, 5, 0.01}}, 0, false},
+		{"nothing expected", []sample{{1, 0, 0, 0.5}}, 0, false},
+		{"all produced", []sample{{1, 50, 50, 0.5}}, 100, true},
+		{"first sample uses the epoch rate", []sample{{1, 40, 50, 0.5}}, 80, true},
+		{"stopped node", []sample{{1, 50, 50, 0.5}, {1, 50, 70, 0.7}}, 50, true},
+		{"recovered node", []sample{{1, 20, 50, 0.5}, {1, 40, 70, 0.7}}, 70, true},
+		{"few new blocks keep the epoch rate", []sample{{1, 50, 50, 0.5}, {1, 50, 55, 0.55}}, 50 + 50.0/55*45, true},
+		{"the rate waits for enough new blocks", []sample{{1, 50, 50, 0.5}, {1, 50, 55, 0.55}, {1, 50, 62, 0.62}}, 50, true},
+		{"new epoch starts over", []sample{{1, 50, 70, 0.7}, {2, 10, 10, 0.1}}, 100, true},
+		{"counters going back start over", []sample{{1, 50, 50, 0.5}, {1, 45, 48, 0.5}}, 93.75, true},
+		{"end of the epoch", []sample{{1, 90, 100, 1.2}}, 90, true},
+	}
+	for _, tt := range tests {
+		p := &production{name: "blocks"}
+		var got float64
+		var ok bool
+		for _, s := range tt.samples {
+			got, ok = p.project(s.epoch, s.produced, s.expected, s.progress)
+		}
+		if ok != tt.ok {
+			t.Errorf("%s: ok %v, want %v", tt.name, ok, tt.ok)
+		}
+		if diff := got - tt.want; diff > 1e-9 || diff < -1e-9 {
+			t.Errorf("%s: projected %f, want %f", tt.name, got, tt.want)
+		}
+	}
+}
+
+type recordingNotifier struct {
+	events []notifier.Event
+}
+
+func (n *recordingNotifier) Notify(ctx context.Context, e notifier.Event) error {
+	n.events = append(n.events, e)
+	return nil
+}
+
+func TestCheckProductionWarnsOncePerEpoch(t *testing.T) {
+	alerts := &recordingNotifier{}
+	m := &Monitor{poolId: "pool.near", notifier: alerts}
+	p := &production{name: "chunks"}
+	ctx := context.Background()
+	tests := []struct {
+		sample
+		threshold int
+		alerts    int
+	}{
+		// 80% projected is above a threshold of 70
+		{sample{1, 40, 50, 0.5}, 70, 0},
+		// and below the default of 90
+		{sample{1, 48, 60, 0.6}, 0, 1},
+		{sample{1, 56, 70, 0.7}, 90, 1},
+		{sample{2, 8, 10, 0.1}, 90, 2},
+		{sample{2, 30, 30, 0.3}, 90, 2},
+	}
+	for i, tt := range tests {
+		m.checkProduction(ctx, p, tt.epoch, tt.produced, tt.expected, tt.progress, tt.threshold)
+		if len(alerts.events) != tt.alerts {
+			t.Fatalf("step %d: %d alerts, want %d", i, len(alerts.events), tt.alerts)
+		}
+	}
+	for _, e := range alerts.events {
+		if e.Kind != notifier.KickoutRisk || e.PoolId != "pool.near" {
+			t.Errorf("alert %+v", e)
+		}
+	}
+}
@@ -0,0 +1,129 @@
+package rpc
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/rozum-dev/near-go-warchest/common"
+	nearapi "github.com/rozum-dev/near-go-warchest/rpc/client"
+	"github.com/rozum-dev/near-go-warchest/services/notifier"
+	prom "github.com/rozum-dev/near-go-warchest/services/prometheus"
+	"github.com/rozum-dev/near-go-warchest/store"
+)
+
+// PoolInfo is the state of the staking pool contract.
+type PoolInfo struct {
+	TotalStakedBalance common.Amount             `json:"total_staked_balance"`
+	NumberOfAccounts   uint64                    `json:"number_of_accounts"`
+	RewardFee          nearapi.RewardFeeFraction `json:"reward_fee_fraction"`
+	OwnerId            string                    `json:"owner_id"`
+	StakingKey         string                    `json:"staking_key"`
+	Paused             bool                      `json:"staking_paused"`
+}
+
+func (m *Monitor) poolInfo(ctx context.Context) (*PoolInfo, error) {
+	var info PoolInfo
+	var err error
+	if info.TotalStakedBalance, err = m.client.GetTotalStakedBalance(ctx, m.poolId); err != nil {
+		return nil, err
+	}
+	if info.NumberOfAccounts, err = m.client.GetNumberOfAccounts(ctx, m.poolId); err != nil {
+		return nil, err
+	}
+	if info.RewardFee, err = m.client.GetRewardFeeFraction(ctx, m.poolId); err != nil {
+		return nil, err
+	}
+	if info.OwnerId, err = m.client.GetOwnerId(ctx, m.poolId); err != nil {
+		return nil, err
+	}
+	if info.StakingKey, err = m.client.GetStakingKey(ctx, m.poolId); err != nil {
+		return nil, err
+	}
+	if info.Paused, err = m.client.IsStakingPaused(ctx, m.poolId); err != nil {
+		return nil, err
+	}
+	return &info, nil
+}
+
+// checkPool exports the state of the pool contract and alerts when the
+// staking key or the reward fee change, or staking gets paused. A node that
+// does not sign with the staking key produces nothing, and a paused pool
+// proposes no stake, so either loses the seat. The last known state is kept
+// while the views fail, and in the store across restarts.
+func (m *Monitor) checkPool(ctx context.Context, metrics *prom.PoolMetrics) *PoolInfo {
+	info, err := m.poolInfo(ctx)
+	if err != nil {
+		log.Printf("Failed to read the pool contract: %s\n", err)
+		return m.pool
+	}
+	metrics.PoolTotalStakeGauge.Set(info.TotalStakedBalance.Near())
+	metrics.PoolAccountsGauge.Set(float64(info.NumberOfAccounts))
+	metrics.PoolRewardFeeGauge.Set(info.RewardFee.Ratio())
+	if info.Paused {
+		metrics.PoolPausedGauge.Set(1)
+	} else {
+		metrics.PoolPausedGauge.Set(0)
+	}
+
+	prev := m.pool
+	if prev == nil {
+		prev = m.savedPool()
+	}
+	if prev != nil && (prev.OwnerId != info.OwnerId || prev.StakingKey != info.StakingKey) {
+		metrics.PoolInfoGauge.DeleteLabelValues(prev.OwnerId, prev.StakingKey)
+	}
+	metrics.PoolInfoGauge.WithLabelValues(info.OwnerId, info.StakingKey).Set(1)
+	if prev != nil {
+		if prev.OwnerId != info.OwnerId {
+			log.Printf("Pool owner changed from %s to %s\n", prev.OwnerId, info.OwnerId)
+		}
+		if prev.StakingKey != info.StakingKey {
+			m.notify(ctx, notifier.StakingKeyChanged, fmt.Sprintf("staking key changed from %s to %s. The node must run with the new key or it produces no blocks", prev.StakingKey, info.StakingKey))
+		}
+		if prev.RewardFee != info.RewardFee {
+			m.notify(ctx, notifier.RewardFeeChanged, fmt.Sprintf("reward fee changed from %s to %s", prev.RewardFee, info.RewardFee))
+		}
+	}
+	if info.Paused && (prev == nil || !prev.Paused) {
+		m.notify(ctx, notifier.StakingPaused, "staking is paused, the pool proposes no stake and loses its seat until the owner resumes it")
+	}
+	if prev == nil || prev.OwnerId != info.OwnerId || prev.StakingKey != info.StakingKey || prev.RewardFee != info.RewardFee || prev.Paused != info.Paused {
+		m.savePool(info)
+	}
+	m.pool = info
+	return info
+}
+
+// savedPool returns the state of the pool contract seen before a restart,
+// without the balances, or nil.
+func (m *Monitor) savedPool() *PoolInfo {
+	if m.store == nil {
+		return nil
+	}
+	c := m.store.Pool(m.poolId).Contract
+	if c == nil {
+		return nil
+	}
+	return &PoolInfo{
+		OwnerId:    c.OwnerId,
+		StakingKey: c.StakingKey,
+		RewardFee:  nearapi.RewardFeeFraction{Numerator: c.RewardFee[0], Denominator: c.RewardFee[1]},
+		Paused:     c.Paused,
+	}
+}
+
+func (m *Monitor) savePool(info *PoolInfo) {
+	if m.store == nil {
+		return
+	}
+	err := m.store.SetContract(m.poolId, store.Contract{
+		OwnerId:    info.OwnerId,
+		StakingKey: info.StakingKey,
+		RewardFee:  [2]uint64{info.RewardFee.Numerator, info.RewardFee.Denominator},
+		Paused:     info.Paused,
+	})
+	if err != nil {
+		log.Printf("Failed to save state: %s\n", err)
+	}
+}
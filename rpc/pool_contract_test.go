@@ -0,0 +1,129 @@
+package rpc
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/rozum-dev/near-go-warchest/services/notifier"
+	prom "github.com/rozum-dev/near-go-warchest/services/prometheus"
+	"github.com/rozum-dev/near-go-warchest/store"
+)
+
+func openStore(t *testing.T) *store.Store {
+	dir, err := ioutil.TempDir("", "warchest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	st, err := store.Open(filepath.Join(dir, "state.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return st
+}
+
+func TestCheckPoolAlerts(t *testing.T) {
+	type step struct {
+		// Views changed before the check
+		views map[string]string
+		// Restart the monitor on the same store before the check
+		restart bool
+		alerts  []string
+	}
+	tests := []struct {
+		name  string
+		steps []step
+	}{
+		{"nothing before the first state", []step{{}, {}}},
+		{"staking key", []step{
+			{},
+			{views: map[string]string{"view:get_staking_key": `"ed25519:other"`}, alerts: []string{notifier.StakingKeyChanged}},
+			{},
+		}},
+		{"reward fee", []step{
+			{},
+			{views: map[string]string{"view:get_reward_fee_fraction": `{"numerator":20,"denominator":100}`}, alerts: []string{notifier.RewardFeeChanged}},
+			{},
+		}},
+		{"paused", []step{
+			{},
+			{views: map[string]string{"view:is_staking_paused": `true`}, alerts: []string{notifier.StakingPaused}},
+			{},
+			{views: map[string]string{"view:is_staking_paused": `false`}},
+			{views: map[string]string{"view:is_staking_paused": `true`}, alerts: []string{notifier.StakingPaused}},
+		}},
+		{"paused from the start", []step{
+			{views: map[string]string{"view:is_staking_paused": `true`}, alerts: []string{notifier.StakingPaused}},
+		}},
+		{"owner change", []step{
+			{},
+			{views: map[string]string{"view:get_owner_id": `"new.near"`}},
+		}},
+		{"changes across a restart", []step{
+			{},
+			{
+				views: map[string]string{
+					"view:get_staking_key":         `"ed25519:other"`,
+					"view:get_reward_fee_fraction": `{"numerator":20,"denominator":100}`,
+				},
+				restart: true,
+				alerts:  []string{notifier.StakingKeyChanged, notifier.RewardFeeChanged},
+			},
+			{restart: true},
+		}},
+		{"still paused after a restart", []step{
+			{views: map[string]string{"view:is_staking_paused": `true`}, alerts: []string{notifier.StakingPaused}},
+			{restart: true},
+		}},
+	}
+	for _, tt := range tests {
+		node, client := newFakeNode(t)
+		st := openStore(t)
+		metrics := prom.NewPromMetrics().Pool(testPoolId)
+		var m *Monitor
+		var alerts *recordingNotifier
+		for i, s := range tt.steps {
+			if m == nil || s.restart {
+				alerts = &recordingNotifier{}
+				m = NewMonitor(client, testPoolId, time.Millisecond, alerts, st)
+			}
+			for name, result := range s.views {
+				node.set(name, result)
+			}
+			before := len(alerts.events)
+			m.checkPool(context.Background(), metrics)
+			var kinds []string
+			for _, e := range alerts.events[before:] {
+				kinds = append(kinds, e.Kind)
+			}
+			if len(kinds) != len(s.alerts) {
+				t.Errorf("%s: step %d: alerts %v, want %v", tt.name, i, kinds, s.alerts)
+				continue
+			}
+			for j := range kinds {
+				if kinds[j] != s.alerts[j] {
+					t.Errorf("%s: step %d: alerts %v, want %v", tt.name, i, kinds, s.alerts)
+					break
+				}
+			}
+		}
+	}
+}
+
+func TestCheckPoolKeepsTheLastState(t *testing.T) {
+	node, client := newFakeNode(t)
+	m := NewMonitor(client, testPoolId, time.Millisecond, &recordingNotifier{}, nil)
+	metrics := prom.NewPromMetrics().Pool(testPoolId)
+	first := m.checkPool(context.Background(), metrics)
+	if first == nil || first.StakingKey != "ed25519:key" || first.NumberOfAccounts != 3 {
+		t.Fatalf("pool %+v", first)
+	}
+	node.fail("view:get_staking_key", `{"name":"HANDLER_ERROR","cause":{"name":"UNKNOWN_BLOCK","info":{}},"code":-32000,"message":"Server error"}`)
+	if got := m.checkPool(context.Background(), metrics); got != first {
+		t.Errorf("pool %+v while the views fail, want the last %+v", got, first)
+	}
+}
@@ -2,124 +2,233 @@ package rpc
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"log"
-	"os"
+	"reflect"
 	"time"
 
 	"github.com/rozum-dev/near-go-warchest/common"
 	nearapi "github.com/rozum-dev/near-go-warchest/rpc/client"
+	"github.com/rozum-dev/near-go-warchest/services/notifier"
 	prom "github.com/rozum-dev/near-go-warchest/services/prometheus"
-)
-
-var (
-	repeatTime = os.Getenv("REPEAT_TIME")
+	"github.com/rozum-dev/near-go-warchest/store"
 )
 
 type SubscrResult struct {
-	LatestBlockHeight int64
-	EpochStartHeight  int64
-	EpochLength       int
-	CurrentStake      int
-	NextStake         int
-	KickedOut         bool
-	Err               error
+	LatestBlockHeight int64 `json:"latest_block_height"`
+	EpochStartHeight  int64 `json:"epoch_start_height"`
+	// Number of the epoch, 0 if the node does not send it
+	EpochHeight   int64         `json:"epoch_height"`
+	EpochLength   int           `json:"epoch_length"`
+	CurrentStake  common.Amount `json:"current_stake"`
+	NextStake     common.Amount `json:"next_stake"`
+	ExpectedStake common.Amount `json:"expected_stake"`
+	// Blocks produced and expected in the current epoch
+	ProducedBlocks int64 `json:"produced_blocks"`
+	ExpectedBlocks int64 `json:"expected_blocks"`
+	ProducedChunks int64 `json:"produced_chunks"`
+	ExpectedChunks int64 `json:"expected_chunks"`
+	// Percent of blocks and chunks projected at the end of the epoch, 0 if unknown
+	ProjectedBlocks float64     `json:"projected_blocks"`
+	ProjectedChunks float64     `json:"projected_chunks"`
+	Proposed        bool        `json:"proposed"`
+	KickedOut       bool        `json:"kicked_out"`
+	Kickout         *Kickout    `json:"kickout"`
+	SeatPrices      *SeatPrices `json:"seat_prices"`
+	// State of the staking pool contract, nil until it is read
+	Pool *PoolInfo `json:"pool"`
+	// Protocol parameters of the current epoch
+	Protocol *nearapi.ProtocolConfigResult `json:"protocol"`
+	Err      error                         `json:"-"`
+}
+
+// Kickout is why the pool was kicked out in the previous epoch.
+type Kickout struct {
+	Kind   string                `json:"kind"`
+	Reason nearapi.KickoutReason `json:"reason"`
+	Hint   string                `json:"hint"`
 }
 
 type Monitor struct {
-	client *nearapi.Client
-	result *SubscrResult
-	poolId string
+	client   *nearapi.Client
+	result   *SubscrResult
+	poolId   string
+	interval time.Duration
+	notifier notifier.Notifier
+	// Alerts already sent: rpc down, and the epochs of the kickout and seat loss alerts
+	rpcDown                       bool
+	kickedOutEpoch, seatLostEpoch int64
+	kickoutKind                   string
+	blocks, chunks                production
+	// Protocol config cached for one epoch
+	protocol      *nearapi.ProtocolConfigResult
+	protocolEpoch int64
+	// Last state of the pool contract, to alert on changes
+	pool *PoolInfo
+	// Keeps the state of the pool contract across restarts, optional
+	store *store.Store
 }
 
-func NewMonitor(client *nearapi.Client, poolId string) *Monitor {
+func NewMonitor(client *nearapi.Client, poolId string, interval time.Duration, n notifier.Notifier, st *store.Store) *Monitor {
 	return &Monitor{
-		client: client,
-		poolId: poolId,
+		client:   client,
+		poolId:   poolId,
+		interval: interval,
+		notifier: n,
+		store:    st,
+		blocks:   production{name: "blocks"},
+		chunks:   production{name: "chunks"},
 	}
 }
 
-func (m *Monitor) Run(ctx context.Context, result chan *SubscrResult, sem common.Sem, metrics *prom.PromMetrics) {
-	t := common.GetIntFromString(repeatTime)
-	ticker := time.NewTicker(time.Duration(t) * time.Second)
-	log.Printf("Subscribed for updates every %s seconds\n", repeatTime)
+func (m *Monitor) Run(ctx context.Context, result chan *SubscrResult, sem common.Sem, metrics *prom.PoolMetrics) {
+	ticker := time.NewTicker(m.interval)
+	log.Printf("Subscribed for updates every %s\n", m.interval)
 	for {
 		select {
 		case <-ticker.C:
 			sem.Acquare()
 
 			log.Println("Starting watch rpc")
+			for _, h := range m.client.Health() {
+				metrics.RPCHealthGauge.WithLabelValues(h.URL).Set(h.Health)
+			}
 
-			sr, err := m.client.Get("status", nil)
+			sr, err := m.client.Status(ctx)
 			if err != nil {
 				log.Println(err)
 				sem.Release()
-				m.result.Err = err
-				result <- m.result
+				result <- m.failed(ctx, err)
 				continue
 			}
 
-			var epochLength int
-			switch sr.Status.ChainId {
-			case "betanet":
-				epochLength = 10000
-			case "testnet":
-				epochLength = 43200
-			case "mainnet":
-				epochLength = 43200
-			}
+			blockHeight := sr.SyncInfo.LatestBlockHeight
 
-			blockHeight := sr.Status.SyncInfo.LatestBlockHeight
+			vr, err := m.validators(ctx, blockHeight)
+			if err != nil {
+				log.Println(err)
+				sem.Release()
+				result <- m.failed(ctx, err)
+				continue
+			}
 
-			vr, err := m.client.Get("validators", []uint64{blockHeight})
+			pc, err := m.protocolConfig(ctx, vr.EpochStartHeight)
 			if err != nil {
 				log.Println(err)
 				sem.Release()
-				m.result.Err = err
-				result <- m.result
+				result <- m.failed(ctx, err)
 				continue
 			}
 
 			metrics.ThresholdGauge.Set(0)
-			var currentStake int
+			var currentStake common.Amount
+			var producedBlocks, expectedBlocks, producedChunks, expectedChunks int64
+			validator := false
 
-			for _, v := range vr.Validators.CurrentValidators {
+			for _, v := range vr.CurrentValidators {
 
 				if v.AccountId == m.poolId {
+					validator = true
+					producedBlocks, expectedBlocks = v.NumProducedBlocks, v.NumExpectedBlocks
+					producedChunks, expectedChunks = v.NumProducedChunks, v.NumExpectedChunks
+					if expectedBlocks > 0 {
+						metrics.ThresholdGauge.Set(float64(producedBlocks) / float64(expectedBlocks) * 100)
+					}
 
-					pb := float64(v.NumProducedBlocks)
-					eb := float64(v.NumExpectedBlocks)
-					threshold := (pb / eb) * 100
-					if threshold > 90.0 {
-						log.Printf("Kicked out threshold: %f\n", threshold)
+					currentStake, err = common.ParseAmount(v.Stake)
+					if err != nil {
+						log.Println(err)
 					}
-					metrics.ThresholdGauge.Set(threshold)
+				}
+			}
+
+			epochStartHeight := vr.EpochStartHeight
+			var projectedBlocks, projectedChunks float64
+			if validator {
+				progress := float64(int64(blockHeight)-epochStartHeight) / float64(pc.EpochLength)
+				projectedBlocks = m.checkProduction(ctx, &m.blocks, epochStartHeight, producedBlocks, expectedBlocks, progress, pc.BlockProducerKickoutThreshold)
+				projectedChunks = m.checkProduction(ctx, &m.chunks, epochStartHeight, producedChunks, expectedChunks, progress, pc.ChunkProducerKickoutThreshold)
+			}
+			metrics.ProjectedProductionGauge.WithLabelValues("blocks").Set(projectedBlocks)
+			metrics.ProjectedProductionGauge.WithLabelValues("chunks").Set(projectedChunks)
 
-					currentStake = common.GetStakeFromString(v.Stake)
+			var nextStake common.Amount
+			inNext := false
+			for _, v := range vr.NextValidators {
+				if v.AccountId == m.poolId {
+					inNext = true
+					nextStake, err = common.ParseAmount(v.Stake)
+					if err != nil {
+						log.Println(err)
+					}
 				}
 			}
+			if !currentStake.IsZero() && !inNext && m.seatLostEpoch != epochStartHeight {
+				m.seatLostEpoch = epochStartHeight
+				m.notify(ctx, notifier.SeatLost, "validator in the current epoch but not in the next one")
+			}
 
-			var nextStake int
-			for _, v := range vr.Validators.NextValidators {
+			// Our exact account id in the current proposals
+			var expectedStake common.Amount
+			proposed := false
+			for _, v := range vr.CurrentProposals {
 				if v.AccountId == m.poolId {
-					nextStake = common.GetStakeFromString(v.Stake)
+					expectedStake, err = common.ParseAmount(v.Stake)
+					if err != nil {
+						log.Println(err)
+						continue
+					}
+					proposed = true
 				}
 			}
 
 			kickedOut := false
-			for _, v := range vr.Validators.PrevEpochKickOut {
+			var kickout *Kickout
+			for _, v := range vr.PrevEpochKickOut {
 				if v.AccountId == m.poolId {
 					kickedOut = true
-					log.Printf("Was kicked out :(\n")
+					kickout = &Kickout{Kind: v.Reason.Kind(), Reason: v.Reason, Hint: v.Reason.Hint()}
+					log.Printf("Was kicked out :( %s: %s\n", kickout.Kind, v.Reason)
+					log.Printf("Hint: %s\n", kickout.Hint)
+					if m.kickedOutEpoch != epochStartHeight {
+						m.kickedOutEpoch = epochStartHeight
+						m.notify(ctx, notifier.KickedOut, fmt.Sprintf("kicked out in the previous epoch: %s. %s", v.Reason, kickout.Hint))
+					}
 				}
 			}
+			m.setKickoutGauge(metrics, kickout)
+			pool := m.checkPool(ctx, metrics)
+
+			seatPrices, err := GetSeatPrices(vr, pc)
+			if err != nil {
+				log.Printf("Failed to calculate seat prices: %s\n", err)
+			}
 
+			if m.rpcDown {
+				m.rpcDown = false
+				log.Println("RPC is back")
+			}
 			m.result = &SubscrResult{
-				EpochStartHeight:  vr.Validators.EpochStartHeight,
+				EpochStartHeight:  epochStartHeight,
+				EpochHeight:       vr.EpochHeight,
 				LatestBlockHeight: int64(blockHeight),
-				EpochLength:       epochLength,
+				EpochLength:       int(pc.EpochLength),
 				CurrentStake:      currentStake,
 				NextStake:         nextStake,
+				ExpectedStake:     expectedStake,
+				ProducedBlocks:    producedBlocks,
+				ExpectedBlocks:    expectedBlocks,
+				ProducedChunks:    producedChunks,
+				ExpectedChunks:    expectedChunks,
+				ProjectedBlocks:   projectedBlocks,
+				ProjectedChunks:   projectedChunks,
+				Proposed:          proposed,
 				KickedOut:         kickedOut,
+				Kickout:           kickout,
+				SeatPrices:        seatPrices,
+				Pool:              pool,
+				Protocol:          pc,
 				Err:               nil,
 			}
 
@@ -132,3 +241,91 @@ func (m *Monitor) Run(ctx context.Context, result chan *SubscrResult, sem common
 		}
 	}
 }
+
+// JSON-RPC code of an unknown method, for nodes that send no cause
+const methodNotFoundCode = -32601
+
+// validators returns the validators of the epoch with the block. A node which
+// does not know the block yet, or is between epochs, is asked for the latest
+// epoch instead.
+func (m *Monitor) validators(ctx context.Context, blockHeight uint64) (*nearapi.ValidatorsResult, error) {
+	vr, err := m.client.Validators(ctx, blockHeight)
+	if nearapi.IsCause(err, nearapi.UnknownBlock) || nearapi.IsCause(err, nearapi.UnknownEpoch) {
+		log.Printf("%s, asking for the latest epoch\n", err)
+		vr, err = m.client.Validators(ctx, 0)
+	}
+	return vr, err
+}
+
+// protocolConfig returns the protocol config of the epoch, asking the node
+// once per epoch. Nodes without EXPERIMENTAL_protocol_config get the genesis
+// config, and the last known config is kept while the RPC fails.
+func (m *Monitor) protocolConfig(ctx context.Context, epochStartHeight int64) (*nearapi.ProtocolConfigResult, error) {
+	if m.protocol != nil && m.protocolEpoch == epochStartHeight {
+		return m.protocol, nil
+	}
+	pc, err := m.client.ProtocolConfig(ctx)
+	var rpcErr *nearapi.RPCError
+	if errors.As(err, &rpcErr) && (rpcErr.Cause == nearapi.MethodNotFound || rpcErr.Code == methodNotFoundCode) {
+		log.Printf("%s, falling back to the genesis config\n", err)
+		pc, err = m.client.GenesisConfig(ctx)
+	}
+	if err != nil {
+		if m.protocol != nil {
+			log.Printf("%s, using the protocol config of epoch %d\n", err, m.protocolEpoch)
+			return m.protocol, nil
+		}
+		return nil, err
+	}
+	if pc.EpochLength <= 0 {
+		return nil, fmt.Errorf("invalid epoch length %d in the protocol config", pc.EpochLength)
+	}
+	if m.protocol == nil || !reflect.DeepEqual(m.protocol, pc) {
+		log.Printf("Protocol %d on %s: epoch length %d, %d seats, minimum stake ratio %d/%d, kickout thresholds %d%% blocks, %d%% chunks\n",
+			pc.ProtocolVersion, pc.ChainId, pc.EpochLength, pc.NumSeats(), pc.MinimumStakeRatio[0], pc.MinimumStakeRatio[1],
+			pc.BlockProducerKickoutThreshold, pc.ChunkProducerKickoutThreshold)
+	}
+	m.protocol = pc
+	m.protocolEpoch = epochStartHeight
+	return pc, nil
+}
+
+// setKickoutGauge sets 1 for the kickout reason and 0 for the others.
+func (m *Monitor) setKickoutGauge(metrics *prom.PoolMetrics, kickout *Kickout) {
+	for _, kind := range nearapi.KickoutKinds {
+		metrics.KickoutGauge.WithLabelValues(kind).Set(0)
+	}
+	// A reason unknown to this version
+	if m.kickoutKind != "" {
+		metrics.KickoutGauge.WithLabelValues(m.kickoutKind).Set(0)
+	}
+	m.kickoutKind = ""
+	if kickout != nil {
+		m.kickoutKind = kickout.Kind
+		metrics.KickoutGauge.WithLabelValues(kickout.Kind).Set(1)
+	}
+}
+
+// failed returns a copy of the last result with the error, so the runner can
+// fall back to it. The last result itself was handed to the runner already.
+func (m *Monitor) failed(ctx context.Context, err error) *SubscrResult {
+	if !nearapi.IsUnreachable(err) {
+		log.Printf("The node answered with an error: %s\n", err)
+	} else if !m.rpcDown {
+		m.rpcDown = true
+		m.notify(ctx, notifier.RPCDown, "node unreachable: "+err.Error())
+	}
+	var res SubscrResult
+	if m.result != nil {
+		res = *m.result
+	}
+	res.Err = err
+	return &res
+}
+
+func (m *Monitor) notify(ctx context.Context, kind, message string) {
+	err := m.notifier.Notify(ctx, notifier.Event{Kind: kind, PoolId: m.poolId, Message: message})
+	if err != nil {
+		log.Println(err)
+	}
+}
@@ -0,0 +1,243 @@
+package rpc
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rozum-dev/near-go-warchest/common"
+	nearapi "github.com/rozum-dev/near-go-warchest/rpc/client"
+	prom "github.com/rozum-dev/near-go-warchest/services/prometheus"
+)
+
+const testPoolId = "pool.poolv1.near"
+
+// fakeNode answers JSON-RPC methods and the view calls of the pool with
+// fixed results, or errors, and counts the requests.
+type fakeNode struct {
+	mu sync.Mutex
+	// Results of methods, and of views by "view:" and the view name
+	results map[string]string
+	errors  map[string]string
+	calls   map[string]int
+}
+
+func newFakeNode(t *testing.T) (*fakeNode, *nearapi.Client) {
+	n := &fakeNode{
+		results: map[string]string{
+			"status":                        `{"chain_id":"testnet","sync_info":{"latest_block_height":1050}}`,
+			"validators":                    `{"current_validators":[],"next_validators":[],"current_proposals":[],"prev_epoch_kickout":[],"epoch_start_height":1000,"epoch_height":10}`,
+			"EXPERIMENTAL_protocol_config":  `{"protocol_version":49,"chain_id":"testnet","epoch_length":100,"num_block_producer_seats":2,"minimum_stake_ratio":[1,6250],"block_producer_kickout_threshold":90,"chunk_producer_kickout_threshold":90}`,
+			"view:get_total_staked_balance": `"1000000000000000000000000000"`,
+			"view:get_number_of_accounts":   `3`,
+			"view:get_reward_fee_fraction":  `{"numerator":10,"denominator":100}`,
+			"view:get_owner_id":             `"owner.near"`,
+			"view:get_staking_key":          `"ed25519:key"`,
+			"view:is_staking_paused":        `false`,
+		},
+		errors: make(map[string]string),
+		calls:  make(map[string]int),
+	}
+	srv := httptest.NewServer(n)
+	t.Cleanup(srv.Close)
+	return n, nearapi.NewClientWithContext(context.Background(), srv.URL)
+}
+
+func (n *fakeNode) set(name, result string) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.results[name] = result
+}
+
+// fail makes the method answer with the JSON-RPC error, or answer again if e is empty.
+func (n *fakeNode) fail(name, e string) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if e == "" {
+		delete(n.errors, name)
+		return
+	}
+	n.errors[name] = e
+}
+
+func (n *fakeNode) count(name string) int {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.calls[name]
+}
+
+func (n *fakeNode) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Method string          `json:"method"`
+		Params json.RawMessage `json:"params"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	name := req.Method
+	if name == "query" {
+		var q struct {
+			MethodName string `json:"method_name"`
+		}
+		json.Unmarshal(req.Params, &q)
+		name = "view:" + q.MethodName
+	}
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.calls[name]++
+	if e, ok := n.errors[name]; ok {
+		fmt.Fprintf(w, `{"jsonrpc":"2.0","id":"dontcare","error":%s}`, e)
+		return
+	}
+	result, ok := n.results[name]
+	if !ok {
+		fmt.Fprintf(w, `{"jsonrpc":"2.0","id":"dontcare","error":{"name":"HANDLER_ERROR","cause":{"name":"UNKNOWN_BLOCK"},"code":-32000,"message":"Server error"}}`)
+		return
+	}
+	if name != req.Method {
+		// View results are the bytes of the JSON value
+		var bytes []int
+		for _, b := range []byte(result) {
+			bytes = append(bytes, int(b))
+		}
+		out, _ := json.Marshal(bytes)
+		result = fmt.Sprintf(`{"result":%s,"logs":[],"block_height":1050,"block_hash":"hash"}`, out)
+	}
+	fmt.Fprintf(w, `{"jsonrpc":"2.0","id":"dontcare","result":%s}`, result)
+}
+
+// tick runs the monitor until it sends one result.
+func tick(m *Monitor) *SubscrResult {
+	ctx, cancel := context.WithCancel(context.Background())
+	results := make(chan *SubscrResult)
+	done := make(chan struct{})
+	go func() {
+		m.Run(ctx, results, make(common.Sem, 1), prom.NewPromMetrics().Pool(m.poolId))
+		close(done)
+	}()
+	res := <-results
+	cancel()
+	for {
+		select {
+		case <-results:
+		case <-done:
+			return res
+		}
+	}
+}
+
+func TestMonitorExpectedStake(t *testing.T) {
+	tests := []struct {
+		name      string
+		proposals string
+		proposed  bool
+		want      common.Amount
+	}{
+		{"proposed", `[{"account_id":"other.poolv1.near","stake":"1"},{"account_id":"pool.poolv1.near","stake":"12000000000000000000000000"}]`, true, common.NearAmount(12)},
+		{"not proposed", `[{"account_id":"other.poolv1.near","stake":"1"}]`, false, common.Amount{}},
+		{"only a similar account", `[{"account_id":"pool.poolv1.near.other","stake":"1"},{"account_id":"x.pool.poolv1.near","stake":"2"}]`, false, common.Amount{}},
+		{"unparsable stake", `[{"account_id":"pool.poolv1.near","stake":"-1"}]`, false, common.Amount{}},
+		{"no proposals", `[]`, false, common.Amount{}},
+	}
+	for _, tt := range tests {
+		node, client := newFakeNode(t)
+		node.set("validators", fmt.Sprintf(`{"current_validators":[],"next_validators":[],"current_proposals":%s,"prev_epoch_kickout":[],"epoch_start_height":1000}`, tt.proposals))
+		res := tick(NewMonitor(client, testPoolId, time.Millisecond, &recordingNotifier{}, nil))
+		if res.Err != nil {
+			t.Errorf("%s: %v", tt.name, res.Err)
+			continue
+		}
+		if res.Proposed != tt.proposed || res.ExpectedStake.Cmp(tt.want) != 0 {
+			t.Errorf("%s: proposed %v with %s, want %v with %s", tt.name, res.Proposed, res.ExpectedStake, tt.proposed, tt.want)
+		}
+	}
+}
+
+func TestMonitorProtocolConfig(t *testing.T) {
+	const (
+		notFoundCause = `{"name":"HANDLER_ERROR","cause":{"name":"METHOD_NOT_FOUND","info":{}},"code":-32000,"message":"Server error"}`
+		notFoundCode  = `{"code":-32601,"message":"Method not found","data":null}`
+		internal      = `{"name":"INTERNAL_ERROR","cause":{"name":"INTERNAL_ERROR","info":{}},"code":-32000,"message":"Server error"}`
+	)
+	config := func(version, epochLength int) string {
+		return fmt.Sprintf(`{"protocol_version":%d,"chain_id":"testnet","epoch_length":%d,"num_block_producer_seats":2,"minimum_stake_ratio":[1,6250],"block_producer_kickout_threshold":90,"chunk_producer_kickout_threshold":90}`, version, epochLength)
+	}
+	type step struct {
+		epoch int64
+		// Result of EXPERIMENTAL_protocol_config from this step, if set
+		result string
+		// Errors of EXPERIMENTAL_protocol_config and EXPERIMENTAL_genesis_config
+		protocolErr, genesisErr string
+		// Protocol version returned, 0 for an error
+		version int
+		// Requests made so far
+		protocolCalls, genesisCalls int
+	}
+	tests := []struct {
+		name  string
+		steps []step
+	}{
+		{"asked once per epoch", []step{
+			{epoch: 1000, version: 49, protocolCalls: 1},
+			{epoch: 1000, result: config(50, 100), version: 49, protocolCalls: 1},
+			{epoch: 1100, version: 50, protocolCalls: 2},
+		}},
+		{"genesis config without the method", []step{
+			{epoch: 1000, protocolErr: notFoundCause, version: 29, protocolCalls: 1, genesisCalls: 1},
+			{epoch: 1000, protocolErr: notFoundCause, version: 29, protocolCalls: 1, genesisCalls: 1},
+			{epoch: 1100, protocolErr: notFoundCause, version: 29, protocolCalls: 2, genesisCalls: 2},
+		}},
+		{"genesis config on the JSON-RPC code", []step{
+			{epoch: 1000, protocolErr: notFoundCode, version: 29, protocolCalls: 1, genesisCalls: 1},
+		}},
+		{"last config kept while the node fails", []step{
+			{epoch: 1000, version: 49, protocolCalls: 1},
+			{epoch: 1100, protocolErr: internal, version: 49, protocolCalls: 2},
+			{epoch: 1100, protocolErr: internal, version: 49, protocolCalls: 3},
+			{epoch: 1100, result: config(50, 100), version: 50, protocolCalls: 4},
+		}},
+		{"no config before the first answer", []step{
+			{epoch: 1000, protocolErr: internal, protocolCalls: 1},
+			{epoch: 1000, version: 49, protocolCalls: 2},
+		}},
+		{"last config kept while the genesis config fails", []step{
+			{epoch: 1000, protocolErr: notFoundCause, genesisErr: internal, protocolCalls: 1, genesisCalls: 1},
+			{epoch: 1000, protocolErr: notFoundCause, version: 29, protocolCalls: 2, genesisCalls: 2},
+			{epoch: 1100, protocolErr: notFoundCause, genesisErr: internal, version: 29, protocolCalls: 3, genesisCalls: 3},
+		}},
+		{"invalid epoch length", []step{
+			{epoch: 1000, result: config(49, 0), protocolCalls: 1},
+		}},
+	}
+	for _, tt := range tests {
+		node, client := newFakeNode(t)
+		node.set("EXPERIMENTAL_genesis_config", config(29, 100))
+		m := NewMonitor(client, testPoolId, time.Millisecond, &recordingNotifier{}, nil)
+		for i, s := range tt.steps {
+			if s.result != "" {
+				node.set("EXPERIMENTAL_protocol_config", s.result)
+			}
+			node.fail("EXPERIMENTAL_protocol_config", s.protocolErr)
+			node.fail("EXPERIMENTAL_genesis_config", s.genesisErr)
+			pc, err := m.protocolConfig(context.Background(), s.epoch)
+			switch {
+			case s.version == 0 && err == nil:
+				t.Errorf("%s: step %d: protocol %d, want an error", tt.name, i, pc.ProtocolVersion)
+			case s.version != 0 && err != nil:
+				t.Errorf("%s: step %d: %v", tt.name, i, err)
+			case s.version != 0 && pc.ProtocolVersion != s.version:
+				t.Errorf("%s: step %d: protocol %d, want %d", tt.name, i, pc.ProtocolVersion, s.version)
+			}
+			protocolCalls, genesisCalls := node.count("EXPERIMENTAL_protocol_config"), node.count("EXPERIMENTAL_genesis_config")
+			if protocolCalls != s.protocolCalls || genesisCalls != s.genesisCalls {
+				t.Errorf("%s: step %d: %d protocol and %d genesis requests, want %d and %d", tt.name, i, protocolCalls, genesisCalls, s.protocolCalls, s.genesisCalls)
+			}
+		}
+	}
+}
@@ -0,0 +1,155 @@
+package rpc
+
+import (
+	"errors"
+	"math/big"
+	"sort"
+
+	"github.com/rozum-dev/near-go-warchest/common"
+	nearapi "github.com/rozum-dev/near-go-warchest/rpc/client"
+)
+
+// From this protocol version on validators are selected by stake, largest
+// first, and the seat price is derived from the selected stakes.
+const seatPriceProtocolVersion = 49
+
+// FindSeatPrice implements the seat price threshold algorithm of nearcore for the given stakes.
+func FindSeatPrice(stakes []common.Amount, numSeats int, minStakeRatio [2]int64, protocolVersion int) (common.Amount, error) {
+	if len(stakes) == 0 {
+		return common.Amount{}, errors.New("no stakes")
+	}
+	sorted := make([]common.Amount, len(stakes))
+	copy(sorted, stakes)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Cmp(sorted[j]) < 0 })
+	if protocolVersion < seatPriceProtocolVersion {
+		var sum common.Amount
+		for _, s := range sorted {
+			sum = sum.Add(s)
+		}
+		return findSeatPriceBefore49(sorted, sum, numSeats)
+	}
+	if minStakeRatio[1] <= minStakeRatio[0] || minStakeRatio[0] < 0 {
+		return common.Amount{}, errors.New("invalid minimum stake ratio")
+	}
+	return selectValidators(sorted, numSeats, minStakeRatio), nil
+}
+
+// selectValidators follows select_validators of nearcore: the largest stakes
+// take the seats as long as each one is above the minimum ratio of the stake
+// selected with it. When all seats are taken the price is one yocto above the
+// smallest selected stake, otherwise it is the smallest stake which would
+// pass the ratio, ceil(ratio * total / (1 - ratio)). stakes must be ascending.
+func selectValidators(stakes []common.Amount, numSeats int, minStakeRatio [2]int64) common.Amount {
+	num, den := big.NewInt(minStakeRatio[0]), big.NewInt(minStakeRatio[1])
+	total := new(big.Int)
+	var selected int
+	var smallest *big.Int
+	for i := len(stakes) - 1; i >= 0 && selected < numSeats; i-- {
+		stake := stakes[i].BigInt()
+		withStake := new(big.Int).Add(total, stake)
+		// stake / withStake > num / den
+		if new(big.Int).Mul(stake, den).Cmp(new(big.Int).Mul(withStake, num)) <= 0 {
+			break
+		}
+		total = withStake
+		smallest = stake
+		selected++
+	}
+	if selected == numSeats && smallest != nil {
+		return common.NewAmount(new(big.Int).Add(smallest, big.NewInt(1)))
+	}
+	// ceil(num * total / (den - num))
+	q := new(big.Int).Mul(total, num)
+	d := new(big.Int).Sub(den, num)
+	q.Add(q, d).Sub(q, big.NewInt(1)).Quo(q, d)
+	return common.NewAmount(q)
+}
+
+// findSeatPriceBefore49 finds by binary search the largest price at which
+// the stakes fill all seats.
+func findSeatPriceBefore49(stakes []common.Amount, sum common.Amount, numSeats int) (common.Amount, error) {
+	seats := big.NewInt(int64(numSeats))
+	total := sum.BigInt()
+	if total.Cmp(seats) < 0 {
+		return common.Amount{}, errors.New("stakes are below seats")
+	}
+	one := big.NewInt(1)
+	left := big.NewInt(1)
+	right := new(big.Int).Add(total, one)
+	for new(big.Int).Sub(right, one).Cmp(left) != 0 {
+		mid := new(big.Int).Add(left, right)
+		mid.Quo(mid, big.NewInt(2))
+		found := false
+		current := new(big.Int)
+		for _, s := range stakes {
+			current.Add(current, new(big.Int).Quo(s.BigInt(), mid))
+			if current.Cmp(seats) >= 0 {
+				left = mid
+				found = true
+				break
+			}
+		}
+		if !found {
+			right = mid
+		}
+	}
+	return common.NewAmount(left), nil
+}
+
+// SeatPrices holds the seat prices of the current and next epochs and the
+// price expected from the current proposals.
+type SeatPrices struct {
+	Current  common.Amount `json:"current"`
+	Next     common.Amount `json:"next"`
+	Expected common.Amount `json:"expected"`
+}
+
+func parseStakes(validators []nearapi.Validator) []common.Amount {
+	var stakes []common.Amount
+	for _, v := range validators {
+		s, err := common.ParseAmount(v.Stake)
+		if err != nil {
+			continue
+		}
+		stakes = append(stakes, s)
+	}
+	return stakes
+}
+
+// GetSeatPrices computes the three seat prices from the validators result and the protocol config.
+func GetSeatPrices(vr *nearapi.ValidatorsResult, pc *nearapi.ProtocolConfigResult) (*SeatPrices, error) {
+	numSeats := pc.NumSeats()
+
+	var current, next []nearapi.Validator
+	proposed := make(map[string]bool)
+	for _, v := range vr.CurrentProposals {
+		proposed[v.AccountId] = true
+	}
+	for _, v := range vr.CurrentValidators {
+		current = append(current, v.Validator)
+	}
+	for _, v := range vr.NextValidators {
+		next = append(next, v.Validator)
+	}
+	// Validators of the next epoch which did not send a new proposal keep
+	// their stake for the epoch after it
+	combined := append([]nearapi.Validator{}, vr.CurrentProposals...)
+	for _, v := range next {
+		if !proposed[v.AccountId] {
+			combined = append(combined, v)
+		}
+	}
+
+	var p SeatPrices
+	var err error
+	if p.Current, err = FindSeatPrice(parseStakes(current), numSeats, pc.MinimumStakeRatio, pc.ProtocolVersion); err != nil {
+		return nil, err
+	}
+	if p.Next, err = FindSeatPrice(parseStakes(next), numSeats, pc.MinimumStakeRatio, pc.ProtocolVersion); err != nil {
+		return nil, err
+	}
+	if p.Expected, err = FindSeatPrice(parseStakes(combined), numSeats, pc.MinimumStakeRatio, pc.ProtocolVersion); err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
@@ -0,0 +1,174 @@
+package rpc
+
+import (
+	"encoding/json"
+	"math/big"
+	"testing"
+
+	"github.com/rozum-dev/near-go-warchest/common"
+	nearapi "github.com/rozum-dev/near-go-warchest/rpc/client"
+)
+
+func near(ns ...int64) []common.Amount {
+	var out []common.Amount
+	for _, n := range ns {
+		out = append(out, common.NearAmount(n))
+	}
+	return out
+}
+
+func yocto(n int64) common.Amount {
+	return common.NewAmount(big.NewInt(n))
+}
+
+func TestFindSeatPrice(t *testing.T) {
+	ratio := [2]int64{1, 6250}
+	tests := []struct {
+		name     string
+		stakes   []common.Amount
+		seats    int
+		protocol int
+		want     common.Amount
+		err      bool
+	}{
+		{name: "before 49, equal stakes", stakes: near(100, 100, 100), seats: 3, protocol: 40, want: common.NearAmount(100)},
+		{name: "before 49, one stake buys several seats", stakes: near(300, 100), seats: 4, protocol: 40, want: common.NearAmount(100)},
+		{name: "before 49, rounding down", stakes: near(10, 1), seats: 2, protocol: 40, want: common.NearAmount(5)},
+		{name: "before 49, more stakes than seats", stakes: near(5, 4, 3, 2, 1), seats: 3, protocol: 40, want: common.NearAmount(3)},
+		{name: "before 49, unsorted input", stakes: near(1, 3, 5, 2, 4), seats: 3, protocol: 40, want: common.NearAmount(3)},
+		{name: "before 49, stakes below seats", stakes: []common.Amount{yocto(1)}, seats: 2, protocol: 40, err: true},
+		{name: "no stakes", seats: 3, protocol: 49, err: true},
+		{name: "49, more stakes than seats", stakes: near(5, 4, 3, 2, 1), seats: 3, protocol: 49, want: common.NearAmount(3).Add(yocto(1))},
+		{name: "49, unsorted input", stakes: near(2, 5, 1, 3, 4), seats: 3, protocol: 49, want: common.NearAmount(3).Add(yocto(1))},
+		{name: "49, one stake left out", stakes: near(5, 4, 3), seats: 2, protocol: 49, want: common.NearAmount(4).Add(yocto(1))},
+		{name: "49, as many stakes as seats", stakes: near(6250, 6250), seats: 2, protocol: 49, want: common.NearAmount(6250).Add(yocto(1))},
+		// ceil(62500 / 6249) NEAR
+		{name: "49, free seats", stakes: near(62500), seats: 10, protocol: 56, want: amount("10001600256040966554648744")},
+		// 1 NEAR is below 1/6250 of the stake with it and takes no seat
+		{name: "49, below the minimum ratio", stakes: near(10000, 1), seats: 10, protocol: 49, want: amount("1600256040966554648743800")},
+		{name: "49, the ratio stops before the seats are full", stakes: near(10000, 1, 1), seats: 2, protocol: 49, want: amount("1600256040966554648743800")},
+	}
+	for _, tt := range tests {
+		got, err := FindSeatPrice(tt.stakes, tt.seats, ratio, tt.protocol)
+		if (err != nil) != tt.err {
+			t.Errorf("%s: error = %v, want error %v", tt.name, err, tt.err)
+			continue
+		}
+		if err == nil && got.Cmp(tt.want) != 0 {
+			t.Errorf("%s: seat price = %s, want %s", tt.name, got.Yocto(), tt.want.Yocto())
+		}
+	}
+	for _, ratio := range [][2]int64{{1, 0}, {1, 1}, {-1, 6250}} {
+		if _, err := FindSeatPrice(near(1, 2), 1, ratio, 49); err == nil {
+			t.Errorf("minimum stake ratio %d/%d accepted", ratio[0], ratio[1])
+		}
+	}
+}
+
+func amount(yocto string) common.Amount {
+	a, err := common.ParseAmount(yocto)
+	if err != nil {
+		panic(err)
+	}
+	return a
+}
+
+// Validators responses in the format of the RPC. In the first all seats are
+// taken, d was kicked out but may propose again, and c raised its stake. In
+// the second the smallest stake is below the minimum ratio.
+const (
+	fullSeatsResponse = `{
+  "current_validators": [
+    {"account_id": "a.poolv1.near", "public_key": "ed25519:a", "stake": "31861248305745437214385732862", "is_slashed": false, "shards": [0], "num_produced_blocks": 120, "num_expected_blocks": 120},
+    {"account_id": "b.poolv1.near", "public_key": "ed25519:b", "stake": "20434113628930516046284398177", "is_slashed": false, "shards": [0], "num_produced_blocks": 77, "num_expected_blocks": 78},
+    {"account_id": "c.poolv1.near", "public_key": "ed25519:c", "stake": "15402716271028373610318219018", "is_slashed": false, "shards": [0], "num_produced_blocks": 58, "num_expected_blocks": 58},
+    {"account_id": "d.poolv1.near", "public_key": "ed25519:d", "stake": "3918561935716425683918265019", "is_slashed": false, "shards": [0], "num_produced_blocks": 2, "num_expected_blocks": 15}
+  ],
+  "next_validators": [
+    {"account_id": "a.poolv1.near", "public_key": "ed25519:a", "stake": "31861248305745437214385732862", "shards": [0]},
+    {"account_id": "b.poolv1.near", "public_key": "ed25519:b", "stake": "20434113628930516046284398177", "shards": [0]},
+    {"account_id": "c.poolv1.near", "public_key": "ed25519:c", "stake": "15402716271028373610318219018", "shards": [0]},
+    {"account_id": "e.poolv1.near", "public_key": "ed25519:e", "stake": "4218335176162539190625331017", "shards": [0]}
+  ],
+  "current_proposals": [
+    {"account_id": "c.poolv1.near", "public_key": "ed25519:c", "stake": "16112706018272519931920001002"},
+    {"account_id": "d.poolv1.near", "public_key": "ed25519:d", "stake": "3918561935716425683918265019"},
+    {"account_id": "f.poolv1.near", "public_key": "ed25519:f", "stake": "5131059820049110362781201920"}
+  ],
+  "prev_epoch_kickout": [
+    {"account_id": "d.poolv1.near", "reason": {"NotEnoughBlocks": {"produced": 30, "expected": 120}}}
+  ],
+  "epoch_start_height": 42376888,
+  "epoch_height": 1000
+}`
+	minimumRatioResponse = `{
+  "current_validators": [
+    {"account_id": "a.poolv1.near", "public_key": "ed25519:a", "stake": "31861248305745437214385732862", "is_slashed": false, "shards": [0], "num_produced_blocks": 120, "num_expected_blocks": 120},
+    {"account_id": "b.poolv1.near", "public_key": "ed25519:b", "stake": "20434113628930516046284398177", "is_slashed": false, "shards": [0], "num_produced_blocks": 77, "num_expected_blocks": 78}
+  ],
+  "next_validators": [
+    {"account_id": "a.poolv1.near", "public_key": "ed25519:a", "stake": "31861248305745437214385732862", "shards": [0]},
+    {"account_id": "b.poolv1.near", "public_key": "ed25519:b", "stake": "20434113628930516046284398177", "shards": [0]}
+  ],
+  "current_proposals": [
+    {"account_id": "small.poolv1.near", "public_key": "ed25519:s", "stake": "1000000000000000000000000"}
+  ],
+  "prev_epoch_kickout": [],
+  "epoch_start_height": 42376888,
+  "epoch_height": 1000
+}`
+)
+
+func TestGetSeatPrices(t *testing.T) {
+	tests := []struct {
+		name                    string
+		response                string
+		seats, protocol         int
+		current, next, expected common.Amount
+	}{
+		{
+			name:     "all seats taken",
+			response: fullSeatsResponse,
+			seats:    4,
+			protocol: 49,
+			current:  amount("3918561935716425683918265020"),
+			next:     amount("4218335176162539190625331018"),
+			// a and b roll over from the next validators, c and f proposed
+			expected: amount("5131059820049110362781201921"),
+		},
+		{
+			name:     "below the minimum ratio",
+			response: minimumRatioResponse,
+			seats:    100,
+			protocol: 56,
+			// ceil((a + b) / 6249)
+			current:  amount("8368596885049760483384563"),
+			next:     amount("8368596885049760483384563"),
+			expected: amount("8368596885049760483384563"),
+		},
+	}
+	for _, tt := range tests {
+		var vr nearapi.ValidatorsResult
+		if err := json.Unmarshal([]byte(tt.response), &vr); err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		pc := &nearapi.ProtocolConfigResult{ProtocolVersion: tt.protocol, NumBlockProducerSeats: tt.seats, MinimumStakeRatio: [2]int64{1, 6250}}
+		p, err := GetSeatPrices(&vr, pc)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		for _, price := range []struct {
+			name      string
+			got, want common.Amount
+		}{
+			{"current", p.Current, tt.current},
+			{"next", p.Next, tt.next},
+			{"expected", p.Expected, tt.expected},
+		} {
+			if price.got.Cmp(price.want) != 0 {
+				t.Errorf("%s: %s seat price = %s, want %s", tt.name, price.name, price.got.Yocto(), price.want.Yocto())
+			}
+		}
+	}
+}
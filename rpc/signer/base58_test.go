@@ -0,0 +1,45 @@
+package signer
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+)
+
+func TestBase58(t *testing.T) {
+	tests := []struct {
+		hex, base58 string
+	}{
+		{"", ""},
+		{"00", "1"},
+		{"0000", "11"},
+		{"61", "2g"},
+		{"626262", "a3gV"},
+		{"00000000000000000000", "1111111111"},
+		// Public key and block hash of the near-api-js transaction in transaction_test.go
+		{"917b3d268d4b58f7fec1b150bd68d69be3ee5d4cc39855e341538465bb77860d", "Anu7LYDfpLtkP7E16LT9imXF694BdQaa9ufVkQiwTQxC"},
+		{"0fa473fd26901df296be6adc4cc4df34d040efa2435224b6986910e630c2fef6", "244ZQ9cgj3CQ6bWBdytfrJMuMQ1jdXLFGnr4HhvtCTnM"},
+	}
+	for _, tt := range tests {
+		b, _ := hex.DecodeString(tt.hex)
+		if got := Base58Encode(b); got != tt.base58 {
+			t.Errorf("Base58Encode(%s) = %q, want %q", tt.hex, got, tt.base58)
+		}
+		got, err := Base58Decode(tt.base58)
+		if err != nil {
+			t.Errorf("Base58Decode(%q): %v", tt.base58, err)
+			continue
+		}
+		if !bytes.Equal(got, b) {
+			t.Errorf("Base58Decode(%q) = %x, want %s", tt.base58, got, tt.hex)
+		}
+	}
+}
+
+func TestBase58DecodeInvalid(t *testing.T) {
+	for _, s := range []string{"0", "O", "I", "l", "abc+"} {
+		if _, err := Base58Decode(s); err == nil {
+			t.Errorf("Base58Decode(%q) should fail", s)
+		}
+	}
+}
@@ -0,0 +1,52 @@
+package signer
+
+import (
+	"encoding/binary"
+	"math/big"
+)
+
+// borshWriter implements the subset of Borsh serialization needed for transactions.
+type borshWriter struct {
+	buf []byte
+}
+
+func (w *borshWriter) u8(v uint8) {
+	w.buf = append(w.buf, v)
+}
+
+func (w *borshWriter) u32(v uint32) {
+	var b [4]byte
+	binary.LittleEndian.PutUint32(b[:], v)
+	w.buf = append(w.buf, b[:]...)
+}
+
+func (w *borshWriter) u64(v uint64) {
+	var b [8]byte
+	binary.LittleEndian.PutUint64(b[:], v)
+	w.buf = append(w.buf, b[:]...)
+}
+
+// u128 writes a non-negative big.Int as 16 little-endian bytes.
+func (w *borshWriter) u128(v *big.Int) {
+	var b [16]byte
+	if v != nil {
+		be := v.Bytes()
+		for i := 0; i < len(be) && i < 16; i++ {
+			b[i] = be[len(be)-1-i]
+		}
+	}
+	w.buf = append(w.buf, b[:]...)
+}
+
+func (w *borshWriter) fixed(b []byte) {
+	w.buf = append(w.buf, b...)
+}
+
+func (w *borshWriter) bytes(b []byte) {
+	w.u32(uint32(len(b)))
+	w.buf = append(w.buf, b...)
+}
+
+func (w *borshWriter) string(s string) {
+	w.bytes([]byte(s))
+}
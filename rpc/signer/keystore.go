@@ -0,0 +1,108 @@
+package signer
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/rozum-dev/near-go-warchest/common"
+)
+
+const ed25519Prefix = "ed25519:"
+
+// KeyPair is a full access key loaded from a near-shell credentials file.
+type KeyPair struct {
+	AccountId  string
+	PublicKey  ed25519.PublicKey
+	PrivateKey ed25519.PrivateKey
+}
+
+type credentialsFile struct {
+	AccountId  string `json:"account_id"`
+	PublicKey  string `json:"public_key"`
+	PrivateKey string `json:"private_key"`
+	SecretKey  string `json:"secret_key"`
+}
+
+// DefaultCredentialsDir returns ~/.near-credentials.
+func DefaultCredentialsDir() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ".near-credentials"
+	}
+	return filepath.Join(home, ".near-credentials")
+}
+
+// LoadKeyPair reads <dir>/<network>/<accountId>.json.
+func LoadKeyPair(dir, network, accountId string) (*KeyPair, error) {
+	// The id is part of the path
+	if _, err := common.ParseAccountID(accountId); err != nil {
+		return nil, err
+	}
+	path := filepath.Join(dir, network, accountId+".json")
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var f credentialsFile
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	secret := f.PrivateKey
+	if secret == "" {
+		secret = f.SecretKey
+	}
+	if secret == "" {
+		return nil, fmt.Errorf("%s: no private key", path)
+	}
+	priv, err := decodeKey(secret)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	var privateKey ed25519.PrivateKey
+	switch len(priv) {
+	case ed25519.PrivateKeySize:
+		privateKey = ed25519.PrivateKey(priv)
+	case ed25519.SeedSize:
+		privateKey = ed25519.NewKeyFromSeed(priv)
+	default:
+		return nil, fmt.Errorf("%s: unexpected private key length %d", path, len(priv))
+	}
+	publicKey := privateKey.Public().(ed25519.PublicKey)
+	if f.PublicKey != "" {
+		pub, err := decodeKey(f.PublicKey)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", path, err)
+		}
+		if !bytes.Equal(publicKey, pub) {
+			return nil, fmt.Errorf("%s: public key does not match private key", path)
+		}
+	}
+	accountIdInFile := f.AccountId
+	if accountIdInFile == "" {
+		accountIdInFile = accountId
+	}
+	return &KeyPair{
+		AccountId:  accountIdInFile,
+		PublicKey:  publicKey,
+		PrivateKey: privateKey,
+	}, nil
+}
+
+// PublicKeyString returns the key in the "ed25519:<base58>" form used by the RPC.
+func (k *KeyPair) PublicKeyString() string {
+	return ed25519Prefix + Base58Encode(k.PublicKey)
+}
+
+func decodeKey(s string) ([]byte, error) {
+	if !strings.HasPrefix(s, ed25519Prefix) {
+		return nil, errors.New("only ed25519 keys are supported")
+	}
+	return Base58Decode(strings.TrimPrefix(s, ed25519Prefix))
+}
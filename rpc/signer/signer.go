@@ -0,0 +1,132 @@
+package signer
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"sync"
+
+	nearapi "github.com/rozum-dev/near-go-warchest/rpc/client"
+)
+
+// DefaultGas is attached to every staking pool call (200 TGas).
+const DefaultGas uint64 = 200000000000000
+
+// Signer signs function calls with keys from the near-shell credentials
+// directory and sends them through the JSON-RPC client.
+type Signer struct {
+	client         *nearapi.Client
+	credentialsDir string
+	network        string
+
+	mu   sync.Mutex
+	keys map[string]*KeyPair
+	// Last nonce used by each key. The access key is read at the final block,
+	// which lags behind transactions sent a moment ago.
+	nonces map[string]uint64
+}
+
+func NewSigner(client *nearapi.Client, credentialsDir, network string) *Signer {
+	return &Signer{
+		client:         client,
+		credentialsDir: credentialsDir,
+		network:        network,
+		keys:           make(map[string]*KeyPair),
+		nonces:         make(map[string]uint64),
+	}
+}
+
+func (s *Signer) keyPair(accountId string) (*KeyPair, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if k, ok := s.keys[accountId]; ok {
+		return k, nil
+	}
+	k, err := LoadKeyPair(s.credentialsDir, s.network, accountId)
+	if err != nil {
+		return nil, err
+	}
+	s.keys[accountId] = k
+	return k, nil
+}
+
+// nextNonce returns a nonce above both the nonce of the access key and the
+// last one this signer used. Gaps are allowed, so a transaction that never
+// reached the node does not block the next one.
+func (s *Signer) nextNonce(publicKey string, current uint64) uint64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	nonce := current
+	if last := s.nonces[publicKey]; last > nonce {
+		nonce = last
+	}
+	nonce++
+	s.nonces[publicKey] = nonce
+	return nonce
+}
+
+// SignedTx is a serialized SignedTransaction with what is needed to follow it.
+type SignedTx struct {
+	Data      []byte
+	Hash      string
+	PublicKey string
+	Nonce     uint64
+	// Height of the block the transaction refers to. The transaction expires
+	// a validity period of blocks later.
+	BlockHeight uint64
+}
+
+// SignFunctionCall builds and signs a single FunctionCall from signerId to receiverId.
+func (s *Signer) SignFunctionCall(ctx context.Context, signerId, receiverId, method string, args interface{}, deposit *big.Int) (*SignedTx, error) {
+	key, err := s.keyPair(signerId)
+	if err != nil {
+		return nil, err
+	}
+	argsJson, err := json.Marshal(args)
+	if err != nil {
+		return nil, err
+	}
+	ak, err := s.client.ViewAccessKey(ctx, signerId, key.PublicKeyString())
+	if err != nil {
+		return nil, err
+	}
+	blockHash, err := Base58Decode(ak.BlockHash)
+	if err != nil {
+		return nil, fmt.Errorf("block hash %s: %v", ak.BlockHash, err)
+	}
+	tx := &Transaction{
+		SignerId:   signerId,
+		PublicKey:  key.PublicKey,
+		Nonce:      s.nextNonce(key.PublicKeyString(), ak.Nonce),
+		ReceiverId: receiverId,
+		BlockHash:  blockHash,
+		Actions: []FunctionCall{{
+			MethodName: method,
+			Args:       argsJson,
+			Gas:        DefaultGas,
+			Deposit:    deposit,
+		}},
+	}
+	return &SignedTx{
+		Data:        tx.Sign(key.PrivateKey),
+		Hash:        tx.Hash(),
+		PublicKey:   key.PublicKeyString(),
+		Nonce:       tx.Nonce,
+		BlockHeight: ak.BlockHeight,
+	}, nil
+}
+
+// Send broadcasts a signed transaction and waits for its outcome.
+func (s *Signer) Send(ctx context.Context, signedTx []byte) (*nearapi.FinalExecutionOutcome, error) {
+	return s.client.BroadcastTxCommit(ctx, signedTx)
+}
+
+// FunctionCall signs and broadcasts a single FunctionCall from signerId to receiverId.
+func (s *Signer) FunctionCall(ctx context.Context, signerId, receiverId, method string, args interface{}, deposit *big.Int) (*nearapi.FinalExecutionOutcome, error) {
+	signedTx, err := s.SignFunctionCall(ctx, signerId, receiverId, method, args, deposit)
+	if err != nil {
+		return nil, err
+	}
+	return s.client.BroadcastTxCommit(ctx, signedTx.Data)
+}
@@ -0,0 +1,71 @@
+package signer
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"math/big"
+)
+
+const (
+	keyTypeED25519     = 0
+	actionFunctionCall = 2
+)
+
+// FunctionCall is the only action kind the warchest ever sends.
+type FunctionCall struct {
+	MethodName string
+	Args       []byte
+	Gas        uint64
+	Deposit    *big.Int
+}
+
+// Transaction mirrors near_primitives::transaction::Transaction.
+type Transaction struct {
+	SignerId   string
+	PublicKey  ed25519.PublicKey
+	Nonce      uint64
+	ReceiverId string
+	BlockHash  []byte
+	Actions    []FunctionCall
+}
+
+// Serialize returns the Borsh encoding of the transaction.
+func (t *Transaction) Serialize() []byte {
+	w := &borshWriter{}
+	t.serialize(w)
+	return w.buf
+}
+
+func (t *Transaction) serialize(w *borshWriter) {
+	w.string(t.SignerId)
+	w.u8(keyTypeED25519)
+	w.fixed(t.PublicKey)
+	w.u64(t.Nonce)
+	w.string(t.ReceiverId)
+	w.fixed(t.BlockHash)
+	w.u32(uint32(len(t.Actions)))
+	for _, a := range t.Actions {
+		w.u8(actionFunctionCall)
+		w.string(a.MethodName)
+		w.bytes(a.Args)
+		w.u64(a.Gas)
+		w.u128(a.Deposit)
+	}
+}
+
+// Hash returns the base58 transaction hash, which is how the RPC refers to the transaction.
+func (t *Transaction) Hash() string {
+	hash := sha256.Sum256(t.Serialize())
+	return Base58Encode(hash[:])
+}
+
+// Sign hashes the serialized transaction with sha256, signs the hash and
+// returns the Borsh encoding of the SignedTransaction.
+func (t *Transaction) Sign(key ed25519.PrivateKey) []byte {
+	w := &borshWriter{}
+	t.serialize(w)
+	hash := sha256.Sum256(w.buf)
+	w.u8(keyTypeED25519)
+	w.fixed(ed25519.Sign(key, hash[:]))
+	return w.buf
+}
@@ -0,0 +1,116 @@
+package signer
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/hex"
+	"math/big"
+	"testing"
+)
+
+// Transfer of 1 yoctoNEAR from test.near to whatever.near with nonce 1, from
+// the serialization tests of near-api-js
+const nearApiJsTransfer = "09000000746573742e6e65617200917b3d268d4b58f7fec1b150bd68d69be3ee5d4cc39855e341538465bb77860d01000000000000000d00000077686174657665722e6e6561720fa473fd26901df296be6adc4cc4df34d040efa2435224b6986910e630c2fef6010000000301000000000000000000000000000000"
+
+func testTransaction(t *testing.T, actions []FunctionCall) *Transaction {
+	publicKey, err := Base58Decode("Anu7LYDfpLtkP7E16LT9imXF694BdQaa9ufVkQiwTQxC")
+	if err != nil {
+		t.Fatal(err)
+	}
+	blockHash, err := Base58Decode("244ZQ9cgj3CQ6bWBdytfrJMuMQ1jdXLFGnr4HhvtCTnM")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &Transaction{
+		SignerId:   "test.near",
+		PublicKey:  publicKey,
+		Nonce:      1,
+		ReceiverId: "whatever.near",
+		BlockHash:  blockHash,
+		Actions:    actions,
+	}
+}
+
+func TestSerializeMatchesNearApiJs(t *testing.T) {
+	want, _ := hex.DecodeString(nearApiJsTransfer)
+	// Only function calls are supported, so compare everything before the
+	// actions and add the transfer by hand: action 3 with a u128 deposit
+	got := testTransaction(t, nil).Serialize()
+	got = got[:len(got)-4]
+	w := &borshWriter{buf: got}
+	w.u32(1)
+	w.u8(3)
+	w.u128(big.NewInt(1))
+	if hex.EncodeToString(w.buf) != nearApiJsTransfer {
+		t.Errorf("serialized\n%x\nwant\n%x", w.buf, want)
+	}
+}
+
+func TestSerializeFunctionCall(t *testing.T) {
+	deposit, _ := new(big.Int).SetString("1000000000000000000000000", 10)
+	tx := testTransaction(t, []FunctionCall{{
+		MethodName: "stake",
+		Args:       []byte(`{"amount":"1"}`),
+		Gas:        DefaultGas,
+		Deposit:    deposit,
+	}})
+	header := nearApiJsTransfer[:len(nearApiJsTransfer)-2*(4+1+16)]
+	want := header +
+		"01000000" + // one action
+		"02" + // FunctionCall
+		"05000000" + hex.EncodeToString([]byte("stake")) +
+		"0e000000" + hex.EncodeToString([]byte(`{"amount":"1"}`)) +
+		"0080f420e6b50000" + // 200 TGas
+		"000000a1edccce1bc2d3000000000000" // 10^24
+	if got := hex.EncodeToString(tx.Serialize()); got != want {
+		t.Errorf("serialized\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestSignAndHash(t *testing.T) {
+	seed := sha256.Sum256([]byte("warchest"))
+	key := ed25519.NewKeyFromSeed(seed[:])
+	tx := testTransaction(t, []FunctionCall{{MethodName: "ping", Args: []byte("{}"), Gas: DefaultGas}})
+	tx.PublicKey = key.Public().(ed25519.PublicKey)
+
+	serialized := tx.Serialize()
+	hash := sha256.Sum256(serialized)
+	if got := tx.Hash(); got != Base58Encode(hash[:]) {
+		t.Errorf("Hash() = %s, want base58 of sha256 %s", got, Base58Encode(hash[:]))
+	}
+	signed := tx.Sign(key)
+	if len(signed) != len(serialized)+1+ed25519.SignatureSize {
+		t.Fatalf("signed transaction is %d bytes, want %d", len(signed), len(serialized)+1+ed25519.SignatureSize)
+	}
+	if hex.EncodeToString(signed[:len(serialized)]) != hex.EncodeToString(serialized) {
+		t.Error("signed transaction does not start with the transaction")
+	}
+	if signed[len(serialized)] != keyTypeED25519 {
+		t.Errorf("signature key type = %d, want %d", signed[len(serialized)], keyTypeED25519)
+	}
+	if !ed25519.Verify(tx.PublicKey, hash[:], signed[len(serialized)+1:]) {
+		t.Error("signature does not verify against the transaction hash")
+	}
+}
+
+func TestNextNonce(t *testing.T) {
+	s := NewSigner(nil, "", "")
+	tests := []struct {
+		current, want uint64
+	}{
+		// The final block does not show the first transaction yet
+		{10, 11},
+		{10, 12},
+		// The node moved past us, for example a transaction from near-shell
+		{20, 21},
+		{12, 22},
+	}
+	for _, tt := range tests {
+		if got := s.nextNonce("ed25519:key", tt.current); got != tt.want {
+			t.Errorf("nextNonce(%d) = %d, want %d", tt.current, got, tt.want)
+		}
+	}
+	if got := s.nextNonce("ed25519:other", 5); got != 6 {
+		t.Errorf("nonces of other keys are independent, got %d", got)
+	}
+}
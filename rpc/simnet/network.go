@@ -0,0 +1,445 @@
+// Package simnet is an in-process NEAR network for end-to-end runs of the
+// warchest. It serves the JSON-RPC methods the warchest uses, advances
+// blocks and epochs only when told to, runs staking pool contracts and
+// selects validators at epoch boundaries, all deterministically.
+//
+//	net := simnet.New(simnet.DefaultConfig())
+//	srv := httptest.NewServer(net)
+//	net.AddAccount("owner", common.NearAmount(100), pub)
+//	net.AddPool("pool", "owner", "ed25519:...")
+//	net.AdvanceEpochs(1)
+package simnet
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/binary"
+	"math/big"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/rozum-dev/near-go-warchest/common"
+	"github.com/rozum-dev/near-go-warchest/rpc/signer"
+)
+
+// Epochs before unstaked tokens can be withdrawn, as in the staking pool contract
+const NumEpochsToUnlock = 4
+
+type Config struct {
+	ChainId         string
+	EpochLength     int64
+	NumSeats        int
+	ProtocolVersion int
+	// Validators below stake * ratio do not get a seat
+	MinimumStakeRatio [2]int64
+	// Percent of expected blocks and chunks a validator must produce
+	KickoutThreshold int
+	Genesis          time.Time
+	BlockTime        time.Duration
+}
+
+// DefaultConfig is a small network with short epochs. Protocol version 40
+// uses the seat price search of the protocol before version 49.
+func DefaultConfig() Config {
+	return Config{
+		ChainId:           "simnet",
+		EpochLength:       100,
+		NumSeats:          10,
+		ProtocolVersion:   40,
+		MinimumStakeRatio: [2]int64{1, 6250},
+		KickoutThreshold:  90,
+		Genesis:           time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC),
+		BlockTime:         time.Second,
+	}
+}
+
+type accessKey struct {
+	publicKey ed25519.PublicKey
+	nonce     uint64
+}
+
+type account struct {
+	balance common.Amount
+	keys    map[string]*accessKey
+}
+
+type validator struct {
+	id                             string
+	stake                          common.Amount
+	producedBlocks, expectedBlocks int64
+	producedChunks, expectedChunks int64
+}
+
+type kickout struct {
+	id     string
+	reason interface{}
+}
+
+// Call is a function call applied by a transaction.
+type Call struct {
+	Height     int64
+	Hash       string
+	SignerId   string
+	ReceiverId string
+	Method     string
+	Args       string
+	Deposit    common.Amount
+	Error      string
+}
+
+// Network is the state of the simulated chain. It is safe for concurrent use.
+type Network struct {
+	mu          sync.Mutex
+	cfg         Config
+	height      int64
+	epochStart  int64
+	epoch       int64
+	current     []*validator
+	next        []*validator
+	proposals   map[string]common.Amount
+	kickouts    []kickout
+	accounts    map[string]*account
+	pools       map[string]*Pool
+	offline     map[string]bool
+	unavailable bool
+	outcomes    map[string]interface{}
+	calls       []Call
+}
+
+func New(cfg Config) *Network {
+	return &Network{
+		cfg:        cfg,
+		height:     1,
+		epochStart: 1,
+		proposals:  make(map[string]common.Amount),
+		accounts:   make(map[string]*account),
+		pools:      make(map[string]*Pool),
+		offline:    make(map[string]bool),
+		outcomes:   make(map[string]interface{}),
+	}
+}
+
+// AddAccount creates an account with a full access key.
+func (n *Network) AddAccount(id string, balance common.Amount, publicKey ed25519.PublicKey) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	a := n.account(id)
+	a.balance = balance
+	if publicKey != nil {
+		a.keys[keyString(publicKey)] = &accessKey{publicKey: publicKey}
+	}
+}
+
+func (n *Network) account(id string) *account {
+	a, ok := n.accounts[id]
+	if !ok {
+		a = &account{keys: make(map[string]*accessKey)}
+		n.accounts[id] = a
+	}
+	return a
+}
+
+// AddValidator adds a validator with a fixed stake to the current and the
+// next epoch, to compete with the pools for seats.
+func (n *Network) AddValidator(id string, stake common.Amount) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.account(id)
+	n.current = append(n.current, &validator{id: id, stake: stake})
+	n.next = append(n.next, &validator{id: id, stake: stake})
+}
+
+// Propose sends a staking proposal for an account, as a validator outside a
+// pool would.
+func (n *Network) Propose(id string, stake common.Amount) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.proposals[id] = stake
+}
+
+// SetOffline stops a validator from producing blocks and chunks.
+func (n *Network) SetOffline(id string, offline bool) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.offline[id] = offline
+}
+
+// SetUnavailable makes the RPC answer 503 to everything.
+func (n *Network) SetUnavailable(unavailable bool) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.unavailable = unavailable
+}
+
+func (n *Network) Height() int64 {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.height
+}
+
+func (n *Network) EpochStartHeight() int64 {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.epochStart
+}
+
+// Balance returns the liquid balance of an account.
+func (n *Network) Balance(id string) common.Amount {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.account(id).balance
+}
+
+// Calls returns every function call applied so far.
+func (n *Network) Calls() []Call {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	out := make([]Call, len(n.calls))
+	copy(out, n.calls)
+	return out
+}
+
+// Validators returns the stakes of the current and the next epoch validators.
+func (n *Network) Validators() (current, next map[string]common.Amount) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	current = make(map[string]common.Amount)
+	next = make(map[string]common.Amount)
+	for _, v := range n.current {
+		current[v.id] = v.stake
+	}
+	for _, v := range n.next {
+		next[v.id] = v.stake
+	}
+	return current, next
+}
+
+// Advance produces blocks, crossing epoch boundaries as needed.
+func (n *Network) Advance(blocks int64) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	for i := int64(0); i < blocks; i++ {
+		n.height++
+		if n.height == n.epochStart+n.cfg.EpochLength {
+			n.newEpoch()
+		}
+		n.produce()
+	}
+}
+
+// AdvanceEpochs produces blocks up to the start of the k-th next epoch.
+func (n *Network) AdvanceEpochs(k int) {
+	n.mu.Lock()
+	left := n.epochStart + n.cfg.EpochLength - n.height
+	n.mu.Unlock()
+	n.Advance(left + int64(k-1)*n.cfg.EpochLength)
+}
+
+// produce picks the block and chunk producers of the new height by stake.
+func (n *Network) produce() {
+	if v := n.pick(0); v != nil {
+		v.expectedBlocks++
+		if !n.offline[v.id] {
+			v.producedBlocks++
+		}
+	}
+	if v := n.pick(1); v != nil {
+		v.expectedChunks++
+		if !n.offline[v.id] {
+			v.producedChunks++
+		}
+	}
+}
+
+// pick chooses a current validator with a probability proportional to its
+// stake, from a hash of the height.
+func (n *Network) pick(salt byte) *validator {
+	var total common.Amount
+	for _, v := range n.current {
+		total = total.Add(v.stake)
+	}
+	if total.Sign() <= 0 {
+		return nil
+	}
+	var seed [9]byte
+	binary.LittleEndian.PutUint64(seed[:8], uint64(n.height))
+	seed[8] = salt
+	hash := sha256.Sum256(seed[:])
+	r := new(big.Int).Mod(new(big.Int).SetBytes(hash[:]), total.BigInt())
+	var sum common.Amount
+	for _, v := range n.current {
+		sum = sum.Add(v.stake)
+		if common.NewAmount(r).Cmp(sum) < 0 {
+			return v
+		}
+	}
+	return n.current[len(n.current)-1]
+}
+
+// newEpoch kicks out the validators which produced too little, moves the
+// next validators in and selects the validators of the epoch after from the
+// proposals and the stakes rolled over from the next validators.
+func (n *Network) newEpoch() {
+	n.kickouts = nil
+	kicked := make(map[string]bool)
+	for _, v := range n.current {
+		if v.expectedBlocks > 0 && v.producedBlocks*100 < v.expectedBlocks*int64(n.cfg.KickoutThreshold) {
+			kicked[v.id] = true
+			n.kickouts = append(n.kickouts, kickout{v.id, map[string]interface{}{
+				"NotEnoughBlocks": map[string]int64{"produced": v.producedBlocks, "expected": v.expectedBlocks},
+			}})
+		} else if v.expectedChunks > 0 && v.producedChunks*100 < v.expectedChunks*int64(n.cfg.KickoutThreshold) {
+			kicked[v.id] = true
+			n.kickouts = append(n.kickouts, kickout{v.id, map[string]interface{}{
+				"NotEnoughChunks": map[string]int64{"produced": v.producedChunks, "expected": v.expectedChunks},
+			}})
+		}
+	}
+
+	stakes := make(map[string]common.Amount)
+	wasNext := make(map[string]bool)
+	for _, v := range n.next {
+		stakes[v.id] = v.stake
+		wasNext[v.id] = true
+	}
+	for id, stake := range n.proposals {
+		stakes[id] = stake
+	}
+	var ids []string
+	for id, stake := range stakes {
+		if kicked[id] {
+			continue
+		}
+		if stake.Sign() <= 0 {
+			if wasNext[id] {
+				n.kickouts = append(n.kickouts, kickout{id, "Unstaked"})
+			}
+			continue
+		}
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		if c := stakes[ids[i]].Cmp(stakes[ids[j]]); c != 0 {
+			return c > 0
+		}
+		return ids[i] < ids[j]
+	})
+	var candidates []common.Amount
+	for _, id := range ids {
+		candidates = append(candidates, stakes[id])
+	}
+	seated, price := n.selectValidators(candidates)
+
+	n.current = n.next
+	n.next = nil
+	for i, id := range ids {
+		if i < seated {
+			n.next = append(n.next, &validator{id: id, stake: stakes[id]})
+			continue
+		}
+		if wasNext[id] {
+			n.kickouts = append(n.kickouts, kickout{id, "DidNotGetASeat"})
+		} else {
+			n.kickouts = append(n.kickouts, kickout{id, map[string]interface{}{
+				"NotEnoughStake": map[string]string{"stake_u128": stakes[id].Yocto(), "threshold_u128": price.Yocto()},
+			}})
+		}
+	}
+	n.proposals = make(map[string]common.Amount)
+	n.epochStart = n.height
+	n.epoch++
+}
+
+// selectValidators returns how many of the stakes, sorted from the largest,
+// get a seat and the seat price. From protocol version 49 it follows
+// select_validators of nearcore: the largest stakes are taken while each is
+// above the minimum ratio of the stake taken with it. With every seat taken
+// the price is just above the smallest stake taken, otherwise it is the
+// stake which would pass the ratio. Before, the price is the highest at
+// which the stakes buy all the seats.
+func (n *Network) selectValidators(stakes []common.Amount) (int, common.Amount) {
+	if n.cfg.ProtocolVersion < 49 {
+		price := n.seatPriceBefore49(stakes)
+		var seated int
+		for seated < len(stakes) && stakes[seated].Cmp(price) >= 0 {
+			seated++
+		}
+		return seated, price
+	}
+	num, den := big.NewInt(n.cfg.MinimumStakeRatio[0]), big.NewInt(n.cfg.MinimumStakeRatio[1])
+	total := new(big.Int)
+	var seated int
+	for seated < len(stakes) && seated < n.cfg.NumSeats {
+		stake := stakes[seated].BigInt()
+		withStake := new(big.Int).Add(total, stake)
+		// stake / withStake > num / den
+		if new(big.Int).Mul(stake, den).Cmp(new(big.Int).Mul(withStake, num)) <= 0 {
+			break
+		}
+		total = withStake
+		seated++
+	}
+	if seated > 0 && seated == n.cfg.NumSeats {
+		return seated, stakes[seated-1].Add(common.NewAmount(big.NewInt(1)))
+	}
+	// ceil(num * total / (den - num))
+	price, rem := new(big.Int).QuoRem(new(big.Int).Mul(num, total), new(big.Int).Sub(den, num), new(big.Int))
+	if rem.Sign() > 0 {
+		price.Add(price, big.NewInt(1))
+	}
+	return seated, common.NewAmount(price)
+}
+
+// seatPriceBefore49 searches the highest price at which the stakes buy all
+// the seats. Stakes below the number of seats buy nothing, the price is 1 yocto.
+func (n *Network) seatPriceBefore49(stakes []common.Amount) common.Amount {
+	var total common.Amount
+	for _, s := range stakes {
+		total = total.Add(s)
+	}
+	one := big.NewInt(1)
+	seats := big.NewInt(int64(n.cfg.NumSeats))
+	if total.BigInt().Cmp(seats) < 0 {
+		return common.NewAmount(one)
+	}
+	left := big.NewInt(1)
+	right := new(big.Int).Add(total.BigInt(), one)
+	for new(big.Int).Sub(right, left).Cmp(one) > 0 {
+		mid := new(big.Int).Rsh(new(big.Int).Add(left, right), 1)
+		sum := new(big.Int)
+		for _, s := range stakes {
+			sum.Add(sum, new(big.Int).Div(s.BigInt(), mid))
+		}
+		if sum.Cmp(seats) >= 0 {
+			left = mid
+		} else {
+			right = mid
+		}
+	}
+	return common.NewAmount(left)
+}
+
+func (n *Network) blockHash(height int64) []byte {
+	var b [8]byte
+	binary.LittleEndian.PutUint64(b[:], uint64(height))
+	hash := sha256.Sum256(append([]byte(n.cfg.ChainId), b[:]...))
+	return hash[:]
+}
+
+func (n *Network) blockTime(height int64) time.Time {
+	return n.cfg.Genesis.Add(time.Duration(height) * n.cfg.BlockTime)
+}
+
+func sortedIds(stakes map[string]common.Amount) []string {
+	var ids []string
+	for id := range stakes {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+func keyString(publicKey ed25519.PublicKey) string {
+	return "ed25519:" + signer.Base58Encode(publicKey)
+}
@@ -0,0 +1,107 @@
+package simnet_test
+
+import (
+	"context"
+	"math/big"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/rozum-dev/near-go-warchest/common"
+	nearapi "github.com/rozum-dev/near-go-warchest/rpc/client"
+	"github.com/rozum-dev/near-go-warchest/rpc/simnet"
+)
+
+func TestValidatorSelection(t *testing.T) {
+	near := common.NearAmount
+	// ceil(100 NEAR / 6249), the stake which passes the ratio next to 100 NEAR
+	ratioPrice := new(big.Int).Add(near(100).BigInt(), big.NewInt(6248))
+	ratioPrice.Quo(ratioPrice, big.NewInt(6249))
+	tests := []struct {
+		name            string
+		protocolVersion int
+		numSeats        int
+		proposals       map[string]common.Amount
+		next            []string
+		// Left without a seat and the threshold it was told
+		kickedOut string
+		threshold common.Amount
+	}{
+		{
+			// The smallest stake taken is below the price, which is for the next one
+			name:            "every seat taken",
+			protocolVersion: 49,
+			numSeats:        2,
+			proposals:       map[string]common.Amount{"a": near(300), "b": near(200), "c": near(100)},
+			next:            []string{"a", "b"},
+			kickedOut:       "c",
+			threshold:       near(200).Add(common.NewAmount(big.NewInt(1))),
+		},
+		{
+			name:            "seats left by the minimum stake ratio",
+			protocolVersion: 49,
+			numSeats:        10,
+			proposals:       map[string]common.Amount{"a": near(100), "b": common.NewAmount(new(big.Int).Sub(ratioPrice, big.NewInt(1)))},
+			next:            []string{"a"},
+			kickedOut:       "b",
+			threshold:       common.NewAmount(ratioPrice),
+		},
+		{
+			name:            "fewer proposals than seats",
+			protocolVersion: 49,
+			numSeats:        10,
+			proposals:       map[string]common.Amount{"a": near(100), "b": near(1)},
+			next:            []string{"a", "b"},
+		},
+		{
+			// 10 seats at 10 NEAR, the highest price at which the stakes buy them all
+			name:            "before protocol 49",
+			protocolVersion: 40,
+			numSeats:        10,
+			proposals:       map[string]common.Amount{"a": near(100), "b": near(5)},
+			next:            []string{"a"},
+			kickedOut:       "b",
+			threshold:       near(10),
+		},
+	}
+	for _, tt := range tests {
+		cfg := simnet.DefaultConfig()
+		cfg.BlockTime = 0
+		cfg.ProtocolVersion = tt.protocolVersion
+		cfg.NumSeats = tt.numSeats
+		net := simnet.New(cfg)
+		for id, stake := range tt.proposals {
+			net.Propose(id, stake)
+		}
+		net.AdvanceEpochs(1)
+
+		_, next := net.Validators()
+		var ids []string
+		for id := range next {
+			ids = append(ids, id)
+		}
+		sort.Strings(ids)
+		if !reflect.DeepEqual(ids, tt.next) {
+			t.Errorf("%s: next validators %v, want %v", tt.name, ids, tt.next)
+		}
+
+		srv := httptest.NewServer(net)
+		vr, err := nearapi.NewClientWithContext(context.Background(), srv.URL).Validators(context.Background(), 0)
+		srv.Close()
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		var kickedOut string
+		for _, k := range vr.PrevEpochKickOut {
+			kickedOut = k.AccountId
+			r, ok := k.Reason.(nearapi.NotEnoughStake)
+			if !ok || r.Threshold.Cmp(tt.threshold) != 0 {
+				t.Errorf("%s: %s kicked out for %v, want a threshold of %s", tt.name, k.AccountId, k.Reason, tt.threshold.Yocto())
+			}
+		}
+		if kickedOut != tt.kickedOut || len(vr.PrevEpochKickOut) > 1 {
+			t.Errorf("%s: kicked out %+v, want %q", tt.name, vr.PrevEpochKickOut, tt.kickedOut)
+		}
+	}
+}
@@ -0,0 +1,261 @@
+package simnet
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/rozum-dev/near-go-warchest/common"
+)
+
+type poolAccount struct {
+	staked, unstaked common.Amount
+	// Epoch from which the unstaked balance can be withdrawn
+	availableEpoch int64
+}
+
+// Pool is a staking pool contract. Balances are kept in tokens rather than
+// shares, and there are no rewards.
+type Pool struct {
+	id, owner  string
+	stakingKey string
+	rewardFee  [2]int64
+	paused     bool
+	accounts   map[string]*poolAccount
+}
+
+// AddPool deploys a staking pool owned by owner.
+func (n *Network) AddPool(id, owner, stakingKey string) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.account(id)
+	n.pools[id] = &Pool{
+		id:         id,
+		owner:      owner,
+		stakingKey: stakingKey,
+		rewardFee:  [2]int64{10, 100},
+		accounts:   make(map[string]*poolAccount),
+	}
+}
+
+// PoolBalance returns the staked and unstaked balances of an account in a pool.
+func (n *Network) PoolBalance(poolId, accountId string) (staked, unstaked common.Amount) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	p, ok := n.pools[poolId]
+	if !ok {
+		return
+	}
+	a := p.account(accountId)
+	return a.staked, a.unstaked
+}
+
+// Deposit adds unstaked tokens of an account to a pool without a transaction.
+func (n *Network) Deposit(poolId, accountId string, amount common.Amount) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if p, ok := n.pools[poolId]; ok {
+		a := p.account(accountId)
+		a.unstaked = a.unstaked.Add(amount)
+	}
+}
+
+// SetPoolPaused pauses or resumes staking, as the owner would.
+func (n *Network) SetPoolPaused(poolId string, paused bool) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if p, ok := n.pools[poolId]; ok {
+		p.paused = paused
+		n.restake(p)
+	}
+}
+
+// SetRewardFee changes the reward fee fraction, as the owner would.
+func (n *Network) SetRewardFee(poolId string, numerator, denominator int64) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if p, ok := n.pools[poolId]; ok {
+		p.rewardFee = [2]int64{numerator, denominator}
+	}
+}
+
+// SetStakingKey changes the staking key, as the owner would.
+func (n *Network) SetStakingKey(poolId, key string) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if p, ok := n.pools[poolId]; ok {
+		p.stakingKey = key
+	}
+}
+
+func (p *Pool) account(id string) *poolAccount {
+	a, ok := p.accounts[id]
+	if !ok {
+		a = &poolAccount{}
+		p.accounts[id] = a
+	}
+	return a
+}
+
+func (p *Pool) totalStaked() common.Amount {
+	var total common.Amount
+	for _, a := range p.accounts {
+		total = total.Add(a.staked)
+	}
+	return total
+}
+
+// restake proposes the total stake, or nothing while staking is paused.
+func (n *Network) restake(p *Pool) {
+	if p.paused {
+		n.proposals[p.id] = common.Amount{}
+		return
+	}
+	n.proposals[p.id] = p.totalStaked()
+}
+
+type amountArgs struct {
+	Amount *common.Amount `json:"amount"`
+	// Views
+	AccountId string `json:"account_id"`
+}
+
+func parseArgs(args []byte) (amountArgs, error) {
+	var a amountArgs
+	if len(args) == 0 {
+		return a, nil
+	}
+	err := json.Unmarshal(args, &a)
+	return a, err
+}
+
+// callPool runs a change method. The deposit is already taken from the signer.
+func (n *Network) callPool(p *Pool, signerId, method string, rawArgs []byte, deposit common.Amount) error {
+	args, err := parseArgs(rawArgs)
+	if err != nil {
+		return fmt.Errorf("failed to parse arguments: %v", err)
+	}
+	if method != "deposit_and_stake" && method != "deposit" && deposit.Sign() > 0 {
+		return errors.New("method doesn't accept deposit")
+	}
+	a := p.account(signerId)
+	amount := func() (common.Amount, error) {
+		if args.Amount == nil || args.Amount.Sign() <= 0 {
+			return common.Amount{}, errors.New("the amount should be positive")
+		}
+		return *args.Amount, nil
+	}
+	switch method {
+	case "ping":
+		n.restake(p)
+	case "deposit":
+		a.unstaked = a.unstaked.Add(deposit)
+	case "deposit_and_stake":
+		if deposit.Sign() <= 0 {
+			return errors.New("the deposit should be positive")
+		}
+		a.staked = a.staked.Add(deposit)
+		n.restake(p)
+	case "stake":
+		v, err := amount()
+		if err != nil {
+			return err
+		}
+		if a.unstaked.Cmp(v) < 0 {
+			return errors.New("not enough unstaked balance to stake")
+		}
+		a.unstaked = a.unstaked.Sub(v)
+		a.staked = a.staked.Add(v)
+		n.restake(p)
+	case "stake_all":
+		a.staked = a.staked.Add(a.unstaked)
+		a.unstaked = common.Amount{}
+		n.restake(p)
+	case "unstake":
+		v, err := amount()
+		if err != nil {
+			return err
+		}
+		if a.staked.Cmp(v) < 0 {
+			return errors.New("not enough staked balance to unstake")
+		}
+		a.staked = a.staked.Sub(v)
+		a.unstaked = a.unstaked.Add(v)
+		a.availableEpoch = n.epoch + NumEpochsToUnlock
+		n.restake(p)
+	case "unstake_all":
+		a.unstaked = a.unstaked.Add(a.staked)
+		a.staked = common.Amount{}
+		a.availableEpoch = n.epoch + NumEpochsToUnlock
+		n.restake(p)
+	case "withdraw", "withdraw_all":
+		v := a.unstaked
+		if method == "withdraw" {
+			if v, err = amount(); err != nil {
+				return err
+			}
+		}
+		if a.unstaked.Cmp(v) < 0 {
+			return errors.New("not enough unstaked balance to withdraw")
+		}
+		if n.epoch < a.availableEpoch {
+			return errors.New("the unstaked balance is not yet available due to unstaking delay")
+		}
+		a.unstaked = a.unstaked.Sub(v)
+		n.account(signerId).balance = n.account(signerId).balance.Add(v)
+	case "pause_staking", "resume_staking":
+		if signerId != p.owner {
+			return errors.New("can only be called by the owner")
+		}
+		p.paused = method == "pause_staking"
+		n.restake(p)
+	default:
+		return fmt.Errorf("method %s not found", method)
+	}
+	return nil
+}
+
+// viewPool runs a view method.
+func (n *Network) viewPool(p *Pool, method string, rawArgs []byte) (interface{}, error) {
+	args, err := parseArgs(rawArgs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse arguments: %v", err)
+	}
+	a := p.account(args.AccountId)
+	switch method {
+	case "get_account_staked_balance":
+		return a.staked, nil
+	case "get_account_unstaked_balance":
+		return a.unstaked, nil
+	case "get_account_total_balance":
+		return a.staked.Add(a.unstaked), nil
+	case "is_account_unstaked_balance_available":
+		return n.epoch >= a.availableEpoch, nil
+	case "get_account":
+		return map[string]interface{}{
+			"account_id":       args.AccountId,
+			"staked_balance":   a.staked,
+			"unstaked_balance": a.unstaked,
+			"can_withdraw":     n.epoch >= a.availableEpoch,
+		}, nil
+	case "get_total_staked_balance":
+		return p.totalStaked(), nil
+	case "get_number_of_accounts":
+		count := 0
+		for _, a := range p.accounts {
+			if !a.staked.IsZero() || !a.unstaked.IsZero() {
+				count++
+			}
+		}
+		return count, nil
+	case "get_owner_id":
+		return p.owner, nil
+	case "get_staking_key":
+		return p.stakingKey, nil
+	case "get_reward_fee_fraction":
+		return map[string]int64{"numerator": p.rewardFee[0], "denominator": p.rewardFee[1]}, nil
+	case "is_staking_paused":
+		return p.paused, nil
+	}
+	return nil, fmt.Errorf("MethodNotFound: %s", method)
+}
@@ -0,0 +1,438 @@
+package simnet_test
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rozum-dev/near-go-warchest/common"
+	runner "github.com/rozum-dev/near-go-warchest/near-shell/runner"
+	"github.com/rozum-dev/near-go-warchest/rpc"
+	nearapi "github.com/rozum-dev/near-go-warchest/rpc/client"
+	"github.com/rozum-dev/near-go-warchest/rpc/signer"
+	"github.com/rozum-dev/near-go-warchest/rpc/simnet"
+	"github.com/rozum-dev/near-go-warchest/services/notifier"
+	prom "github.com/rozum-dev/near-go-warchest/services/prometheus"
+	"github.com/rozum-dev/near-go-warchest/strategy"
+)
+
+const (
+	poolId  = "pool.simnet"
+	ownerId = "owner.simnet"
+	otherId = "other.simnet"
+)
+
+type alerts struct {
+	mu     sync.Mutex
+	events []notifier.Event
+}
+
+func (a *alerts) Notify(ctx context.Context, e notifier.Event) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.events = append(a.events, e)
+	return nil
+}
+
+func (a *alerts) count(kind string) int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	var c int
+	for _, e := range a.events {
+		if e.Kind == kind {
+			c++
+		}
+	}
+	return c
+}
+
+// hold decides nothing, so only pings and alerts reach the network.
+type hold struct{}
+
+func (hold) Name() string                                { return "hold" }
+func (hold) Decide(*strategy.Snapshot) []strategy.Intent { return nil }
+
+// scenario runs a Monitor against a simnet served over HTTP, as the warchest
+// runs it, and optionally a Runner fed by the monitor. Both wait on
+// unbuffered semaphores which only the test takes and gives back, so each
+// tick runs when the test asks for it and the network only changes between
+// ticks.
+type scenario struct {
+	t       *testing.T
+	ctx     context.Context
+	net     *simnet.Network
+	client  *nearapi.Client
+	signer  *signer.Signer
+	alerts  *alerts
+	metrics *prom.PoolMetrics
+	wg      sync.WaitGroup
+
+	monitorSem common.Sem
+	results    chan *rpc.SubscrResult
+	runnerSem  common.Sem
+	runner     chan *rpc.SubscrResult
+}
+
+// newScenario starts the monitor on a network with the pool, its owner with
+// 1000 NEAR and 100 NEAR deposited in the pool and another validator with 100 NEAR.
+func newScenario(t *testing.T) *scenario {
+	dir, err := ioutil.TempDir("", "simnet")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	cfg := simnet.DefaultConfig()
+	cfg.BlockTime = 0
+	net := simnet.New(cfg)
+	pub, err := simnet.WriteKey(dir, cfg.ChainId, ownerId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	net.AddAccount(ownerId, common.NearAmount(1000), pub)
+	net.AddPool(poolId, ownerId, "ed25519:"+poolId)
+	net.Deposit(poolId, ownerId, common.NearAmount(100))
+	net.AddValidator(otherId, common.NearAmount(100))
+	srv := httptest.NewServer(net)
+	t.Cleanup(srv.Close)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	client := nearapi.NewClientWithContext(ctx, srv.URL)
+	s := &scenario{
+		t:          t,
+		ctx:        ctx,
+		net:        net,
+		client:     client,
+		signer:     signer.NewSigner(client, dir, cfg.ChainId),
+		alerts:     &alerts{},
+		metrics:    prom.NewPromMetrics().Pool(poolId),
+		monitorSem: make(common.Sem),
+		results:    make(chan *rpc.SubscrResult),
+		runnerSem:  make(common.Sem),
+	}
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		rpc.NewMonitor(client, poolId, time.Millisecond, s.alerts, nil).Run(ctx, s.results, s.monitorSem, s.metrics)
+	}()
+	t.Cleanup(func() { s.stop(cancel) })
+	return s
+}
+
+// stop cancels the scenario and lets the monitor and the runner run until
+// they see it.
+func (s *scenario) stop(cancel context.CancelFunc) {
+	cancel()
+	stopped := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(stopped)
+	}()
+	for {
+		select {
+		case <-s.monitorSem:
+		case s.monitorSem <- struct{}{}:
+		case <-s.results:
+		case <-s.runnerSem:
+		case s.runnerSem <- struct{}{}:
+		case <-stopped:
+			return
+		}
+	}
+}
+
+// startRunner runs a Runner for the owner on the monitor results.
+func (s *scenario) startRunner(st strategy.Strategy) {
+	s.runner = make(chan *rpc.SubscrResult)
+	r := runner.NewRunner(s.client, poolId, []string{ownerId}, runner.NewNativeExecutor(s.signer, nil), st, runner.Options{
+		Notifier: s.alerts,
+		Reserve:  common.NearAmount(10),
+	})
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		r.Run(s.ctx, s.runner, s.metrics, s.runnerSem)
+	}()
+}
+
+// tick runs one tick of the monitor, and of the runner on its result.
+func (s *scenario) tick() *rpc.SubscrResult {
+	// The semaphores are unbuffered, so receiving lets a tick start and
+	// sending waits for it to end
+	<-s.monitorSem
+	s.monitorSem <- struct{}{}
+	res := <-s.results
+	if s.runner != nil {
+		s.runner <- res
+		<-s.runnerSem
+		s.runnerSem <- struct{}{}
+	}
+	return res
+}
+
+// call sends a function call to the pool from the owner, as done outside the warchest.
+func (s *scenario) call(method string, amount int64) {
+	args := map[string]string{}
+	if amount > 0 {
+		args["amount"] = common.NearAmount(amount).Yocto()
+	}
+	if _, err := s.signer.FunctionCall(s.ctx, ownerId, poolId, method, args, nil); err != nil {
+		s.t.Fatalf("%s: %v", method, err)
+	}
+}
+
+// seat stakes the deposit and waits until the pool is a current validator.
+func (s *scenario) seat() {
+	s.call("stake", 100)
+	s.net.AdvanceEpochs(2)
+	if res := s.tick(); res.CurrentStake.Cmp(common.NearAmount(100)) != 0 {
+		s.t.Fatalf("current stake %s, want 100 NEAR", res.CurrentStake)
+	}
+}
+
+func (s *scenario) pings() int {
+	var c int
+	for _, call := range s.net.Calls() {
+		if call.Method == "ping" && call.ReceiverId == poolId && call.Error == "" {
+			c++
+		}
+	}
+	return c
+}
+
+func TestKickoutScenario(t *testing.T) {
+	s := newScenario(t)
+	s.seat()
+
+	s.net.SetOffline(poolId, true)
+	s.net.AdvanceEpochs(1)
+	res := s.tick()
+	if !res.KickedOut || res.Kickout == nil || res.Kickout.Kind != nearapi.NotEnoughBlocksKind {
+		t.Fatalf("kickout %+v, want %s", res.Kickout, nearapi.NotEnoughBlocksKind)
+	}
+	if n := s.alerts.count(notifier.KickedOut); n != 1 {
+		t.Fatalf("%d kickout alerts, want 1", n)
+	}
+
+	// One alert per epoch
+	s.net.Advance(1)
+	s.tick()
+	if n := s.alerts.count(notifier.KickedOut); n != 1 {
+		t.Errorf("%d kickout alerts, want 1", n)
+	}
+}
+
+func TestSeatLossScenario(t *testing.T) {
+	s := newScenario(t)
+	s.seat()
+	if n := s.alerts.count(notifier.SeatLost); n != 0 {
+		t.Fatalf("%d seat loss alerts for a seated pool", n)
+	}
+
+	// A large proposal raises the seat price above the stake of the pool
+	s.net.Propose("whale.simnet", common.NearAmount(100000))
+	s.net.AdvanceEpochs(1)
+	res := s.tick()
+	if res.CurrentStake.IsZero() || !res.NextStake.IsZero() {
+		t.Fatalf("current stake %s, next stake %s", res.CurrentStake, res.NextStake)
+	}
+	if n := s.alerts.count(notifier.SeatLost); n != 1 {
+		t.Errorf("%d seat loss alerts, want 1", n)
+	}
+}
+
+func TestPingScenario(t *testing.T) {
+	s := newScenario(t)
+	s.startRunner(hold{})
+	s.tick()
+	if n := s.pings(); n != 0 {
+		t.Fatalf("%d pings before a new epoch", n)
+	}
+
+	for epochs := 1; epochs <= 2; epochs++ {
+		s.net.AdvanceEpochs(1)
+		s.tick()
+		if n := s.pings(); n != epochs {
+			t.Fatalf("%d pings after %d epochs", n, epochs)
+		}
+		// Later ticks of the epoch do not ping again
+		s.net.Advance(1)
+		s.tick()
+		if n := s.pings(); n != epochs {
+			t.Errorf("%d pings after %d epochs", n, epochs)
+		}
+	}
+	if n := s.alerts.count(notifier.PingFailed); n != 0 {
+		t.Errorf("%d failed pings", n)
+	}
+}
+
+func TestUnlockScenario(t *testing.T) {
+	s := newScenario(t)
+	s.call("stake", 100)
+	s.call("unstake", 40)
+	s.startRunner(hold{})
+	s.tick()
+	if n := s.alerts.count(notifier.UnstakeUnlocked); n != 0 {
+		t.Fatalf("%d unlock alerts for a locked balance", n)
+	}
+
+	s.net.AdvanceEpochs(simnet.NumEpochsToUnlock - 1)
+	s.tick()
+	if n := s.alerts.count(notifier.UnstakeUnlocked); n != 0 {
+		t.Fatalf("%d unlock alerts before the unstaking delay", n)
+	}
+
+	s.net.AdvanceEpochs(1)
+	s.tick()
+	if n := s.alerts.count(notifier.UnstakeUnlocked); n != 1 {
+		t.Fatalf("%d unlock alerts, want 1", n)
+	}
+	s.net.Advance(1)
+	s.tick()
+	if n := s.alerts.count(notifier.UnstakeUnlocked); n != 1 {
+		t.Errorf("%d unlock alerts, want 1", n)
+	}
+}
+
+// call is a pool call of the owner: the method and the amount in the
+// arguments, or the attached deposit.
+type call struct {
+	method string
+	amount string
+}
+
+// ownerCalls returns the successful calls of the owner after the first n calls.
+func (s *scenario) ownerCalls(n int) []call {
+	var out []call
+	for _, c := range s.net.Calls()[n:] {
+		if c.SignerId != ownerId || c.ReceiverId != poolId || c.Error != "" {
+			continue
+		}
+		var args struct {
+			Amount *common.Amount `json:"amount"`
+		}
+		if err := json.Unmarshal([]byte(c.Args), &args); err != nil {
+			s.t.Fatalf("%s arguments %s: %v", c.Method, c.Args, err)
+		}
+		amount := c.Deposit
+		if args.Amount != nil {
+			amount = *args.Amount
+		}
+		out = append(out, call{c.Method, amount.Yocto()})
+	}
+	return out
+}
+
+func yocto(t *testing.T, near string) string {
+	a, err := common.ParseNear(near)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return a.Yocto()
+}
+
+// TestStrategyScenarios runs the built-in strategies on the seat prices of
+// the network: the other validator holds 100 NEAR of the 10 seats, so the
+// expected seat price is 100 NEAR over the seats it keeps.
+func TestStrategyScenarios(t *testing.T) {
+	tests := []struct {
+		name   string
+		params func(p *strategy.Params)
+		// Calls of the owner before the runner starts
+		setup  func(s *scenario)
+		calls  func(t *testing.T) []call
+		staked string
+		// Unstaked in the pool and the liquid balance of the owner
+		unstaked, liquid string
+	}{
+		{
+			name:   strategy.OneSeatName,
+			params: func(p *strategy.Params) {},
+			setup:  func(s *scenario) {},
+			// Nothing is proposed before the ping. Alone the other validator
+			// takes every seat at 10 NEAR, 15 NEAR is one seat and the offset.
+			calls: func(t *testing.T) []call {
+				return []call{{"ping", "0"}, {"stake", yocto(t, "15")}, {"ping", "0"}}
+			},
+			staked: "15", unstaked: "85", liquid: "1000",
+		},
+		{
+			name:   strategy.TargetSeatsName,
+			params: func(p *strategy.Params) { p.Seats = 2; p.UnlockPolicy = strategy.WithdrawUnlocked },
+			setup:  func(s *scenario) { s.call("stake", 60) },
+			// The deposit never unstaked can be withdrawn at once. 60 NEAR buy
+			// 4 seats at 15 NEAR, 25 NEAR is above 2 seats and the offset.
+			// The 35 NEAR left buy 2 seats at 12.5 NEAR, so 5 NEAR more go,
+			// and all of it is withdrawn after the delay.
+			calls: func(t *testing.T) []call {
+				return []call{
+					{"withdraw", yocto(t, "40")}, {"unstake", yocto(t, "25")}, {"unstake", yocto(t, "5")},
+					{"ping", "0"},
+					{"ping", "0"}, {"withdraw", yocto(t, "30")},
+				}
+			},
+			staked: "30", unstaked: "0", liquid: "1070",
+		},
+		{
+			name:   strategy.BufferName,
+			params: func(p *strategy.Params) {},
+			setup:  func(s *scenario) { s.call("withdraw", 90) },
+			// 5% above the seat price of 10 NEAR and the offset, more than
+			// the 10 NEAR left in the pool
+			calls: func(t *testing.T) []call {
+				return []call{{"ping", "0"}, {"stake", yocto(t, "10")}, {"deposit_and_stake", yocto(t, "5.5")}, {"ping", "0"}}
+			},
+			staked: "15.5", unstaked: "0", liquid: "1084.5",
+		},
+		{
+			name:   strategy.StakeAllName,
+			params: func(p *strategy.Params) {},
+			setup:  func(s *scenario) {},
+			calls: func(t *testing.T) []call {
+				return []call{{"ping", "0"}, {"stake", yocto(t, "100")}, {"ping", "0"}}
+			},
+			staked: "100", unstaked: "0", liquid: "1000",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := strategy.DefaultParams()
+			p.Offset = common.NearAmount(5)
+			tt.params(&p)
+			st, err := strategy.New(tt.name, p)
+			if err != nil {
+				t.Fatal(err)
+			}
+			s := newScenario(t)
+			tt.setup(s)
+			setup := len(s.net.Calls())
+			s.startRunner(st)
+			for _, advance := range []int{0, 1, simnet.NumEpochsToUnlock} {
+				if advance > 0 {
+					s.net.AdvanceEpochs(advance)
+				}
+				for i := 0; i < 3; i++ {
+					s.tick()
+				}
+			}
+
+			if got, want := s.ownerCalls(setup), tt.calls(t); !reflect.DeepEqual(got, want) {
+				t.Errorf("calls\n%v\nwant\n%v", got, want)
+			}
+			staked, unstaked := s.net.PoolBalance(poolId, ownerId)
+			if staked.Yocto() != yocto(t, tt.staked) || unstaked.Yocto() != yocto(t, tt.unstaked) {
+				t.Errorf("staked %s and unstaked %s, want %s and %s NEAR", staked, unstaked, tt.staked, tt.unstaked)
+			}
+			if liquid := s.net.Balance(ownerId); liquid.Yocto() != yocto(t, tt.liquid) {
+				t.Errorf("liquid balance %s, want %s NEAR", liquid, tt.liquid)
+			}
+		})
+	}
+}
@@ -0,0 +1,258 @@
+package simnet
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/rozum-dev/near-go-warchest/common"
+	nearapi "github.com/rozum-dev/near-go-warchest/rpc/client"
+	"github.com/rozum-dev/near-go-warchest/rpc/signer"
+)
+
+type rpcRequest struct {
+	Id     json.RawMessage `json:"id"`
+	Method string          `json:"method"`
+	Params json.RawMessage `json:"params"`
+}
+
+type rpcError struct {
+	Name  string `json:"name"`
+	Cause struct {
+		Name string          `json:"name"`
+		Info json.RawMessage `json:"info"`
+	} `json:"cause"`
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+	Data    string `json:"data"`
+}
+
+// newRPCError is a handler error, as nearcore sends for a valid request it
+// cannot answer.
+func newRPCError(cause, data string) *rpcError {
+	e := &rpcError{Name: "HANDLER_ERROR", Code: -32000, Message: "Server error", Data: data}
+	e.Cause.Name = cause
+	e.Cause.Info = json.RawMessage("{}")
+	return e
+}
+
+// ServeHTTP answers JSON-RPC requests.
+func (n *Network) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.unavailable {
+		http.Error(w, "simnet is unavailable", http.StatusServiceUnavailable)
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, "use POST", http.StatusMethodNotAllowed)
+		return
+	}
+	var req rpcRequest
+	resp := map[string]interface{}{"jsonrpc": "2.0"}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		e := newRPCError(nearapi.ParseError, err.Error())
+		e.Name, e.Code, e.Message = "REQUEST_VALIDATION_ERROR", -32700, "Parse error"
+		resp["error"] = e
+	} else {
+		resp["id"] = req.Id
+		result, e := n.dispatch(req.Method, req.Params)
+		if e != nil {
+			resp["error"] = e
+		} else {
+			resp["result"] = result
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+func (n *Network) dispatch(method string, params json.RawMessage) (interface{}, *rpcError) {
+	switch method {
+	case "status":
+		return n.status(), nil
+	case "validators":
+		return n.validators(params)
+	case "query":
+		return n.query(params)
+	case "broadcast_tx_commit":
+		var p []string
+		if err := json.Unmarshal(params, &p); err != nil || len(p) != 1 {
+			return nil, newRPCError(nearapi.ParseError, "expected [signed_tx_base64]")
+		}
+		return n.broadcast(p[0])
+	case "tx":
+		var p []string
+		if err := json.Unmarshal(params, &p); err != nil || len(p) != 2 {
+			return nil, newRPCError(nearapi.ParseError, "expected [hash, sender_id]")
+		}
+		if o, ok := n.outcomes[p[0]]; ok {
+			return o, nil
+		}
+		return nil, newRPCError(nearapi.UnknownTransaction, fmt.Sprintf("transaction %s doesn't exist", p[0]))
+	case "EXPERIMENTAL_protocol_config", "EXPERIMENTAL_genesis_config":
+		return n.protocolConfig(), nil
+	}
+	e := newRPCError(nearapi.MethodNotFound, method)
+	e.Name, e.Code, e.Message = "REQUEST_VALIDATION_ERROR", -32601, "Method not found"
+	e.Cause.Info = json.RawMessage(fmt.Sprintf(`{"method_name":%q}`, method))
+	return nil, e
+}
+
+func (n *Network) status() *nearapi.StatusResult {
+	r := &nearapi.StatusResult{ChainId: n.cfg.ChainId}
+	r.Version.Version = "simnet"
+	r.SyncInfo.LatestBlockHeight = uint64(n.height)
+	r.SyncInfo.LatestBlockHash = signer.Base58Encode(n.blockHash(n.height))
+	r.SyncInfo.LatestBlockTime = n.blockTime(n.height).Format(time.RFC3339Nano)
+	return r
+}
+
+// validatorsResult sends the kickouts in the form of nearcore, which the
+// client decodes but does not encode.
+type validatorsResult struct {
+	nearapi.ValidatorsResult
+	PrevEpochKickOut []map[string]interface{} `json:"prev_epoch_kickout"`
+}
+
+// validators answers for the current epoch only. Nodes keep a few epochs,
+// but the warchest only asks about the latest one.
+func (n *Network) validators(params json.RawMessage) (interface{}, *rpcError) {
+	var p []*int64
+	if err := json.Unmarshal(params, &p); err != nil || len(p) != 1 {
+		return nil, newRPCError(nearapi.ParseError, "expected [block_height] or [null]")
+	}
+	if h := p[0]; h != nil {
+		if *h > n.height {
+			return nil, newRPCError(nearapi.UnknownBlock, fmt.Sprintf("DB Not Found Error: BLOCK HEIGHT: %d", *h))
+		}
+		if *h < n.epochStart {
+			return nil, newRPCError(nearapi.UnknownEpoch, "Epoch Out Of Bounds")
+		}
+	}
+	r := nearapi.ValidatorsResult{
+		CurrentValidators: []nearapi.CurrentValidator{},
+		NextValidators:    []nearapi.NextValidator{},
+		CurrentProposals:  []nearapi.Validator{},
+		EpochStartHeight:  n.epochStart,
+		EpochHeight:       n.epoch,
+	}
+	for _, v := range n.current {
+		r.CurrentValidators = append(r.CurrentValidators, nearapi.CurrentValidator{
+			Validator:         n.validator(v.id, v.stake),
+			Shards:            []int{0},
+			NumProducedBlocks: v.producedBlocks,
+			NumExpectedBlocks: v.expectedBlocks,
+			NumProducedChunks: v.producedChunks,
+			NumExpectedChunks: v.expectedChunks,
+		})
+	}
+	for _, v := range n.next {
+		r.NextValidators = append(r.NextValidators, nearapi.NextValidator{
+			Validator: n.validator(v.id, v.stake),
+			Shards:    []int{0},
+		})
+	}
+	for _, id := range sortedIds(n.proposals) {
+		r.CurrentProposals = append(r.CurrentProposals, n.validator(id, n.proposals[id]))
+	}
+	out := validatorsResult{ValidatorsResult: r, PrevEpochKickOut: []map[string]interface{}{}}
+	for _, k := range n.kickouts {
+		out.PrevEpochKickOut = append(out.PrevEpochKickOut, map[string]interface{}{"account_id": k.id, "reason": k.reason})
+	}
+	return out, nil
+}
+
+func (n *Network) validator(id string, stake common.Amount) nearapi.Validator {
+	v := nearapi.Validator{AccountId: id, Stake: stake.Yocto()}
+	if p, ok := n.pools[id]; ok {
+		v.PublicKey = p.stakingKey
+	}
+	return v
+}
+
+func (n *Network) query(params json.RawMessage) (interface{}, *rpcError) {
+	var p struct {
+		RequestType string `json:"request_type"`
+		AccountId   string `json:"account_id"`
+		PublicKey   string `json:"public_key"`
+		MethodName  string `json:"method_name"`
+		ArgsBase64  string `json:"args_base64"`
+	}
+	if err := json.Unmarshal(params, &p); err != nil {
+		return nil, newRPCError(nearapi.ParseError, err.Error())
+	}
+	acc, ok := n.accounts[p.AccountId]
+	if !ok {
+		return nil, newRPCError(nearapi.UnknownAccount, fmt.Sprintf("account %s does not exist while viewing", p.AccountId))
+	}
+	block := map[string]interface{}{
+		"block_height": n.height,
+		"block_hash":   signer.Base58Encode(n.blockHash(n.height)),
+	}
+	switch p.RequestType {
+	case "view_account":
+		block["amount"] = acc.balance
+		var locked common.Amount
+		for _, vs := range [][]*validator{n.current, n.next} {
+			for _, v := range vs {
+				if v.id == p.AccountId {
+					locked = common.MaxAmount(locked, v.stake)
+				}
+			}
+		}
+		block["locked"] = locked
+		return block, nil
+	case "view_access_key":
+		key, ok := acc.keys[p.PublicKey]
+		if !ok {
+			return nil, newRPCError(nearapi.UnknownAccessKey, fmt.Sprintf("access key %s does not exist while viewing", p.PublicKey))
+		}
+		block["nonce"] = key.nonce
+		block["permission"] = "FullAccess"
+		return block, nil
+	case "call_function":
+		pool, ok := n.pools[p.AccountId]
+		if !ok {
+			return nil, newRPCError(nearapi.NoContractCode, fmt.Sprintf("contract code for %s has not been deployed", p.AccountId))
+		}
+		args, err := base64.StdEncoding.DecodeString(p.ArgsBase64)
+		if err != nil {
+			return nil, newRPCError(nearapi.ParseError, err.Error())
+		}
+		v, err := n.viewPool(pool, p.MethodName, args)
+		if err != nil {
+			return nil, newRPCError(nearapi.ContractExecution, err.Error())
+		}
+		data, err := json.Marshal(v)
+		if err != nil {
+			return nil, newRPCError(nearapi.InternalError, err.Error())
+		}
+		result := make([]int, len(data))
+		for i, b := range data {
+			result[i] = int(b)
+		}
+		block["result"] = result
+		block["logs"] = []string{}
+		return block, nil
+	}
+	return nil, newRPCError(nearapi.ParseError, fmt.Sprintf("unsupported request type %s", p.RequestType))
+}
+
+func (n *Network) protocolConfig() *nearapi.ProtocolConfigResult {
+	return &nearapi.ProtocolConfigResult{
+		ProtocolVersion:                 n.cfg.ProtocolVersion,
+		ChainId:                         n.cfg.ChainId,
+		EpochLength:                     n.cfg.EpochLength,
+		NumBlockProducerSeats:           n.cfg.NumSeats,
+		AvgHiddenValidatorSeatsPerShard: []int{0},
+		MinimumStakeRatio:               n.cfg.MinimumStakeRatio,
+		BlockProducerKickoutThreshold:   n.cfg.KickoutThreshold,
+		ChunkProducerKickoutThreshold:   n.cfg.KickoutThreshold,
+		TransactionValidityPeriod:       txValidityPeriod,
+	}
+}
@@ -0,0 +1,261 @@
+package simnet
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+
+	"github.com/rozum-dev/near-go-warchest/common"
+	nearapi "github.com/rozum-dev/near-go-warchest/rpc/client"
+	"github.com/rozum-dev/near-go-warchest/rpc/signer"
+)
+
+// Transactions must refer to one of this many latest blocks
+const txValidityPeriod = 1000
+
+// borshReader reads the subset of Borsh written by the signer.
+type borshReader struct {
+	buf []byte
+	err error
+}
+
+func (r *borshReader) next(n int) []byte {
+	if r.err != nil {
+		return make([]byte, n)
+	}
+	if len(r.buf) < n {
+		r.err = errors.New("unexpected end of transaction")
+		return make([]byte, n)
+	}
+	b := r.buf[:n]
+	r.buf = r.buf[n:]
+	return b
+}
+
+func (r *borshReader) u8() uint8 {
+	return r.next(1)[0]
+}
+
+func (r *borshReader) u32() uint32 {
+	return binary.LittleEndian.Uint32(r.next(4))
+}
+
+func (r *borshReader) u64() uint64 {
+	return binary.LittleEndian.Uint64(r.next(8))
+}
+
+func (r *borshReader) u128() *big.Int {
+	le := r.next(16)
+	be := make([]byte, 16)
+	for i := range le {
+		be[15-i] = le[i]
+	}
+	return new(big.Int).SetBytes(be)
+}
+
+func (r *borshReader) bytes() []byte {
+	n := int(r.u32())
+	if r.err == nil && n > len(r.buf) {
+		r.err = errors.New("unexpected end of transaction")
+	}
+	return r.next(n)
+}
+
+func (r *borshReader) string() string {
+	return string(r.bytes())
+}
+
+func (r *borshReader) key() []byte {
+	if t := r.u8(); t != 0 && r.err == nil {
+		r.err = fmt.Errorf("unsupported key type %d", t)
+	}
+	return r.next(ed25519.PublicKeySize)
+}
+
+// decodeTx decodes a SignedTransaction with function calls only. It returns
+// the transaction, the bytes that were signed and the signature.
+func decodeTx(data []byte) (*signer.Transaction, []byte, []byte, error) {
+	r := &borshReader{buf: data}
+	tx := &signer.Transaction{}
+	tx.SignerId = r.string()
+	tx.PublicKey = ed25519.PublicKey(r.key())
+	tx.Nonce = r.u64()
+	tx.ReceiverId = r.string()
+	tx.BlockHash = r.next(32)
+	numActions := r.u32()
+	for i := uint32(0); i < numActions && r.err == nil; i++ {
+		if tag := r.u8(); tag != 2 && r.err == nil {
+			r.err = fmt.Errorf("unsupported action %d", tag)
+			break
+		}
+		tx.Actions = append(tx.Actions, signer.FunctionCall{
+			MethodName: r.string(),
+			Args:       r.bytes(),
+			Gas:        r.u64(),
+			Deposit:    r.u128(),
+		})
+	}
+	signed := data[:len(data)-len(r.buf)]
+	if t := r.u8(); t != 0 && r.err == nil {
+		r.err = fmt.Errorf("unsupported signature type %d", t)
+	}
+	signature := r.next(ed25519.SignatureSize)
+	if r.err == nil && len(r.buf) > 0 {
+		r.err = errors.New("trailing bytes after the signature")
+	}
+	if r.err != nil {
+		return nil, nil, nil, r.err
+	}
+	return tx, signed, signature, nil
+}
+
+// broadcast checks and applies a base64 SignedTransaction. An invalid
+// transaction is an RPC error, a failed call is a failed outcome.
+func (n *Network) broadcast(encoded string) (*nearapi.FinalExecutionOutcome, *rpcError) {
+	data, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, newRPCError(nearapi.ParseError, fmt.Sprintf("invalid base64: %v", err))
+	}
+	tx, signed, signature, err := decodeTx(data)
+	if err != nil {
+		return nil, newRPCError(nearapi.ParseError, err.Error())
+	}
+	hash := sha256.Sum256(signed)
+	if !ed25519.Verify(tx.PublicKey, hash[:], signature) {
+		return nil, newRPCError(nearapi.InvalidTransaction, "InvalidSignature")
+	}
+	acc, ok := n.accounts[tx.SignerId]
+	if !ok {
+		return nil, newRPCError(nearapi.InvalidTransaction, fmt.Sprintf("SignerDoesNotExist %s", tx.SignerId))
+	}
+	key, ok := acc.keys[keyString(tx.PublicKey)]
+	if !ok {
+		return nil, newRPCError(nearapi.InvalidTransaction, "InvalidAccessKeyError AccessKeyNotFound")
+	}
+	if tx.Nonce <= key.nonce {
+		return nil, newRPCError(nearapi.InvalidTransaction, fmt.Sprintf("InvalidNonce %d, the access key nonce is %d", tx.Nonce, key.nonce))
+	}
+	if !n.recentBlock(tx.BlockHash) {
+		return nil, newRPCError(nearapi.InvalidTransaction, "Expired")
+	}
+	var deposit common.Amount
+	for _, a := range tx.Actions {
+		deposit = deposit.Add(common.NewAmount(a.Deposit))
+	}
+	if acc.balance.Cmp(deposit) < 0 {
+		return nil, newRPCError(nearapi.InvalidTransaction, fmt.Sprintf("NotEnoughBalance %s has %s, needs %s", tx.SignerId, acc.balance, deposit))
+	}
+	key.nonce = tx.Nonce
+	acc.balance = acc.balance.Sub(deposit)
+
+	txHash := signer.Base58Encode(hash[:])
+	var failure error
+	for _, a := range tx.Actions {
+		call := Call{
+			Height:     n.height,
+			Hash:       txHash,
+			SignerId:   tx.SignerId,
+			ReceiverId: tx.ReceiverId,
+			Method:     a.MethodName,
+			Args:       string(a.Args),
+			Deposit:    common.NewAmount(a.Deposit),
+		}
+		if failure == nil {
+			failure = n.apply(tx.SignerId, tx.ReceiverId, a)
+			if failure != nil {
+				call.Error = failure.Error()
+			}
+		}
+		n.calls = append(n.calls, call)
+	}
+	if failure != nil {
+		// Actions are all or nothing; the deposit goes back
+		acc.balance = acc.balance.Add(deposit)
+	}
+	o := n.outcome(txHash, tx, failure)
+	n.outcomes[txHash] = o
+	return o, nil
+}
+
+// apply runs one function call. The state changes of a failed call are not
+// rolled back, so the pool checks everything before changing anything.
+func (n *Network) apply(signerId, receiverId string, a signer.FunctionCall) error {
+	p, ok := n.pools[receiverId]
+	if !ok {
+		return fmt.Errorf("CodeDoesNotExist %s", receiverId)
+	}
+	return n.callPool(p, signerId, a.MethodName, a.Args, common.NewAmount(a.Deposit))
+}
+
+func (n *Network) recentBlock(hash []byte) bool {
+	for h := n.height; h > 0 && h > n.height-txValidityPeriod; h-- {
+		if bytes.Equal(hash, n.blockHash(h)) {
+			return true
+		}
+	}
+	return false
+}
+
+func (n *Network) outcome(hash string, tx *signer.Transaction, failure error) *nearapi.FinalExecutionOutcome {
+	o := &nearapi.FinalExecutionOutcome{}
+	o.Transaction.Hash = hash
+	o.Transaction.SignerId = tx.SignerId
+	o.Transaction.ReceiverId = tx.ReceiverId
+	receiptHash := sha256.Sum256([]byte(hash))
+	receiptId := signer.Base58Encode(receiptHash[:])
+	o.TransactionOutcome.Id = hash
+	o.TransactionOutcome.Outcome.ExecutorId = tx.SignerId
+	o.TransactionOutcome.Outcome.ReceiptIds = []string{receiptId}
+	o.TransactionOutcome.Outcome.Status.SuccessReceiptId = &receiptId
+
+	receipt := nearapi.ExecutionOutcome{Id: receiptId}
+	receipt.Outcome.ExecutorId = tx.ReceiverId
+	receipt.Outcome.ReceiptIds = []string{}
+	if failure != nil {
+		f, _ := json.Marshal(map[string]interface{}{
+			"ActionError": map[string]interface{}{
+				"index": 0,
+				"kind": map[string]interface{}{
+					"FunctionCallError": map[string]string{"ExecutionError": "Smart contract panicked: " + failure.Error()},
+				},
+			},
+		})
+		o.Status.Failure = f
+		receipt.Outcome.Status.Failure = f
+	} else {
+		empty := ""
+		o.Status.SuccessValue = &empty
+		receipt.Outcome.Status.SuccessValue = &empty
+	}
+	o.ReceiptsOutcome = []nearapi.ExecutionOutcome{receipt}
+	return o
+}
+
+// WriteKey creates a key for an account and saves it in the near-shell
+// credentials layout, <dir>/<network>/<accountId>.json.
+func WriteKey(dir, network, accountId string) (ed25519.PublicKey, error) {
+	seed := sha256.Sum256([]byte(network + "/" + accountId))
+	privateKey := ed25519.NewKeyFromSeed(seed[:])
+	publicKey := privateKey.Public().(ed25519.PublicKey)
+	data, err := json.Marshal(map[string]string{
+		"account_id":  accountId,
+		"public_key":  keyString(publicKey),
+		"private_key": "ed25519:" + signer.Base58Encode(privateKey),
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Join(dir, network), 0700); err != nil {
+		return nil, err
+	}
+	return publicKey, ioutil.WriteFile(filepath.Join(dir, network, accountId+".json"), data, 0600)
+}
@@ -0,0 +1,303 @@
+package api
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rozum-dev/near-go-warchest/common"
+	"github.com/rozum-dev/near-go-warchest/near-shell/runner"
+	"github.com/rozum-dev/near-go-warchest/store"
+	"github.com/rozum-dev/near-go-warchest/strategy"
+)
+
+// Controller is the part of the runner the API drives.
+type Controller interface {
+	PoolId() string
+	Status() runner.Status
+	Ping(ctx context.Context, reason string) error
+	Pause()
+	Resume()
+	Restake(ctx context.Context, reason string) ([]strategy.Intent, error)
+}
+
+// Config enables the API when Addr is set. Token is required; TLS is used
+// when both cert_file and key_file are set.
+type Config struct {
+	Addr     string `yaml:"addr"`
+	Token    string `yaml:"token"`
+	CertFile string `yaml:"cert_file"`
+	KeyFile  string `yaml:"key_file"`
+}
+
+// How long a POST waits for the runner
+const requestTimeout = 2 * time.Minute
+
+type Server struct {
+	token     string
+	pools     map[string]Controller
+	ids       []string
+	decisions *runner.DecisionLog
+	// State file with the transactions sent in the last epochs, optional
+	store *store.Store
+	mux   *http.ServeMux
+}
+
+func NewServer(token string, pools []Controller, decisions *runner.DecisionLog, st *store.Store) *Server {
+	s := &Server{
+		token:     token,
+		pools:     make(map[string]Controller),
+		decisions: decisions,
+		store:     st,
+		mux:       http.NewServeMux(),
+	}
+	for _, p := range pools {
+		s.pools[p.PoolId()] = p
+		s.ids = append(s.ids, p.PoolId())
+	}
+	sort.Strings(s.ids)
+	s.mux.HandleFunc("/status", s.get(s.status))
+	s.mux.HandleFunc("/decisions", s.get(s.recentDecisions))
+	s.mux.HandleFunc("/history", s.get(s.history))
+	s.mux.HandleFunc("/ping", s.post(s.ping))
+	s.mux.HandleFunc("/pause", s.post(s.pause))
+	s.mux.HandleFunc("/resume", s.post(s.resume))
+	s.mux.HandleFunc("/restake", s.post(s.restake))
+	return s
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// The dashboard page holds no data, it calls the API with the token
+	if r.URL.Path == "/" && r.Method == "GET" {
+		serveDashboard(w, r)
+		return
+	}
+	auth := r.Header.Get("Authorization")
+	if !strings.HasPrefix(auth, "Bearer ") ||
+		subtle.ConstantTimeCompare([]byte(strings.TrimPrefix(auth, "Bearer ")), []byte(s.token)) != 1 {
+		writeError(w, http.StatusUnauthorized, fmt.Errorf("invalid token"))
+		return
+	}
+	s.mux.ServeHTTP(w, r)
+}
+
+// Run serves the API until ctx is done.
+func Run(ctx context.Context, c Config, handler http.Handler) {
+	srv := &http.Server{Addr: c.Addr, Handler: handler}
+	go func() {
+		<-ctx.Done()
+		srv.Close()
+	}()
+	log.Printf("Serving the API on %s\n", c.Addr)
+	var err error
+	if c.CertFile != "" {
+		err = srv.ListenAndServeTLS(c.CertFile, c.KeyFile)
+	} else {
+		err = srv.ListenAndServe()
+	}
+	if err != http.ErrServerClosed {
+		log.Fatal(err)
+	}
+}
+
+type handlerFunc func(r *http.Request) (interface{}, int, error)
+
+func (s *Server) get(h handlerFunc) http.HandlerFunc {
+	return s.handle("GET", h)
+}
+
+func (s *Server) post(h handlerFunc) http.HandlerFunc {
+	return s.handle("POST", h)
+}
+
+func (s *Server) handle(method string, h handlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != method {
+			w.Header().Set("Allow", method)
+			writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("use %s", method))
+			return
+		}
+		if method == "POST" {
+			log.Printf("API: %s %s from %s\n", r.Method, r.URL, r.RemoteAddr)
+		}
+		v, code, err := h(r)
+		if err != nil {
+			writeError(w, code, err)
+			return
+		}
+		writeJSON(w, code, v)
+	}
+}
+
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Println(err)
+	}
+}
+
+func writeError(w http.ResponseWriter, code int, err error) {
+	writeJSON(w, code, map[string]string{"error": err.Error()})
+}
+
+// selectPools returns the pool given by ?pool=, or all pools.
+func (s *Server) selectPools(r *http.Request) ([]Controller, error) {
+	if id := r.URL.Query().Get("pool"); id != "" {
+		if _, err := common.ParseAccountID(id); err != nil {
+			return nil, err
+		}
+		p, ok := s.pools[id]
+		if !ok {
+			return nil, fmt.Errorf("unknown pool %s", id)
+		}
+		return []Controller{p}, nil
+	}
+	var pools []Controller
+	for _, id := range s.ids {
+		pools = append(pools, s.pools[id])
+	}
+	return pools, nil
+}
+
+// selectPool returns the pool given by ?pool=, or the only pool.
+func (s *Server) selectPool(r *http.Request) (Controller, error) {
+	pools, err := s.selectPools(r)
+	if err != nil {
+		return nil, err
+	}
+	if len(pools) != 1 {
+		return nil, fmt.Errorf("%d pools are managed, set ?pool=", len(pools))
+	}
+	return pools[0], nil
+}
+
+func (s *Server) status(r *http.Request) (interface{}, int, error) {
+	pools, err := s.selectPools(r)
+	if err != nil {
+		return nil, http.StatusNotFound, err
+	}
+	statuses := []runner.Status{}
+	for _, p := range pools {
+		statuses = append(statuses, p.Status())
+	}
+	return statuses, http.StatusOK, nil
+}
+
+func (s *Server) recentDecisions(r *http.Request) (interface{}, int, error) {
+	n := 100
+	if v := r.URL.Query().Get("n"); v != "" {
+		var err error
+		n, err = strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			return nil, http.StatusBadRequest, fmt.Errorf("n must be a positive number")
+		}
+	}
+	pool := r.URL.Query().Get("pool")
+	if pool != "" {
+		if _, err := common.ParseAccountID(pool); err != nil {
+			return nil, http.StatusBadRequest, err
+		}
+	}
+	decisions := []runner.Decision{}
+	for _, d := range s.decisions.Recent(0) {
+		if pool == "" || d.PoolId == pool {
+			decisions = append(decisions, d)
+		}
+	}
+	if len(decisions) > n {
+		decisions = decisions[len(decisions)-n:]
+	}
+	return decisions, http.StatusOK, nil
+}
+
+// EpochActions are the transactions sent for a pool in one epoch, as kept in
+// the state file across restarts.
+type EpochActions struct {
+	PoolId           string         `json:"pool_id"`
+	EpochStartHeight int64          `json:"epoch_start_height"`
+	Actions          []store.Action `json:"actions"`
+}
+
+func (s *Server) history(r *http.Request) (interface{}, int, error) {
+	pools, err := s.selectPools(r)
+	if err != nil {
+		return nil, http.StatusNotFound, err
+	}
+	history := []EpochActions{}
+	if s.store == nil {
+		return history, http.StatusOK, nil
+	}
+	for _, p := range pools {
+		var epochs []EpochActions
+		for epoch, actions := range s.store.Pool(p.PoolId()).Actions {
+			epochs = append(epochs, EpochActions{PoolId: p.PoolId(), EpochStartHeight: epoch, Actions: actions})
+		}
+		sort.Slice(epochs, func(i, j int) bool { return epochs[i].EpochStartHeight < epochs[j].EpochStartHeight })
+		history = append(history, epochs...)
+	}
+	return history, http.StatusOK, nil
+}
+
+func (s *Server) ping(r *http.Request) (interface{}, int, error) {
+	p, err := s.selectPool(r)
+	if err != nil {
+		return nil, http.StatusBadRequest, err
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), requestTimeout)
+	defer cancel()
+	if err := p.Ping(ctx, "api request from "+r.RemoteAddr); err != nil {
+		return nil, http.StatusBadGateway, err
+	}
+	return p.Status(), http.StatusOK, nil
+}
+
+func (s *Server) pause(r *http.Request) (interface{}, int, error) {
+	pools, err := s.selectPools(r)
+	if err != nil {
+		return nil, http.StatusNotFound, err
+	}
+	statuses := []runner.Status{}
+	for _, p := range pools {
+		p.Pause()
+		statuses = append(statuses, p.Status())
+	}
+	return statuses, http.StatusOK, nil
+}
+
+func (s *Server) resume(r *http.Request) (interface{}, int, error) {
+	pools, err := s.selectPools(r)
+	if err != nil {
+		return nil, http.StatusNotFound, err
+	}
+	statuses := []runner.Status{}
+	for _, p := range pools {
+		p.Resume()
+		statuses = append(statuses, p.Status())
+	}
+	return statuses, http.StatusOK, nil
+}
+
+func (s *Server) restake(r *http.Request) (interface{}, int, error) {
+	p, err := s.selectPool(r)
+	if err != nil {
+		return nil, http.StatusBadRequest, err
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), requestTimeout)
+	defer cancel()
+	intents, err := p.Restake(ctx, "api request from "+r.RemoteAddr)
+	if err != nil {
+		return nil, http.StatusBadGateway, err
+	}
+	if intents == nil {
+		intents = []strategy.Intent{}
+	}
+	return intents, http.StatusOK, nil
+}
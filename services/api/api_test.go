@@ -0,0 +1,89 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/rozum-dev/near-go-warchest/common"
+	"github.com/rozum-dev/near-go-warchest/near-shell/runner"
+	"github.com/rozum-dev/near-go-warchest/store"
+	"github.com/rozum-dev/near-go-warchest/strategy"
+)
+
+type fakePool struct {
+	id string
+}
+
+func (p fakePool) PoolId() string                                { return p.id }
+func (p fakePool) Status() runner.Status                         { return runner.Status{PoolId: p.id} }
+func (p fakePool) Ping(ctx context.Context, reason string) error { return nil }
+func (p fakePool) Pause()                                        {}
+func (p fakePool) Resume()                                       {}
+func (p fakePool) Restake(ctx context.Context, reason string) ([]strategy.Intent, error) {
+	return nil, nil
+}
+
+func TestHistoryComesFromTheStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "warchest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "state.json")
+	st, err := store.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	add := func(pool string, epoch int64, method string) {
+		err := st.AddAction(pool, epoch, store.Action{Time: time.Now(), Method: method, DelegatorId: "owner.near", Amount: common.NearAmount(1)})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	add("a.pool.near", 200, "stake")
+	add("a.pool.near", 100, "ping")
+	add("b.pool.near", 100, "ping")
+
+	// Read back after a restart, with an empty decision log
+	st, err = store.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decisions, _ := runner.NewDecisionLog(10, "")
+	s := NewServer("secret", []Controller{fakePool{"a.pool.near"}, fakePool{"b.pool.near"}}, decisions, st)
+	get := func(path string) []EpochActions {
+		req := httptest.NewRequest("GET", path, nil)
+		req.Header.Set("Authorization", "Bearer secret")
+		w := httptest.NewRecorder()
+		s.ServeHTTP(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("%s: %d %s", path, w.Code, w.Body)
+		}
+		var out []EpochActions
+		if err := json.Unmarshal(w.Body.Bytes(), &out); err != nil {
+			t.Fatal(err)
+		}
+		return out
+	}
+
+	all := get("/history")
+	if len(all) != 3 {
+		t.Fatalf("%d epochs, want 3: %+v", len(all), all)
+	}
+	a := get("/history?pool=a.pool.near")
+	if len(a) != 2 || a[0].EpochStartHeight != 100 || a[1].EpochStartHeight != 200 || a[1].Actions[0].Method != "stake" {
+		t.Errorf("history of a.pool.near %+v", a)
+	}
+	for _, e := range a {
+		if e.PoolId != "a.pool.near" {
+			t.Errorf("epoch of %s in the history of a.pool.near", e.PoolId)
+		}
+	}
+}
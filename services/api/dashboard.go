@@ -0,0 +1,171 @@
+package api
+
+import "net/http"
+
+func serveDashboard(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write([]byte(dashboardHTML))
+}
+
+// The dashboard asks for the API token once and keeps it in localStorage.
+const dashboardHTML = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Go-Warchest</title>
+<style>
+body { font-family: sans-serif; background: #111217; color: #d8d9da; margin: 0; padding: 16px; }
+h1 { font-size: 20px; margin: 0 0 16px; }
+h2 { font-size: 16px; margin: 0 0 8px; }
+.pool { background: #181b1f; border: 1px solid #2c3235; border-radius: 4px; padding: 16px; margin-bottom: 16px; }
+.grid { display: flex; flex-wrap: wrap; gap: 16px; }
+.card { min-width: 200px; }
+.label { color: #8e8e8e; font-size: 12px; }
+.value { font-size: 18px; margin-bottom: 8px; }
+.bar { background: #2c3235; height: 8px; border-radius: 4px; overflow: hidden; margin: 4px 0 8px; }
+.bar div { background: #73bf69; height: 100%; }
+.warn { color: #f2cc0c; }
+.bad { color: #f2495c; }
+table { border-collapse: collapse; font-size: 13px; }
+td, th { padding: 2px 12px 2px 0; text-align: left; }
+svg { width: 100%; height: 40px; background: #111217; margin: 8px 0; }
+#error { color: #f2495c; }
+</style>
+</head>
+<body>
+<h1>Go-Warchest</h1>
+<div id="error"></div>
+<div id="pools"></div>
+<script>
+var token = localStorage.getItem("warchest-token");
+
+function api(path) {
+  if (!token) {
+    token = prompt("API token");
+    localStorage.setItem("warchest-token", token);
+  }
+  return fetch(path, {headers: {"Authorization": "Bearer " + token}}).then(function (r) {
+    if (r.status === 401) {
+      localStorage.removeItem("warchest-token");
+      token = null;
+    }
+    return r.json().then(function (body) {
+      if (!r.ok) throw new Error(body.error || r.statusText);
+      return body;
+    });
+  });
+}
+
+var yocto = 1e24;
+function near(v) {
+  return (Number(v || "0") / yocto).toLocaleString(undefined, {maximumFractionDigits: 2}) + " NEAR";
+}
+function pct(a, b) {
+  return b > 0 ? 100 * a / b : 0;
+}
+function esc(s) {
+  var d = document.createElement("div");
+  d.textContent = s;
+  return d.innerHTML;
+}
+function card(label, value, cls) {
+  return '<div class="label">' + label + '</div><div class="value ' + (cls || "") + '">' + value + '</div>';
+}
+function bar(p) {
+  return '<div class="bar"><div style="width:' + Math.min(100, p).toFixed(1) + '%"></div></div>';
+}
+
+var colors = {ping: "#5794f2", stake: "#73bf69", unstake: "#ff9830", deposit_and_stake: "#b877d9", withdraw: "#8e8e8e"};
+
+function timeline(decisions) {
+  if (decisions.length === 0) return '<div class="label">No decisions yet</div>';
+  var t0 = new Date(decisions[0].time).getTime();
+  var t1 = new Date(decisions[decisions.length - 1].time).getTime();
+  var span = Math.max(t1 - t0, 1);
+  var marks = decisions.map(function (d) {
+    var x = 1 + 98 * (new Date(d.time).getTime() - t0) / span;
+    var color = d.error ? "#f2495c" : (colors[d.method] || "#d8d9da");
+    return '<rect x="' + x + '%" y="' + (d.dry_run ? 20 : 5) + '" width="3" height="15" fill="' + color + '">' +
+      '<title>' + esc(d.time + " " + d.method + " " + d.reason) + '</title></rect>';
+  });
+  var rows = decisions.slice(-10).reverse().map(function (d) {
+    return '<tr><td>' + esc(new Date(d.time).toLocaleString()) + '</td><td>' + esc(d.method) + '</td><td>' +
+      esc(d.delegator_id) + '</td><td>' + (d.method === "ping" ? "" : near(d.amount)) + '</td><td>' +
+      esc(d.reason) + (d.dry_run ? " (dry run)" : "") + '</td><td class="bad">' + esc(d.error || "") + '</td></tr>';
+  });
+  return '<svg>' + marks.join("") + '</svg><table>' + rows.join("") + '</table>';
+}
+
+function history(epochs) {
+  if (epochs.length === 0) return '<div class="label">Nothing sent yet</div>';
+  var rows = [];
+  epochs.slice().reverse().forEach(function (e) {
+    e.actions.slice().reverse().forEach(function (a) {
+      rows.push('<tr><td>' + e.epoch_start_height.toLocaleString() + '</td><td>' + esc(new Date(a.time).toLocaleString()) + '</td><td>' +
+        esc(a.method) + '</td><td>' + esc(a.delegator_id) + '</td><td>' + (a.method === "ping" ? "" : near(a.amount)) + '</td><td>' +
+        esc(a.tx_hash || "") + '</td></tr>');
+    });
+  });
+  return '<table><tr><th>Epoch start</th><th>Time</th><th>Method</th><th>Delegator</th><th>Amount</th><th>Transaction</th></tr>' +
+    rows.slice(0, 20).join("") + '</table>';
+}
+
+function render(status, decisions, epochs) {
+  var html = status.map(function (s) {
+    var done = s.epoch_length - s.left_blocks;
+    var uptime = pct(s.produced_blocks, s.expected_blocks);
+    // Kicked out below the threshold of the protocol, 90% when the node does not say
+    var protocol = s.result && s.result.protocol;
+    var threshold = (protocol && protocol.block_producer_kickout_threshold) || 90;
+    var balances = Object.keys(s.staked_balance).sort().map(function (d) {
+      return '<tr><td>' + esc(d) + '</td><td>' + near(s.staked_balance[d]) + '</td><td>' + near(s.unstaked_balance[d]) + '</td><td>' + near((s.liquid_balance || {})[d]) + '</td></tr>';
+    });
+    var flags = [];
+    var kickout = s.result && s.result.kickout;
+    if (s.kicked_out) flags.push('<span class="bad">kicked out' + (kickout ? ": " + esc(kickout.kind) + " - " + esc(kickout.hint) : "") + '</span>');
+    if (!s.proposed) flags.push('<span class="warn">not in proposals</span>');
+    if (s.paused) flags.push('<span class="warn">paused</span>');
+    var pool = s.result && s.result.pool;
+    if (pool && pool.staking_paused) flags.push('<span class="bad">staking paused in the pool</span>');
+    if (s.dry_run) flags.push('dry run');
+    var mine = decisions.filter(function (d) { return d.pool_id === s.pool_id; });
+    var sent = epochs.filter(function (e) { return e.pool_id === s.pool_id; });
+    return '<div class="pool"><h2>' + esc(s.pool_id) + ' <span class="label">' + esc(s.strategy) + ' ' + flags.join(" ") + '</span></h2>' +
+      '<div class="grid">' +
+      '<div class="card">' + card("Epoch progress", pct(done, s.epoch_length).toFixed(1) + "%") + bar(pct(done, s.epoch_length)) +
+        card("Left blocks", s.left_blocks.toLocaleString()) + card("Epoch start", s.epoch_start_height.toLocaleString()) + '</div>' +
+      '<div class="card">' + card("Current seat price", near(s.current_seat_price)) + card("Next seat price", near(s.next_seat_price)) +
+        card("Expected seat price", near(s.expected_seat_price)) + '</div>' +
+      '<div class="card">' + card("Current stake", near(s.current_stake)) + card("Expected stake", near(s.expected_stake)) +
+        card("Expected seats", s.expected_seats.toFixed(3)) + '</div>' +
+      '<div class="card">' + card("Uptime", s.expected_blocks > 0 ? uptime.toFixed(1) + "%" : "-", uptime < threshold && s.expected_blocks > 0 ? "bad" : "") +
+        bar(uptime) + card("Blocks", s.produced_blocks + " / " + s.expected_blocks) +
+        (s.result ? card("Chunks", s.result.produced_chunks + " / " + s.result.expected_chunks) +
+          card("Projected at epoch end", s.result.projected_blocks.toFixed(1) + "% blocks, " + s.result.projected_chunks.toFixed(1) + "% chunks") : "") + '</div>' +
+      (pool ? '<div class="card">' + card("Pool total stake", near(pool.total_staked_balance)) +
+        card("Accounts", pool.number_of_accounts.toLocaleString()) +
+        card("Reward fee", pool.reward_fee_fraction.numerator + " / " + pool.reward_fee_fraction.denominator) +
+        card("Staking key", esc(pool.staking_key)) + '</div>' : "") +
+      '<div class="card"><div class="label">Delegators</div><table><tr><th></th><th>Staked</th><th>Unstaked</th><th>Liquid</th></tr>' + balances.join("") + '</table></div>' +
+      '</div><div class="label">Pings and restakes</div>' + timeline(mine) +
+      '<div class="label">Sent in the last epochs, kept across restarts</div>' + history(sent) +
+      '<div class="label">Updated ' + (s.updated_at.indexOf("0001") === 0 ? "never" : esc(new Date(s.updated_at).toLocaleString())) + '</div></div>';
+  });
+  document.getElementById("pools").innerHTML = html.join("");
+}
+
+function refresh() {
+  Promise.all([api("/status"), api("/decisions?n=500"), api("/history")]).then(function (r) {
+    document.getElementById("error").textContent = "";
+    render(r[0], r[1], r[2]);
+  }).catch(function (e) {
+    document.getElementById("error").textContent = e.message;
+  });
+}
+
+refresh();
+setInterval(refresh, 15000);
+</script>
+</body>
+</html>
+`
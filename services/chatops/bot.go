@@ -0,0 +1,284 @@
+package chatops
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"log"
+	"math/big"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rozum-dev/near-go-warchest/common"
+	"github.com/rozum-dev/near-go-warchest/near-shell/runner"
+)
+
+// How long a /stake or /unstake waits for /confirm
+const confirmTimeout = 2 * time.Minute
+
+// Controller is the part of the runner the bot drives.
+type Controller interface {
+	PoolId() string
+	Status() runner.Status
+	Ping(ctx context.Context, reason string) error
+	Pause()
+	Resume()
+	Stake(ctx context.Context, amount common.Amount, reason string) error
+	Unstake(ctx context.Context, amount common.Amount, reason string) error
+}
+
+type pendingAction struct {
+	code    string
+	method  string
+	amount  common.Amount
+	pool    Controller
+	expires time.Time
+}
+
+// Bot answers commands from allowed users on a chat transport.
+type Bot struct {
+	transport Transport
+	allowed   map[string]bool
+	pools     map[string]Controller
+
+	mu      sync.Mutex
+	pending map[string]*pendingAction
+}
+
+func NewBot(t Transport, allowedUsers []string, pools []Controller) *Bot {
+	b := &Bot{
+		transport: t,
+		allowed:   make(map[string]bool),
+		pools:     make(map[string]Controller),
+		pending:   make(map[string]*pendingAction),
+	}
+	for _, u := range allowedUsers {
+		b.allowed[u] = true
+	}
+	for _, p := range pools {
+		b.pools[p.PoolId()] = p
+	}
+	return b
+}
+
+func (b *Bot) Run(ctx context.Context) {
+	for {
+		messages, err := b.transport.Receive(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			log.Printf("Chat-ops: %s\n", err)
+			select {
+			case <-time.After(10 * time.Second):
+			case <-ctx.Done():
+				return
+			}
+			continue
+		}
+		for _, msg := range messages {
+			reply := b.handle(ctx, msg)
+			if reply == "" {
+				continue
+			}
+			if err := b.transport.Send(ctx, msg.ChatId, reply); err != nil {
+				log.Printf("Chat-ops: %s\n", err)
+			}
+		}
+	}
+}
+
+const help = `/status [pool] - seats, left blocks and balances
+/ping [pool] - ping now
+/pause [pool] - stop automatic restaking
+/resume [pool] - resume automatic restaking
+/stake <NEAR> [pool] - stake, asks for confirmation
+/unstake <NEAR> [pool] - unstake, asks for confirmation
+/confirm <code> - confirm a stake or unstake`
+
+func (b *Bot) handle(ctx context.Context, msg Message) string {
+	fields := strings.Fields(msg.Text)
+	if len(fields) == 0 || !strings.HasPrefix(fields[0], "/") {
+		return ""
+	}
+	if !b.allowed[msg.UserId] {
+		log.Printf("Chat-ops: ignored %q from %s\n", msg.Text, msg.UserId)
+		return ""
+	}
+	// Telegram appends the bot name in groups: /status@warchest_bot
+	cmd := strings.SplitN(fields[0], "@", 2)[0]
+	args := fields[1:]
+	log.Printf("Chat-ops: %s from %s\n", msg.Text, msg.UserId)
+	reason := fmt.Sprintf("%s by %s", cmd, msg.UserId)
+
+	switch cmd {
+	case "/help", "/start":
+		return help
+	case "/status":
+		pools, err := b.selectPools(args)
+		if err != nil {
+			return err.Error()
+		}
+		var out []string
+		for _, p := range pools {
+			out = append(out, formatStatus(p.Status()))
+		}
+		return strings.Join(out, "\n\n")
+	case "/ping":
+		p, err := b.selectPool(args)
+		if err != nil {
+			return err.Error()
+		}
+		if err := p.Ping(ctx, reason); err != nil {
+			return fmt.Sprintf("%s: ping failed: %s", p.PoolId(), err)
+		}
+		return fmt.Sprintf("%s: pinged", p.PoolId())
+	case "/pause", "/resume":
+		pools, err := b.selectPools(args)
+		if err != nil {
+			return err.Error()
+		}
+		var out []string
+		for _, p := range pools {
+			if cmd == "/pause" {
+				p.Pause()
+				out = append(out, p.PoolId()+": restaking paused")
+			} else {
+				p.Resume()
+				out = append(out, p.PoolId()+": restaking resumed")
+			}
+		}
+		return strings.Join(out, "\n")
+	case "/stake", "/unstake":
+		if len(args) == 0 {
+			return "Usage: " + cmd + " <NEAR> [pool]"
+		}
+		amount, err := common.ParseNear(args[0])
+		if err != nil {
+			return err.Error()
+		}
+		p, err := b.selectPool(args[1:])
+		if err != nil {
+			return err.Error()
+		}
+		n, err := rand.Int(rand.Reader, big.NewInt(1000000))
+		if err != nil {
+			return err.Error()
+		}
+		code := fmt.Sprintf("%06d", n)
+		b.mu.Lock()
+		b.pending[msg.ChatId+"/"+msg.UserId] = &pendingAction{
+			code:    code,
+			method:  strings.TrimPrefix(cmd, "/"),
+			amount:  amount,
+			pool:    p,
+			expires: time.Now().Add(confirmTimeout),
+		}
+		b.mu.Unlock()
+		return fmt.Sprintf("%s: %s %s? Reply /confirm %s within %s", p.PoolId(), strings.TrimPrefix(cmd, "/"), amount, code, confirmTimeout)
+	case "/confirm":
+		key := msg.ChatId + "/" + msg.UserId
+		b.mu.Lock()
+		a := b.pending[key]
+		delete(b.pending, key)
+		b.mu.Unlock()
+		if a == nil || time.Now().After(a.expires) {
+			return "Nothing to confirm"
+		}
+		if len(args) == 0 || args[0] != a.code {
+			return "Wrong confirmation code, cancelled"
+		}
+		if a.method == "stake" {
+			err := a.pool.Stake(ctx, a.amount, reason)
+			if err != nil {
+				return fmt.Sprintf("%s: stake failed: %s", a.pool.PoolId(), err)
+			}
+		} else {
+			err := a.pool.Unstake(ctx, a.amount, reason)
+			if err != nil {
+				return fmt.Sprintf("%s: unstake failed: %s", a.pool.PoolId(), err)
+			}
+		}
+		return fmt.Sprintf("%s: %sd %s", a.pool.PoolId(), a.method, a.amount)
+	}
+	return "Unknown command\n" + help
+}
+
+// selectPools returns the pool named in args, or all pools.
+func (b *Bot) selectPools(args []string) ([]Controller, error) {
+	if len(args) > 0 {
+		if _, err := common.ParseAccountID(args[0]); err != nil {
+			return nil, err
+		}
+		p, ok := b.pools[args[0]]
+		if !ok {
+			return nil, fmt.Errorf("unknown pool %s", args[0])
+		}
+		return []Controller{p}, nil
+	}
+	var ids []string
+	for id := range b.pools {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	var pools []Controller
+	for _, id := range ids {
+		pools = append(pools, b.pools[id])
+	}
+	return pools, nil
+}
+
+// selectPool returns the pool named in args, or the only pool.
+func (b *Bot) selectPool(args []string) (Controller, error) {
+	pools, err := b.selectPools(args)
+	if err != nil {
+		return nil, err
+	}
+	if len(pools) != 1 {
+		return nil, fmt.Errorf("%d pools are managed, name one", len(pools))
+	}
+	return pools[0], nil
+}
+
+func formatStatus(s runner.Status) string {
+	lines := []string{
+		fmt.Sprintf("%s (%s strategy)", s.PoolId, s.Strategy),
+		fmt.Sprintf("Left blocks: %d of %d", s.LeftBlocks, s.EpochLength),
+		fmt.Sprintf("Expected seats: %f", s.ExpectedSeats),
+		fmt.Sprintf("Current stake: %s", s.CurrentStake),
+		fmt.Sprintf("Expected stake: %s", s.ExpectedStake),
+		fmt.Sprintf("Seat price: current %s, next %s, expected %s", s.CurrentSeatPrice, s.NextSeatPrice, s.ExpectedSeatPrice),
+	}
+	var delegators []string
+	for d := range s.StakedBalance {
+		delegators = append(delegators, d)
+	}
+	sort.Strings(delegators)
+	for _, d := range delegators {
+		line := fmt.Sprintf("%s: staked %s, unstaked %s, liquid %s", d, s.StakedBalance[d], s.UnstakedBalance[d], s.LiquidBalance[d])
+		if epoch, ok := s.LockedUntil[d]; ok {
+			line += fmt.Sprintf(", locked until epoch %d", epoch)
+		}
+		lines = append(lines, line)
+	}
+	if s.Result != nil && s.Result.Kickout != nil {
+		k := s.Result.Kickout
+		lines = append(lines, fmt.Sprintf("Kicked out: %s. %s", k.Reason, k.Hint))
+	}
+	if s.Result != nil && s.Result.Pool != nil {
+		p := s.Result.Pool
+		lines = append(lines, fmt.Sprintf("Pool: total stake %s, %d accounts, fee %s, key %s", p.TotalStakedBalance, p.NumberOfAccounts, p.RewardFee, p.StakingKey))
+		if p.Paused {
+			lines = append(lines, "Staking is paused in the pool contract")
+		}
+	}
+	if s.Paused {
+		lines = append(lines, "Restaking is paused")
+	}
+	if s.DryRun {
+		lines = append(lines, "Dry run")
+	}
+	return strings.Join(lines, "\n")
+}
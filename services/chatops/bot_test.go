@@ -0,0 +1,68 @@
+package chatops
+
+import (
+	"context"
+	"regexp"
+	"testing"
+
+	"github.com/rozum-dev/near-go-warchest/common"
+	"github.com/rozum-dev/near-go-warchest/near-shell/runner"
+)
+
+type fakePool struct {
+	staked []common.Amount
+}
+
+func (p *fakePool) PoolId() string                                { return "pool.near" }
+func (p *fakePool) Status() runner.Status                         { return runner.Status{} }
+func (p *fakePool) Ping(ctx context.Context, reason string) error { return nil }
+func (p *fakePool) Pause()                                        {}
+func (p *fakePool) Resume()                                       {}
+func (p *fakePool) Stake(ctx context.Context, amount common.Amount, reason string) error {
+	p.staked = append(p.staked, amount)
+	return nil
+}
+func (p *fakePool) Unstake(ctx context.Context, amount common.Amount, reason string) error {
+	return nil
+}
+
+var confirmCode = regexp.MustCompile(`/confirm (\d{6}) `)
+
+func TestStakeNeedsTheConfirmationCode(t *testing.T) {
+	pool := &fakePool{}
+	b := NewBot(nil, []string{"1"}, []Controller{pool})
+	ctx := context.Background()
+	msg := func(text string) string {
+		return b.handle(ctx, Message{ChatId: "2", UserId: "1", Text: text})
+	}
+
+	codes := make(map[string]bool)
+	for i := 0; i < 5; i++ {
+		m := confirmCode.FindStringSubmatch(msg("/stake 10"))
+		if m == nil {
+			t.Fatal("no confirmation code")
+		}
+		codes[m[1]] = true
+	}
+	if len(codes) < 2 {
+		t.Errorf("the same code every time: %v", codes)
+	}
+
+	msg("/stake 10")
+	if reply := msg("/confirm 1234567"); len(pool.staked) != 0 {
+		t.Fatalf("staked with a wrong code: %s", reply)
+	}
+	// A wrong code drops the pending stake
+	if reply := msg("/confirm 123456"); reply != "Nothing to confirm" {
+		t.Errorf("second confirm: %s", reply)
+	}
+
+	m := confirmCode.FindStringSubmatch(msg("/stake 10"))
+	msg("/confirm " + m[1])
+	if len(pool.staked) != 1 || pool.staked[0].Cmp(common.NearAmount(10)) != 0 {
+		t.Errorf("staked %v, want 10 NEAR", pool.staked)
+	}
+	if reply := b.handle(ctx, Message{ChatId: "2", UserId: "3", Text: "/stake 10"}); reply != "" {
+		t.Errorf("answered a user who is not allowed: %s", reply)
+	}
+}
@@ -0,0 +1,264 @@
+package chatops
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Message is a text message received from a chat.
+type Message struct {
+	ChatId string
+	UserId string
+	Text   string
+}
+
+// Transport is a chat network the bot talks on.
+type Transport interface {
+	// Receive long-polls for new messages.
+	Receive(ctx context.Context) ([]Message, error)
+	Send(ctx context.Context, chatId, text string) error
+}
+
+// Config configures a chat-ops bot. URL is the Bot API base for telegram and
+// the homeserver for matrix.
+type Config struct {
+	Type         string   `yaml:"type"`
+	URL          string   `yaml:"url"`
+	Token        string   `yaml:"token"`
+	RoomId       string   `yaml:"room_id"`
+	AllowedUsers []string `yaml:"allowed_users"`
+}
+
+// New returns the transport described by c.
+func New(c Config) (Transport, error) {
+	if c.Token == "" {
+		return nil, fmt.Errorf("%s: token must be set", c.Type)
+	}
+	switch c.Type {
+	case "telegram":
+		return NewTelegram(c.URL, c.Token), nil
+	case "matrix":
+		if c.URL == "" || c.RoomId == "" {
+			return nil, fmt.Errorf("matrix: url and room_id must be set")
+		}
+		return NewMatrix(c.URL, c.Token, c.RoomId), nil
+	}
+	return nil, fmt.Errorf("unknown chat-ops transport %q", c.Type)
+}
+
+var httpClient = &http.Client{Timeout: 60 * time.Second}
+
+func doJSON(ctx context.Context, method, u string, body interface{}, out interface{}) error {
+	var payload []byte
+	if body != nil {
+		var err error
+		payload, err = json.Marshal(body)
+		if err != nil {
+			return err
+		}
+	}
+	req, err := http.NewRequest(method, u, bytes.NewReader(payload))
+	if err != nil {
+		return fmt.Errorf("%s %s: %v", method, redact(u), unwrap(err))
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Add("User-Agent", "Go-Warchest Bot")
+	r, err := httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("%s %s: %v", method, redact(u), unwrap(err))
+	}
+	defer r.Body.Close()
+	data, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return err
+	}
+	if r.StatusCode/100 != 2 {
+		return fmt.Errorf("%s %s: %s", method, redact(u), r.Status)
+	}
+	if out == nil {
+		return nil
+	}
+	return json.Unmarshal(data, out)
+}
+
+// redact hides the Telegram bot token in the path and the Matrix access token
+// in the query.
+func redact(u string) string {
+	p, err := url.Parse(u)
+	if err != nil {
+		return "(invalid url)"
+	}
+	p.User = nil
+	segments := strings.Split(p.Path, "/")
+	for i, s := range segments {
+		if strings.HasPrefix(s, "bot") {
+			segments[i] = "bot..."
+		}
+	}
+	p.Path, p.RawPath = strings.Join(segments, "/"), ""
+	q := p.Query()
+	if _, ok := q["access_token"]; ok {
+		q.Set("access_token", "...")
+		p.RawQuery = q.Encode()
+	}
+	return p.String()
+}
+
+// unwrap drops the full URL the http package puts in its errors.
+func unwrap(err error) error {
+	if inner := errors.Unwrap(err); inner != nil {
+		return inner
+	}
+	return err
+}
+
+// Telegram uses the Bot API with getUpdates long polling.
+type Telegram struct {
+	url, token string
+	offset     int64
+	started    bool
+}
+
+type telegramUpdates struct {
+	Ok     bool `json:"ok"`
+	Result []struct {
+		UpdateId int64 `json:"update_id"`
+		Message  *struct {
+			From struct {
+				Id int64 `json:"id"`
+			} `json:"from"`
+			Chat struct {
+				Id int64 `json:"id"`
+			} `json:"chat"`
+			Text string `json:"text"`
+		} `json:"message"`
+	} `json:"result"`
+}
+
+func NewTelegram(apiURL, token string) *Telegram {
+	if apiURL == "" {
+		apiURL = "https://api.telegram.org"
+	}
+	return &Telegram{url: strings.TrimSuffix(apiURL, "/"), token: token}
+}
+
+func (t *Telegram) method(name string) string {
+	return fmt.Sprintf("%s/bot%s/%s", t.url, t.token, name)
+}
+
+func (t *Telegram) Receive(ctx context.Context) ([]Message, error) {
+	// The first call only skips the commands sent while the bot was down, as
+	// the first sync of matrix does. Offset -1 returns the last update and
+	// forgets the ones before it.
+	if !t.started {
+		var res telegramUpdates
+		if err := doJSON(ctx, "GET", t.method("getUpdates")+"?timeout=0&offset=-1", nil, &res); err != nil {
+			return nil, err
+		}
+		for _, upd := range res.Result {
+			t.offset = upd.UpdateId + 1
+		}
+		t.started = true
+		return nil, nil
+	}
+	var res telegramUpdates
+	u := fmt.Sprintf("%s?timeout=30&offset=%d", t.method("getUpdates"), t.offset)
+	if err := doJSON(ctx, "GET", u, nil, &res); err != nil {
+		return nil, err
+	}
+	var messages []Message
+	for _, upd := range res.Result {
+		t.offset = upd.UpdateId + 1
+		if upd.Message == nil || upd.Message.Text == "" {
+			continue
+		}
+		messages = append(messages, Message{
+			ChatId: strconv.FormatInt(upd.Message.Chat.Id, 10),
+			UserId: strconv.FormatInt(upd.Message.From.Id, 10),
+			Text:   upd.Message.Text,
+		})
+	}
+	return messages, nil
+}
+
+func (t *Telegram) Send(ctx context.Context, chatId, text string) error {
+	return doJSON(ctx, "POST", t.method("sendMessage"), map[string]string{
+		"chat_id": chatId,
+		"text":    text,
+	}, nil)
+}
+
+// Matrix uses the client-server API /sync in one room.
+type Matrix struct {
+	url, token, roomId string
+	since              string
+	txn                int64
+}
+
+func NewMatrix(homeserverURL, token, roomId string) *Matrix {
+	return &Matrix{url: strings.TrimSuffix(homeserverURL, "/"), token: token, roomId: roomId}
+}
+
+func (m *Matrix) Receive(ctx context.Context) ([]Message, error) {
+	var res struct {
+		NextBatch string `json:"next_batch"`
+		Rooms     struct {
+			Join map[string]struct {
+				Timeline struct {
+					Events []struct {
+						Type    string `json:"type"`
+						Sender  string `json:"sender"`
+						Content struct {
+							MsgType string `json:"msgtype"`
+							Body    string `json:"body"`
+						} `json:"content"`
+					} `json:"events"`
+				} `json:"timeline"`
+			} `json:"join"`
+		} `json:"rooms"`
+	}
+	q := url.Values{}
+	q.Set("access_token", m.token)
+	q.Set("timeout", "30000")
+	if m.since != "" {
+		q.Set("since", m.since)
+	}
+	if err := doJSON(ctx, "GET", m.url+"/_matrix/client/r0/sync?"+q.Encode(), nil, &res); err != nil {
+		return nil, err
+	}
+	// The first sync only sets the position, old messages are not replayed
+	first := m.since == ""
+	m.since = res.NextBatch
+	if first {
+		return nil, nil
+	}
+	var messages []Message
+	room, ok := res.Rooms.Join[m.roomId]
+	if !ok {
+		return nil, nil
+	}
+	for _, e := range room.Timeline.Events {
+		if e.Type != "m.room.message" || e.Content.MsgType != "m.text" {
+			continue
+		}
+		messages = append(messages, Message{ChatId: m.roomId, UserId: e.Sender, Text: e.Content.Body})
+	}
+	return messages, nil
+}
+
+func (m *Matrix) Send(ctx context.Context, chatId, text string) error {
+	m.txn++
+	u := fmt.Sprintf("%s/_matrix/client/r0/rooms/%s/send/m.room.message/warchest%d%d?access_token=%s",
+		m.url, url.PathEscape(chatId), time.Now().UnixNano(), m.txn, url.QueryEscape(m.token))
+	return doJSON(ctx, "PUT", u, map[string]string{"msgtype": "m.text", "body": text}, nil)
+}
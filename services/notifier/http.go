@@ -0,0 +1,125 @@
+package notifier
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+)
+
+// postJSON posts v as JSON and fails on a non-2xx status.
+func postJSON(ctx context.Context, url string, v interface{}) error {
+	payload, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest("POST", url, bytes.NewReader(payload))
+	if err != nil {
+		return redactErr(url, err)
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Add("User-Agent", "Go-Warchest Bot")
+	r, err := httpClient.Do(req)
+	if err != nil {
+		return redactErr(url, err)
+	}
+	defer r.Body.Close()
+	if r.StatusCode/100 != 2 {
+		body, _ := ioutil.ReadAll(r.Body)
+		return fmt.Errorf("%s: %s %s", redact(url), r.Status, body)
+	}
+	return nil
+}
+
+// redact hides the path and query of webhook URLs, which contain the secret.
+func redact(url string) string {
+	if i := strings.Index(url, "://"); i >= 0 {
+		if j := strings.IndexAny(url[i+3:], "/?"); j >= 0 {
+			return url[:i+3+j] + "/..."
+		}
+	}
+	return url
+}
+
+// redactErr replaces the full URL the http package puts in its errors.
+func redactErr(url string, err error) error {
+	if inner := errors.Unwrap(err); inner != nil {
+		err = inner
+	}
+	return fmt.Errorf("%s: %v", redact(url), err)
+}
+
+type Telegram struct {
+	url, token, chatId string
+}
+
+// NewTelegram sends messages with the Bot API. apiURL defaults to https://api.telegram.org.
+func NewTelegram(apiURL, token, chatId string) (*Telegram, error) {
+	if token == "" || chatId == "" {
+		return nil, errors.New("telegram: token and chat_id are required")
+	}
+	if apiURL == "" {
+		apiURL = "https://api.telegram.org"
+	}
+	return &Telegram{url: strings.TrimSuffix(apiURL, "/"), token: token, chatId: chatId}, nil
+}
+
+func (t *Telegram) Notify(ctx context.Context, e Event) error {
+	return postJSON(ctx, fmt.Sprintf("%s/bot%s/sendMessage", t.url, t.token), map[string]string{
+		"chat_id": t.chatId,
+		"text":    e.String(),
+	})
+}
+
+type Slack struct {
+	url string
+}
+
+// NewSlack posts to an incoming webhook.
+func NewSlack(webhookURL string) (*Slack, error) {
+	if webhookURL == "" {
+		return nil, errors.New("slack: url is required")
+	}
+	return &Slack{url: webhookURL}, nil
+}
+
+func (s *Slack) Notify(ctx context.Context, e Event) error {
+	return postJSON(ctx, s.url, map[string]string{"text": e.String()})
+}
+
+type Discord struct {
+	url string
+}
+
+// NewDiscord posts to a channel webhook.
+func NewDiscord(webhookURL string) (*Discord, error) {
+	if webhookURL == "" {
+		return nil, errors.New("discord: url is required")
+	}
+	return &Discord{url: webhookURL}, nil
+}
+
+func (d *Discord) Notify(ctx context.Context, e Event) error {
+	return postJSON(ctx, d.url, map[string]string{"content": e.String()})
+}
+
+type Webhook struct {
+	url string
+}
+
+// NewWebhook posts every event as JSON.
+func NewWebhook(url string) (*Webhook, error) {
+	if url == "" {
+		return nil, errors.New("webhook: url is required")
+	}
+	return &Webhook{url: url}, nil
+}
+
+func (w *Webhook) Notify(ctx context.Context, e Event) error {
+	return postJSON(ctx, w.url, e)
+}
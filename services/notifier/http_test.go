@@ -0,0 +1,67 @@
+package notifier
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testSecret = "123456:SECRET-token"
+
+func TestPostJSONRedactsTheURL(t *testing.T) {
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "bad request", http.StatusBadRequest)
+	}))
+	defer failing.Close()
+	release := make(chan struct{})
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer slow.Close()
+	defer close(release)
+	closed := httptest.NewServer(http.NotFoundHandler())
+	closed.Close()
+
+	tests := []struct {
+		name    string
+		url     string
+		timeout time.Duration
+	}{
+		{"error status", failing.URL + "/bot" + testSecret + "/sendMessage", time.Second},
+		{"connection refused", closed.URL + "/bot" + testSecret + "/sendMessage", time.Second},
+		{"timeout", slow.URL + "/hooks/" + testSecret, 10 * time.Millisecond},
+		{"secret in the query", closed.URL + "?token=" + testSecret, time.Second},
+		{"invalid url", "http://host/" + testSecret + "/%zz", time.Second},
+	}
+	for _, tt := range tests {
+		ctx, cancel := context.WithTimeout(context.Background(), tt.timeout)
+		err := postJSON(ctx, tt.url, map[string]string{"text": "alert"})
+		cancel()
+		if err == nil {
+			t.Errorf("%s: no error", tt.name)
+			continue
+		}
+		if strings.Contains(err.Error(), testSecret) {
+			t.Errorf("%s: error %q shows the secret", tt.name, err)
+		}
+	}
+}
+
+func TestRedact(t *testing.T) {
+	tests := []struct {
+		url, want string
+	}{
+		{"https://api.telegram.org/bot" + testSecret + "/sendMessage", "https://api.telegram.org/..."},
+		{"https://hooks.slack.com/services/T0/B0/" + testSecret, "https://hooks.slack.com/..."},
+		{"https://example.com?token=" + testSecret, "https://example.com/..."},
+		{"https://example.com", "https://example.com"},
+	}
+	for _, tt := range tests {
+		if got := redact(tt.url); got != tt.want {
+			t.Errorf("redact(%q) = %q, want %q", tt.url, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,138 @@
+package notifier
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+)
+
+// Event kinds
+const (
+	KickedOut      = "kicked_out"
+	PingFailed     = "ping_failed"
+	StakeSucceeded = "stake_succeeded"
+	StakeFailed    = "stake_failed"
+	RPCDown        = "rpc_down"
+	SeatLost       = "seat_lost"
+	KickoutRisk    = "kickout_risk"
+	// Unstaked tokens can be withdrawn
+	UnstakeUnlocked   = "unstake_unlocked"
+	WithdrawSucceeded = "withdraw_succeeded"
+	WithdrawFailed    = "withdraw_failed"
+	// Changes of the staking pool contract
+	StakingKeyChanged = "staking_key_changed"
+	RewardFeeChanged  = "reward_fee_changed"
+	StakingPaused     = "staking_paused"
+)
+
+// Event is something an operator should know about.
+type Event struct {
+	Kind    string    `json:"kind"`
+	PoolId  string    `json:"pool_id"`
+	Message string    `json:"message"`
+	Time    time.Time `json:"time"`
+}
+
+func (e Event) String() string {
+	return fmt.Sprintf("[%s] %s: %s", e.Kind, e.PoolId, e.Message)
+}
+
+type Notifier interface {
+	Notify(ctx context.Context, e Event) error
+}
+
+// Config configures a backend. URL is the API base for telegram, the webhook
+// for slack, discord and webhook, and smtp://host:port for smtp.
+type Config struct {
+	Type     string   `yaml:"type"`
+	URL      string   `yaml:"url"`
+	Token    string   `yaml:"token"`
+	ChatId   string   `yaml:"chat_id"`
+	From     string   `yaml:"from"`
+	To       []string `yaml:"to"`
+	Username string   `yaml:"username"`
+	Password string   `yaml:"password"`
+}
+
+var httpClient = &http.Client{Timeout: 10 * time.Second}
+
+// New returns the backend described by c.
+func New(c Config) (Notifier, error) {
+	switch c.Type {
+	case "telegram":
+		return NewTelegram(c.URL, c.Token, c.ChatId)
+	case "slack":
+		return NewSlack(c.URL)
+	case "discord":
+		return NewDiscord(c.URL)
+	case "webhook":
+		return NewWebhook(c.URL)
+	case "smtp":
+		return NewSMTP(c.URL, c.Username, c.Password, c.From, c.To)
+	}
+	return nil, fmt.Errorf("unknown notifier %q", c.Type)
+}
+
+// Nop drops every event.
+type Nop struct{}
+
+func (Nop) Notify(ctx context.Context, e Event) error {
+	return nil
+}
+
+// Multi sends every event to all its notifiers.
+type Multi []Notifier
+
+func (m Multi) Notify(ctx context.Context, e Event) error {
+	var firstErr error
+	for _, n := range m {
+		if err := n.Notify(ctx, e); err != nil {
+			log.Printf("Failed to send notification: %s\n", err)
+			if firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+	return firstErr
+}
+
+// Async queues events and sends them in the background, so a slow backend
+// never blocks the monitor or the runner. Events are dropped when the queue is full.
+type Async struct {
+	n     Notifier
+	queue chan Event
+}
+
+func NewAsync(ctx context.Context, n Notifier, size int) *Async {
+	a := &Async{n: n, queue: make(chan Event, size)}
+	go a.run(ctx)
+	return a
+}
+
+func (a *Async) Notify(ctx context.Context, e Event) error {
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	log.Printf("Notify %s\n", e)
+	select {
+	case a.queue <- e:
+		return nil
+	default:
+		return fmt.Errorf("notification queue is full, dropped %s", e)
+	}
+}
+
+func (a *Async) run(ctx context.Context) {
+	for {
+		select {
+		case e := <-a.queue:
+			c, cancel := context.WithTimeout(ctx, 30*time.Second)
+			a.n.Notify(c, e)
+			cancel()
+		case <-ctx.Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,122 @@
+package notifier
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/smtp"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Longest time to deliver a mail, as for the HTTP backends
+const smtpTimeout = 10 * time.Second
+
+type SMTP struct {
+	addr, host string
+	auth       smtp.Auth
+	from       string
+	to         []string
+}
+
+// NewSMTP sends mail through the server at smtp://host:port.
+// Authentication is used when a username is given.
+func NewSMTP(serverURL, username, password, from string, to []string) (*SMTP, error) {
+	u, err := url.Parse(serverURL)
+	if err != nil || u.Scheme != "smtp" || u.Host == "" {
+		return nil, fmt.Errorf("smtp: url must look like smtp://host:port, got %q", serverURL)
+	}
+	if from == "" || len(to) == 0 {
+		return nil, errors.New("smtp: from and to are required")
+	}
+	addr := u.Host
+	host := u.Hostname()
+	if u.Port() == "" {
+		addr = net.JoinHostPort(host, "25")
+	}
+	s := &SMTP{addr: addr, host: host, from: from, to: to}
+	if username != "" {
+		s.auth = smtp.PlainAuth("", username, password, host)
+	}
+	return s, nil
+}
+
+func (s *SMTP) Notify(ctx context.Context, e Event) error {
+	msg := strings.Join([]string{
+		"From: " + s.from,
+		"To: " + strings.Join(s.to, ", "),
+		fmt.Sprintf("Subject: Go-Warchest %s: %s", e.PoolId, e.Kind),
+		"Content-Type: text/plain; charset=utf-8",
+		"",
+		e.Message,
+		"",
+	}, "\r\n")
+
+	ctx, cancel := context.WithTimeout(ctx, smtpTimeout)
+	defer cancel()
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", s.addr)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	deadline, _ := ctx.Deadline()
+	if err := conn.SetDeadline(deadline); err != nil {
+		return err
+	}
+	// A canceled context stops a server that is still answering
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			conn.Close()
+		case <-done:
+		}
+	}()
+	return s.send(conn, []byte(msg))
+}
+
+// send does what smtp.SendMail does, on a connection that is already open.
+func (s *SMTP) send(conn net.Conn, msg []byte) error {
+	c, err := smtp.NewClient(conn, s.host)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		if err := c.StartTLS(&tls.Config{ServerName: s.host}); err != nil {
+			return err
+		}
+	}
+	if s.auth != nil {
+		if ok, _ := c.Extension("AUTH"); !ok {
+			return errors.New("smtp: server doesn't support AUTH")
+		}
+		if err := c.Auth(s.auth); err != nil {
+			return err
+		}
+	}
+	if err := c.Mail(s.from); err != nil {
+		return err
+	}
+	for _, addr := range s.to {
+		if err := c.Rcpt(addr); err != nil {
+			return err
+		}
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(msg); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
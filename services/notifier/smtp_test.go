@@ -0,0 +1,112 @@
+package notifier
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// serveSMTP answers one session of plain SMTP and returns the mail data.
+func serveSMTP(l net.Listener) <-chan string {
+	out := make(chan string, 1)
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		reply := func(s string) { conn.Write([]byte(s + "\r\n")) }
+		reply("220 localhost ESMTP")
+		var data []string
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			cmd := strings.ToUpper(strings.TrimSpace(line))
+			switch {
+			case strings.HasPrefix(cmd, "EHLO"):
+				reply("250 localhost")
+			case cmd == "DATA":
+				reply("354 go ahead")
+				for {
+					line, err := r.ReadString('\n')
+					if err != nil {
+						return
+					}
+					if line == ".\r\n" {
+						break
+					}
+					data = append(data, line)
+				}
+				reply("250 queued")
+			case cmd == "QUIT":
+				reply("221 bye")
+				out <- strings.Join(data, "")
+				return
+			default:
+				reply("250 ok")
+			}
+		}
+	}()
+	return out
+}
+
+func listen(t *testing.T) net.Listener {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+	return l
+}
+
+func TestSMTPNotify(t *testing.T) {
+	l := listen(t)
+	mail := serveSMTP(l)
+	s, err := NewSMTP("smtp://"+l.Addr().String(), "", "", "warchest@example.com", []string{"ops@example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = s.Notify(context.Background(), Event{Kind: KickedOut, PoolId: "pool.near", Message: "kicked out"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := <-mail
+	for _, want := range []string{"Subject: Go-Warchest pool.near: kicked_out", "To: ops@example.com", "kicked out"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("mail %q does not contain %q", got, want)
+		}
+	}
+}
+
+func TestSMTPNotifyStopsWithTheContext(t *testing.T) {
+	l := listen(t)
+	// Accepts and never greets
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+	s, err := NewSMTP("smtp://"+l.Addr().String(), "", "", "warchest@example.com", []string{"ops@example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if err := s.Notify(ctx, Event{Kind: KickedOut, PoolId: "pool.near"}); err == nil {
+		t.Fatal("no error from a silent server")
+	}
+	if d := time.Since(start); d > 2*time.Second {
+		t.Errorf("gave up after %s", d)
+	}
+}
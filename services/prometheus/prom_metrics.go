@@ -9,71 +9,172 @@ import (
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
+// PromMetrics holds every metric of the warchest. Each metric carries a pool label;
+// use Pool to get the metrics of a single pool.
 type PromMetrics struct {
-	LeftBlocksGauge        prometheus.Gauge
-	PingGauge              prometheus.Gauge
-	RestakeGauge           prometheus.Gauge
-	StakeAmountGauge       prometheus.Gauge
-	NextSeatPriceGauge     prometheus.Gauge
-	ExpectedSeatPriceGauge prometheus.Gauge
-	ExpectedStakeGauge     prometheus.Gauge
-	ThresholdGauge         prometheus.Gauge
-	DStakedBalanceGauge    prometheus.Gauge
-	DUnStakedBalanceGauge  prometheus.Gauge
-	registry               *prometheus.Registry
+	LeftBlocksGauge          *prometheus.GaugeVec
+	PingGauge                *prometheus.GaugeVec
+	RestakeGauge             *prometheus.GaugeVec
+	StakeAmountGauge         *prometheus.GaugeVec
+	NextSeatPriceGauge       *prometheus.GaugeVec
+	ExpectedSeatPriceGauge   *prometheus.GaugeVec
+	ExpectedStakeGauge       *prometheus.GaugeVec
+	ThresholdGauge           *prometheus.GaugeVec
+	DStakedBalanceGauge      *prometheus.GaugeVec
+	DUnStakedBalanceGauge    *prometheus.GaugeVec
+	DLiquidBalanceGauge      *prometheus.GaugeVec
+	DecisionsCounter         *prometheus.CounterVec
+	DecisionAmountGauge      *prometheus.GaugeVec
+	KickoutGauge             *prometheus.GaugeVec
+	ProjectedProductionGauge *prometheus.GaugeVec
+	RPCHealthGauge           *prometheus.GaugeVec
+	LockedUntilEpochGauge    *prometheus.GaugeVec
+	PoolTotalStakeGauge      *prometheus.GaugeVec
+	PoolAccountsGauge        *prometheus.GaugeVec
+	PoolRewardFeeGauge       *prometheus.GaugeVec
+	PoolPausedGauge          *prometheus.GaugeVec
+	PoolInfoGauge            *prometheus.GaugeVec
+	registry                 *prometheus.Registry
+}
+
+// PoolMetrics are the metrics of one pool.
+type PoolMetrics struct {
+	LeftBlocksGauge          prometheus.Gauge
+	PingGauge                prometheus.Gauge
+	RestakeGauge             prometheus.Gauge
+	StakeAmountGauge         prometheus.Gauge
+	NextSeatPriceGauge       prometheus.Gauge
+	ExpectedSeatPriceGauge   prometheus.Gauge
+	ExpectedStakeGauge       prometheus.Gauge
+	ThresholdGauge           prometheus.Gauge
+	DStakedBalanceGauge      prometheus.Gauge
+	DUnStakedBalanceGauge    prometheus.Gauge
+	DLiquidBalanceGauge      prometheus.Gauge
+	DecisionsCounter         *prometheus.CounterVec
+	DecisionAmountGauge      *prometheus.GaugeVec
+	KickoutGauge             *prometheus.GaugeVec
+	ProjectedProductionGauge *prometheus.GaugeVec
+	RPCHealthGauge           *prometheus.GaugeVec
+	LockedUntilEpochGauge    *prometheus.GaugeVec
+	PoolTotalStakeGauge      prometheus.Gauge
+	PoolAccountsGauge        prometheus.Gauge
+	PoolRewardFeeGauge       prometheus.Gauge
+	PoolPausedGauge          prometheus.Gauge
+	PoolInfoGauge            *prometheus.GaugeVec
 }
 
 func NewPromMetrics() *PromMetrics {
-	leftBlocksGauge := prometheus.NewGauge(
+	poolLabel := []string{"pool"}
+	leftBlocksGauge := prometheus.NewGaugeVec(
 		prometheus.GaugeOpts{
 			Name: "warchest_left_blocks",
 			Help: "The number of blocks left in the current epoch",
-		})
-	pingGauge := prometheus.NewGauge(
+		}, poolLabel)
+	pingGauge := prometheus.NewGaugeVec(
 		prometheus.GaugeOpts{
 			Name: "warchest_ping",
 			Help: "Near ping",
-		})
-	restakeGauge := prometheus.NewGauge(
+		}, poolLabel)
+	restakeGauge := prometheus.NewGaugeVec(
 		prometheus.GaugeOpts{
 			Name: "warchest_restake",
 			Help: "Near stake/unstake event",
-		})
-	stakeAmountGauge := prometheus.NewGauge(
+		}, poolLabel)
+	stakeAmountGauge := prometheus.NewGaugeVec(
 		prometheus.GaugeOpts{
 			Name: "warchest_stake_amount",
 			Help: "The amount of stake",
-		})
-	nextSeatPriceGauge := prometheus.NewGauge(
+		}, poolLabel)
+	nextSeatPriceGauge := prometheus.NewGaugeVec(
 		prometheus.GaugeOpts{
 			Name: "warchest_next_seat_price",
 			Help: "The next seat price",
-		})
-	expectedSeatPriceGauge := prometheus.NewGauge(
+		}, poolLabel)
+	expectedSeatPriceGauge := prometheus.NewGaugeVec(
 		prometheus.GaugeOpts{
 			Name: "warchest_expected_seat_price",
 			Help: "The expected seat price",
-		})
-	expectedStakeGauge := prometheus.NewGauge(
+		}, poolLabel)
+	expectedStakeGauge := prometheus.NewGaugeVec(
 		prometheus.GaugeOpts{
 			Name: "warchest_expected_stake",
 			Help: "The expected stake",
-		})
-	thresholdGauge := prometheus.NewGauge(
+		}, poolLabel)
+	thresholdGauge := prometheus.NewGaugeVec(
 		prometheus.GaugeOpts{
 			Name: "warchest_threshold",
 			Help: "The kickout threshold",
-		})
-	dStakedBalanceGauge := prometheus.NewGauge(
+		}, poolLabel)
+	dStakedBalanceGauge := prometheus.NewGaugeVec(
 		prometheus.GaugeOpts{
 			Name: "warchest_delegator_staked_balance",
 			Help: "The delegator staked balance",
-		})
-	dUnStakedBalanceGauge := prometheus.NewGauge(
+		}, poolLabel)
+	dUnStakedBalanceGauge := prometheus.NewGaugeVec(
 		prometheus.GaugeOpts{
 			Name: "warchest_delegator_unstaked_balance",
 			Help: "The delegator unstaked balance",
-		})
+		}, poolLabel)
+	dLiquidBalanceGauge := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "warchest_delegator_liquid_balance",
+			Help: "The delegator account balance above the reserve, available to deposit and stake",
+		}, poolLabel)
+	decisionsCounter := prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "warchest_decisions_total",
+			Help: "The number of ping/stake/unstake decisions",
+		}, []string{"pool", "method", "dry_run"})
+	decisionAmountGauge := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "warchest_decision_amount",
+			Help: "The amount of the last decision per method and delegator",
+		}, []string{"pool", "method", "delegator", "dry_run"})
+	kickoutGauge := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "warchest_kickout",
+			Help: "1 for the reason the pool was kicked out in the previous epoch",
+		}, []string{"pool", "reason"})
+	projectedProductionGauge := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "warchest_projected_production",
+			Help: "Percent of expected blocks or chunks projected to be produced at the end of the epoch",
+		}, []string{"pool", "kind"})
+	rpcHealthGauge := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "warchest_rpc_health",
+			Help: "Health of an RPC endpoint from 0 to 1, by latency, error rate and block height lag",
+		}, []string{"pool", "endpoint"})
+	lockedUntilEpochGauge := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "warchest_unstaked_locked_until_epoch",
+			Help: "Epoch from which the unstaked balance of a delegator can be withdrawn, 0 when it is available or empty",
+		}, []string{"pool", "delegator"})
+	poolTotalStakeGauge := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "warchest_pool_total_staked_balance",
+			Help: "The total staked balance of the staking pool contract",
+		}, poolLabel)
+	poolAccountsGauge := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "warchest_pool_accounts",
+			Help: "The number of accounts with a balance in the staking pool contract",
+		}, poolLabel)
+	poolRewardFeeGauge := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "warchest_pool_reward_fee",
+			Help: "The reward fee fraction of the staking pool contract, from 0 to 1",
+		}, poolLabel)
+	poolPausedGauge := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "warchest_pool_staking_paused",
+			Help: "1 when staking is paused in the staking pool contract",
+		}, poolLabel)
+	poolInfoGauge := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "warchest_pool_info",
+			Help: "1 for the current owner and staking key of the staking pool contract",
+		}, []string{"pool", "owner", "staking_key"})
 
 	registry := prometheus.NewPedanticRegistry()
 	registry.MustRegister(leftBlocksGauge)
@@ -86,19 +187,72 @@ func NewPromMetrics() *PromMetrics {
 	registry.MustRegister(thresholdGauge)
 	registry.MustRegister(dStakedBalanceGauge)
 	registry.MustRegister(dUnStakedBalanceGauge)
+	registry.MustRegister(dLiquidBalanceGauge)
+	registry.MustRegister(decisionsCounter)
+	registry.MustRegister(decisionAmountGauge)
+	registry.MustRegister(kickoutGauge)
+	registry.MustRegister(projectedProductionGauge)
+	registry.MustRegister(rpcHealthGauge)
+	registry.MustRegister(lockedUntilEpochGauge)
+	registry.MustRegister(poolTotalStakeGauge)
+	registry.MustRegister(poolAccountsGauge)
+	registry.MustRegister(poolRewardFeeGauge)
+	registry.MustRegister(poolPausedGauge)
+	registry.MustRegister(poolInfoGauge)
 
 	return &PromMetrics{
-		LeftBlocksGauge:        leftBlocksGauge,
-		PingGauge:              pingGauge,
-		RestakeGauge:           restakeGauge,
-		StakeAmountGauge:       stakeAmountGauge,
-		NextSeatPriceGauge:     nextSeatPriceGauge,
-		ExpectedSeatPriceGauge: expectedSeatPriceGauge,
-		ExpectedStakeGauge:     expectedStakeGauge,
-		ThresholdGauge:         thresholdGauge,
-		DStakedBalanceGauge:    dStakedBalanceGauge,
-		DUnStakedBalanceGauge:  dUnStakedBalanceGauge,
-		registry:               registry,
+		LeftBlocksGauge:          leftBlocksGauge,
+		PingGauge:                pingGauge,
+		RestakeGauge:             restakeGauge,
+		StakeAmountGauge:         stakeAmountGauge,
+		NextSeatPriceGauge:       nextSeatPriceGauge,
+		ExpectedSeatPriceGauge:   expectedSeatPriceGauge,
+		ExpectedStakeGauge:       expectedStakeGauge,
+		ThresholdGauge:           thresholdGauge,
+		DStakedBalanceGauge:      dStakedBalanceGauge,
+		DUnStakedBalanceGauge:    dUnStakedBalanceGauge,
+		DLiquidBalanceGauge:      dLiquidBalanceGauge,
+		DecisionsCounter:         decisionsCounter,
+		DecisionAmountGauge:      decisionAmountGauge,
+		KickoutGauge:             kickoutGauge,
+		ProjectedProductionGauge: projectedProductionGauge,
+		RPCHealthGauge:           rpcHealthGauge,
+		LockedUntilEpochGauge:    lockedUntilEpochGauge,
+		PoolTotalStakeGauge:      poolTotalStakeGauge,
+		PoolAccountsGauge:        poolAccountsGauge,
+		PoolRewardFeeGauge:       poolRewardFeeGauge,
+		PoolPausedGauge:          poolPausedGauge,
+		PoolInfoGauge:            poolInfoGauge,
+		registry:                 registry,
+	}
+}
+
+// Pool returns the metrics labelled with the given pool id.
+func (m *PromMetrics) Pool(poolId string) *PoolMetrics {
+	labels := prometheus.Labels{"pool": poolId}
+	return &PoolMetrics{
+		LeftBlocksGauge:          m.LeftBlocksGauge.With(labels),
+		PingGauge:                m.PingGauge.With(labels),
+		RestakeGauge:             m.RestakeGauge.With(labels),
+		StakeAmountGauge:         m.StakeAmountGauge.With(labels),
+		NextSeatPriceGauge:       m.NextSeatPriceGauge.With(labels),
+		ExpectedSeatPriceGauge:   m.ExpectedSeatPriceGauge.With(labels),
+		ExpectedStakeGauge:       m.ExpectedStakeGauge.With(labels),
+		ThresholdGauge:           m.ThresholdGauge.With(labels),
+		DStakedBalanceGauge:      m.DStakedBalanceGauge.With(labels),
+		DUnStakedBalanceGauge:    m.DUnStakedBalanceGauge.With(labels),
+		DLiquidBalanceGauge:      m.DLiquidBalanceGauge.With(labels),
+		DecisionsCounter:         m.DecisionsCounter.MustCurryWith(labels),
+		DecisionAmountGauge:      m.DecisionAmountGauge.MustCurryWith(labels),
+		KickoutGauge:             m.KickoutGauge.MustCurryWith(labels),
+		ProjectedProductionGauge: m.ProjectedProductionGauge.MustCurryWith(labels),
+		RPCHealthGauge:           m.RPCHealthGauge.MustCurryWith(labels),
+		LockedUntilEpochGauge:    m.LockedUntilEpochGauge.MustCurryWith(labels),
+		PoolTotalStakeGauge:      m.PoolTotalStakeGauge.With(labels),
+		PoolAccountsGauge:        m.PoolAccountsGauge.With(labels),
+		PoolRewardFeeGauge:       m.PoolRewardFeeGauge.With(labels),
+		PoolPausedGauge:          m.PoolPausedGauge.With(labels),
+		PoolInfoGauge:            m.PoolInfoGauge.MustCurryWith(labels),
 	}
 }
 
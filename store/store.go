@@ -0,0 +1,254 @@
+package store
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/rozum-dev/near-go-warchest/common"
+)
+
+// Number of epochs of actions kept in the store
+const keepEpochs = 10
+
+// SeatPrices are the last seat prices seen by the runner.
+type SeatPrices struct {
+	Current  common.Amount `json:"current"`
+	Next     common.Amount `json:"next"`
+	Expected common.Amount `json:"expected"`
+}
+
+// Chain is the last block the runner saw, used to extrapolate block heights while the RPC is down.
+type Chain struct {
+	LatestBlockHeight int64 `json:"latest_block_height"`
+	EpochStartHeight  int64 `json:"epoch_start_height"`
+	EpochLength       int   `json:"epoch_length"`
+	BlocksPerTick     int   `json:"blocks_per_tick"`
+}
+
+// Action is a ping, stake or unstake that was sent.
+type Action struct {
+	Time        time.Time     `json:"time"`
+	Method      string        `json:"method"`
+	DelegatorId string        `json:"delegator_id"`
+	Amount      common.Amount `json:"amount"`
+	TxHash      string        `json:"tx_hash,omitempty"`
+}
+
+// PendingTx is a transaction that was signed but whose outcome is not known yet.
+type PendingTx struct {
+	Hash     string        `json:"hash"`
+	SignerId string        `json:"signer_id"`
+	Method   string        `json:"method"`
+	Amount   common.Amount `json:"amount"`
+	// Key and nonce it was signed with, a later nonce of the key means it
+	// can no longer be executed
+	PublicKey string `json:"public_key,omitempty"`
+	Nonce     uint64 `json:"nonce,omitempty"`
+	// Height of the block hash in the transaction
+	BlockHeight uint64    `json:"block_height,omitempty"`
+	Time        time.Time `json:"time"`
+}
+
+// Unlock is when the unstaked balance of a delegator can be withdrawn. The
+// pool locks the whole unstaked balance again on every unstake.
+type Unlock struct {
+	Epoch int64 `json:"epoch"`
+	// Guessed, the unstake was not made by the warchest
+	Estimated bool `json:"estimated,omitempty"`
+	// The pool said the balance is available
+	Available bool `json:"available,omitempty"`
+}
+
+// Contract is the last state of the staking pool contract the monitor saw,
+// so a change made while the warchest was down still alerts.
+type Contract struct {
+	OwnerId    string `json:"owner_id"`
+	StakingKey string `json:"staking_key"`
+	// Numerator and denominator
+	RewardFee [2]uint64 `json:"reward_fee"`
+	Paused    bool      `json:"paused"`
+}
+
+// PoolState is everything the runner of a pool has to remember across restarts.
+type PoolState struct {
+	// Start height of the last epoch in which the pool was pinged
+	LastPingedEpoch int64              `json:"last_pinged_epoch"`
+	SeatPrices      *SeatPrices        `json:"seat_prices,omitempty"`
+	Chain           *Chain             `json:"chain,omitempty"`
+	Actions         map[int64][]Action `json:"actions"`
+	PendingTxs      []PendingTx        `json:"pending_txs"`
+	// By delegator
+	Unlocks  map[string]Unlock `json:"unlocks,omitempty"`
+	Contract *Contract         `json:"contract,omitempty"`
+}
+
+type state struct {
+	Pools map[string]*PoolState `json:"pools"`
+}
+
+// Store keeps the state of every pool in a JSON file, rewritten atomically on every change.
+type Store struct {
+	path  string
+	mu    sync.Mutex
+	state state
+}
+
+// Open loads the store from path. A missing file is an empty store.
+func Open(path string) (*Store, error) {
+	s := &Store{
+		path:  path,
+		state: state{Pools: make(map[string]*PoolState)},
+	}
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &s.state); err != nil {
+		return nil, err
+	}
+	if s.state.Pools == nil {
+		s.state.Pools = make(map[string]*PoolState)
+	}
+	return s, nil
+}
+
+func (s *Store) pool(poolId string) *PoolState {
+	p, ok := s.state.Pools[poolId]
+	if !ok {
+		p = &PoolState{Actions: make(map[int64][]Action)}
+		s.state.Pools[poolId] = p
+	}
+	if p.Actions == nil {
+		p.Actions = make(map[int64][]Action)
+	}
+	return p
+}
+
+// save writes the state to a temporary file and renames it over the store file.
+func (s *Store) save() error {
+	data, err := json.MarshalIndent(s.state, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(s.path), filepath.Base(s.path)+".tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), s.path)
+}
+
+func (s *Store) update(poolId string, f func(p *PoolState)) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	f(s.pool(poolId))
+	return s.save()
+}
+
+// Pool returns a copy of the state of a pool.
+func (s *Store) Pool(poolId string) PoolState {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p := *s.pool(poolId)
+	p.Actions = make(map[int64][]Action, len(p.Actions))
+	for k, v := range s.state.Pools[poolId].Actions {
+		p.Actions[k] = append([]Action(nil), v...)
+	}
+	p.PendingTxs = append([]PendingTx(nil), p.PendingTxs...)
+	p.Unlocks = make(map[string]Unlock, len(p.Unlocks))
+	for k, v := range s.state.Pools[poolId].Unlocks {
+		p.Unlocks[k] = v
+	}
+	if p.Contract != nil {
+		c := *p.Contract
+		p.Contract = &c
+	}
+	return p
+}
+
+func (s *Store) SetLastPingedEpoch(poolId string, epochStartHeight int64) error {
+	return s.update(poolId, func(p *PoolState) {
+		p.LastPingedEpoch = epochStartHeight
+	})
+}
+
+func (s *Store) SetSeatPrices(poolId string, prices SeatPrices) error {
+	return s.update(poolId, func(p *PoolState) {
+		p.SeatPrices = &prices
+	})
+}
+
+func (s *Store) SetChain(poolId string, chain Chain) error {
+	return s.update(poolId, func(p *PoolState) {
+		p.Chain = &chain
+	})
+}
+
+// AddAction records an action in its epoch and drops the actions of old epochs.
+func (s *Store) AddAction(poolId string, epochStartHeight int64, a Action) error {
+	return s.update(poolId, func(p *PoolState) {
+		p.Actions[epochStartHeight] = append(p.Actions[epochStartHeight], a)
+		var epochs []int64
+		for e := range p.Actions {
+			epochs = append(epochs, e)
+		}
+		sort.Slice(epochs, func(i, j int) bool { return epochs[i] > epochs[j] })
+		for i := keepEpochs; i < len(epochs); i++ {
+			delete(p.Actions, epochs[i])
+		}
+	})
+}
+
+func (s *Store) SetContract(poolId string, c Contract) error {
+	return s.update(poolId, func(p *PoolState) {
+		p.Contract = &c
+	})
+}
+
+func (s *Store) AddPendingTx(poolId string, tx PendingTx) error {
+	return s.update(poolId, func(p *PoolState) {
+		p.PendingTxs = append(p.PendingTxs, tx)
+	})
+}
+
+func (s *Store) RemovePendingTx(poolId, hash string) error {
+	return s.update(poolId, func(p *PoolState) {
+		for i, tx := range p.PendingTxs {
+			if tx.Hash == hash {
+				p.PendingTxs = append(p.PendingTxs[:i], p.PendingTxs[i+1:]...)
+				return
+			}
+		}
+	})
+}
+
+func (s *Store) SetUnlock(poolId, delegatorId string, u Unlock) error {
+	return s.update(poolId, func(p *PoolState) {
+		if p.Unlocks == nil {
+			p.Unlocks = make(map[string]Unlock)
+		}
+		p.Unlocks[delegatorId] = u
+	})
+}
+
+func (s *Store) RemoveUnlock(poolId, delegatorId string) error {
+	return s.update(poolId, func(p *PoolState) {
+		delete(p.Unlocks, delegatorId)
+	})
+}
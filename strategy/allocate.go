@@ -0,0 +1,60 @@
+package strategy
+
+import (
+	"sort"
+
+	"github.com/rozum-dev/near-go-warchest/common"
+)
+
+type delegator struct {
+	delegatorBalance common.Amount
+	delegatorId      string
+}
+
+type entries []delegator
+
+func (s entries) Len() int { return len(s) }
+func (s entries) Less(i, j int) bool {
+	if c := s[i].delegatorBalance.Cmp(s[j].delegatorBalance); c != 0 {
+		return c < 0
+	}
+	return s[i].delegatorId > s[j].delegatorId
+}
+func (s entries) Swap(i, j int) { s[i], s[j] = s[j], s[i] }
+
+// Allocate splits amount over the delegators, taking from the largest balances
+// first, and returns one intent per delegator used.
+func Allocate(method string, delegatorBalances map[string]common.Amount, amount common.Amount, reason string) []Intent {
+	var delegatorBalancesSorted entries
+	for k, v := range delegatorBalances {
+		delegatorBalancesSorted = append(delegatorBalancesSorted, delegator{delegatorBalance: v, delegatorId: k})
+	}
+	sort.Sort(sort.Reverse(delegatorBalancesSorted))
+
+	var intents []Intent
+	for _, v := range delegatorBalancesSorted {
+		if amount.Sign() <= 0 {
+			break
+		}
+		if v.delegatorBalance.Sign() <= 0 {
+			continue
+		}
+		tokensAmount := common.MinAmount(amount, v.delegatorBalance)
+		intents = append(intents, Intent{
+			Method:      method,
+			DelegatorId: v.delegatorId,
+			Amount:      tokensAmount,
+			Reason:      reason,
+		})
+		amount = amount.Sub(tokensAmount)
+	}
+	return intents
+}
+
+func total(balances map[string]common.Amount) common.Amount {
+	var sum common.Amount
+	for _, b := range balances {
+		sum = sum.Add(b)
+	}
+	return sum
+}
@@ -0,0 +1,37 @@
+package strategy
+
+import (
+	"testing"
+
+	"github.com/rozum-dev/near-go-warchest/common"
+)
+
+func TestAllocate(t *testing.T) {
+	balances := map[string]common.Amount{
+		"a.near": common.NearAmount(100),
+		"b.near": common.NearAmount(300),
+		"c.near": {},
+	}
+	tests := []struct {
+		name   string
+		amount int64
+		want   map[string]int64
+	}{
+		{"largest first", 350, map[string]int64{"b.near": 300, "a.near": 50}},
+		{"one balance is enough", 200, map[string]int64{"b.near": 200}},
+		{"more than all balances", 1000, map[string]int64{"b.near": 300, "a.near": 100}},
+		{"nothing", 0, map[string]int64{}},
+	}
+	for _, tt := range tests {
+		intents := Allocate("stake", balances, common.NearAmount(tt.amount), "test")
+		if len(intents) != len(tt.want) {
+			t.Errorf("%s: got %+v, want %v", tt.name, intents, tt.want)
+			continue
+		}
+		for _, i := range intents {
+			if i.Method != "stake" || i.Amount.Cmp(common.NearAmount(tt.want[i.DelegatorId])) != 0 {
+				t.Errorf("%s: %s %s for %s, want %d NEAR", tt.name, i.Method, i.Amount, i.DelegatorId, tt.want[i.DelegatorId])
+			}
+		}
+	}
+}
@@ -0,0 +1,166 @@
+package strategy
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/rozum-dev/near-go-warchest/common"
+)
+
+// TargetSeats keeps the expected stake between N seats plus the offset and
+// N seats times the seat ceiling. With N = 1 it is the original one-seat policy.
+type TargetSeats struct {
+	Params
+	seats int64
+	name  string
+}
+
+func (t *TargetSeats) Name() string {
+	return t.name
+}
+
+func (t *TargetSeats) Decide(s *Snapshot) []Intent {
+	if s.LeftBlocks > t.Window {
+		log.Printf("Too early to stake/unstake, left blocks = %d", s.LeftBlocks)
+		return nil
+	}
+	seats := t.seats
+	if s.NumSeats > 0 && seats > int64(s.NumSeats) {
+		log.Printf("Only %d seats in the epoch, targeting all of them", s.NumSeats)
+		seats = int64(s.NumSeats)
+	}
+	return keepBetween(s, s.ExpectedSeatPrice.Mul(seats), float64(seats)*t.SeatCeiling, t.Offset, t.UnlockPolicy)
+}
+
+// Buffer keeps the expected stake a percentage above the expected seat price.
+type Buffer struct {
+	Params
+}
+
+func (b *Buffer) Name() string {
+	return BufferName
+}
+
+func (b *Buffer) Decide(s *Snapshot) []Intent {
+	if s.LeftBlocks > b.Window {
+		log.Printf("Too early to stake/unstake, left blocks = %d", s.LeftBlocks)
+		return nil
+	}
+	bp := int64(b.BufferPercent * 100)
+	target := s.ExpectedSeatPrice.MulFrac(10000+bp, 10000)
+	return keepBetween(s, target, target.Ratio(s.ExpectedSeatPrice)*b.SeatCeiling, b.Offset, b.UnlockPolicy)
+}
+
+// StakeAll stakes every unstaked balance within the window. It needs every
+// balance, so the unlocked ones are staked too unless the unlock policy is
+// withdraw.
+type StakeAll struct {
+	Params
+}
+
+func (a StakeAll) Name() string {
+	return StakeAllName
+}
+
+func (a StakeAll) Decide(s *Snapshot) []Intent {
+	if s.LeftBlocks > a.Window {
+		log.Printf("Too early to stake/unstake, left blocks = %d", s.LeftBlocks)
+		return nil
+	}
+	if a.UnlockPolicy != WithdrawUnlocked {
+		return Allocate("stake", s.UnstakedBalance, total(s.UnstakedBalance), "stake all available balance")
+	}
+	locked := make(map[string]common.Amount)
+	for delegatorId, amount := range s.UnstakedBalance {
+		if u, ok := s.Unlocked[delegatorId]; ok {
+			amount = amount.Sub(u)
+		}
+		if amount.Sign() > 0 {
+			locked[delegatorId] = amount
+		}
+	}
+	intents := Allocate("stake", locked, total(locked), "stake all available balance")
+	return append(intents, unlocked(s, WithdrawUnlocked, nil, common.Amount{})...)
+}
+
+// fund stakes need from the unstaked balances in the pool first, and deposits
+// and stakes the rest from the liquid balances of the delegator accounts.
+func fund(s *Snapshot, need common.Amount, reason string) []Intent {
+	unstaked := total(s.UnstakedBalance)
+	intents := Allocate("stake", s.UnstakedBalance, need, reason)
+	if need.Cmp(unstaked) <= 0 {
+		return intents
+	}
+	rest := need.Sub(unstaked)
+	if rest.Cmp(total(s.LiquidBalance)) > 0 {
+		log.Printf("Not enough unstaked and liquid balance to stake %s\n", need)
+	}
+	return append(intents, Allocate("deposit_and_stake", s.LiquidBalance, rest, reason)...)
+}
+
+// keepBetween stakes up to target plus offset when the expected stake is below target,
+// and unstakes down to target plus offset when the seats exceed the ceiling.
+// Unlocked balances it does not stake are handled by the unlock policy.
+func keepBetween(s *Snapshot, target common.Amount, ceiling float64, offset common.Amount, policy string) []Intent {
+	seats := s.Seats()
+	log.Printf("Expected seats: %f", seats)
+	// Stake that buys exactly the ceiling
+	ceilingStake := s.ExpectedSeatPrice.MulFrac(int64(ceiling*1e6), 1e6)
+	switch {
+	case s.ExpectedStake.Cmp(target) < 0:
+		need := target.Sub(s.ExpectedStake).Add(offset)
+		reason := fmt.Sprintf("expected stake %s is below target %s (%f seats)", s.ExpectedStake, target, seats)
+		log.Printf("You don't have enough stake: %s\n", reason)
+		intents := fund(s, need, reason)
+		room := ceilingStake.Sub(s.ExpectedStake).Sub(need)
+		return append(intents, unlocked(s, policy, intents, room)...)
+	case seats > ceiling:
+		excess := s.ExpectedStake.Sub(target).Sub(offset)
+		log.Printf("You retain %f seats\n", seats)
+		if excess.Sign() <= 0 {
+			// A ceiling close to the target is within the offset
+			log.Printf("Expected stake %s is within the offset of target %s, nothing to unstake\n", s.ExpectedStake, target)
+			return unlocked(s, policy, nil, common.Amount{})
+		}
+		reason := fmt.Sprintf("expected stake %s is above target %s (%f seats)", s.ExpectedStake, target, seats)
+		intents := Allocate("unstake", s.StakedBalance, excess, reason)
+		if len(intents) == 0 {
+			log.Printf("You don't have enough staked balance\n")
+		}
+		// An unstake locks the whole unstaked balance of the delegator
+		// again, so withdraw first. Nothing is restaked while unstaking.
+		return append(unlocked(s, policy, nil, common.Amount{}), intents...)
+	}
+	log.Println("I'm okay with a stake")
+	return unlocked(s, policy, nil, ceilingStake.Sub(s.ExpectedStake))
+}
+
+// unlocked withdraws the unlocked balances the stake intents do not use, or
+// restakes up to room of them, depending on the policy.
+func unlocked(s *Snapshot, policy string, intents []Intent, room common.Amount) []Intent {
+	left := make(map[string]common.Amount)
+	for delegatorId, amount := range s.Unlocked {
+		for _, intent := range intents {
+			if intent.DelegatorId == delegatorId && intent.Method == "stake" {
+				amount = amount.Sub(intent.Amount)
+			}
+		}
+		if amount.Sign() > 0 {
+			left[delegatorId] = amount
+		}
+	}
+	if len(left) == 0 {
+		return nil
+	}
+	switch policy {
+	case WithdrawUnlocked:
+		return Allocate("withdraw", left, total(left), "unstaked balance unlocked, withdraw policy")
+	case RestakeUnlocked:
+		if room.Sign() <= 0 {
+			return nil
+		}
+		reason := fmt.Sprintf("unstaked balance unlocked, restake policy up to the ceiling, %s of room", room)
+		return Allocate("stake", left, room, reason)
+	}
+	return nil
+}
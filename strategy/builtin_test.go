@@ -0,0 +1,246 @@
+package strategy
+
+import (
+	"bytes"
+	"log"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/rozum-dev/near-go-warchest/common"
+)
+
+func unlockParams(policy string) Params {
+	p := DefaultParams()
+	p.SeatCeiling = 1.5
+	p.UnlockPolicy = policy
+	return p
+}
+
+// snapshot has a seat price of 1000 NEAR and 500 NEAR unstaked and unlocked
+// for owner.near, which stakes 1000 NEAR.
+func snapshot(expectedStake int64) *Snapshot {
+	return &Snapshot{
+		LeftBlocks:        10,
+		ExpectedSeatPrice: common.NearAmount(1000),
+		ExpectedStake:     common.NearAmount(expectedStake),
+		StakedBalance:     map[string]common.Amount{"owner.near": common.NearAmount(1000)},
+		UnstakedBalance:   map[string]common.Amount{"owner.near": common.NearAmount(500)},
+		Unlocked:          map[string]common.Amount{"owner.near": common.NearAmount(500)},
+	}
+}
+
+func TestUnlockPolicies(t *testing.T) {
+	type call struct {
+		method string
+		amount int64
+	}
+	tests := []struct {
+		name          string
+		policy        string
+		expectedStake int64
+		leftBlocks    int
+		want          []call
+	}{
+		{"none keeps the unlocked balance", KeepUnlocked, 1200, 10, nil},
+		{"nothing outside the window", WithdrawUnlocked, 1200, 5000, nil},
+		{"withdraw within the seats", WithdrawUnlocked, 1200, 10, []call{{"withdraw", 500}}},
+		{"withdraw what the stake does not need", WithdrawUnlocked, 900, 10, []call{{"stake", 200}, {"withdraw", 300}}},
+		{"withdraw before unstaking", WithdrawUnlocked, 1600, 10, []call{{"withdraw", 500}, {"unstake", 500}}},
+		{"restake up to the ceiling", RestakeUnlocked, 1200, 10, []call{{"stake", 300}}},
+		{"restake all below the ceiling", RestakeUnlocked, 900, 10, []call{{"stake", 200}, {"stake", 300}}},
+		{"no restake while unstaking", RestakeUnlocked, 1600, 10, []call{{"unstake", 500}}},
+		{"no restake at the ceiling", RestakeUnlocked, 1500, 10, nil},
+	}
+	for _, tt := range tests {
+		s, err := New(OneSeatName, unlockParams(tt.policy))
+		if err != nil {
+			t.Fatal(err)
+		}
+		snap := snapshot(tt.expectedStake)
+		snap.LeftBlocks = tt.leftBlocks
+		intents := s.Decide(snap)
+		if len(intents) != len(tt.want) {
+			t.Errorf("%s: got %+v, want %v", tt.name, intents, tt.want)
+			continue
+		}
+		for i, w := range tt.want {
+			got := intents[i]
+			if got.Method != w.method || got.Amount.Cmp(common.NearAmount(w.amount)) != 0 || got.DelegatorId != "owner.near" {
+				t.Errorf("%s: intent %d is %s %s for %s, want %s %d NEAR", tt.name, i, got.Method, got.Amount, got.DelegatorId, w.method, w.amount)
+			}
+		}
+	}
+}
+
+func TestUnknownUnlockPolicy(t *testing.T) {
+	if _, err := New(OneSeatName, unlockParams("burn")); err == nil {
+		t.Error("accepted an unknown unlock policy")
+	}
+}
+
+type call struct {
+	method string
+	amount int64
+}
+
+func checkIntents(t *testing.T, name string, got []Intent, want []call) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Errorf("%s: got %+v, want %v", name, got, want)
+		return
+	}
+	for i, w := range want {
+		if got[i].Method != w.method || got[i].Amount.Cmp(common.NearAmount(w.amount)) != 0 {
+			t.Errorf("%s: intent %d is %s %s, want %s %d NEAR", name, i, got[i].Method, got[i].Amount, w.method, w.amount)
+		}
+	}
+}
+
+func TestBuiltinStrategies(t *testing.T) {
+	tests := []struct {
+		name          string
+		strategy      string
+		seats         int64
+		numSeats      int
+		leftBlocks    int
+		expectedStake int64
+		want          []call
+	}{
+		{"one seat below target", OneSeatName, 1, 0, 10, 900, []call{{"stake", 50}, {"deposit_and_stake", 150}}},
+		{"one seat at target", OneSeatName, 1, 0, 10, 1000, nil},
+		{"one seat above the ceiling", OneSeatName, 1, 0, 10, 1300, []call{{"unstake", 200}}},
+		{"one seat too early", OneSeatName, 1, 0, 5000, 900, nil},
+		{"target seats below target", TargetSeatsName, 3, 0, 10, 2500, []call{{"stake", 50}, {"deposit_and_stake", 550}}},
+		{"target seats above the seats of the epoch", TargetSeatsName, 3, 2, 10, 2500, []call{{"unstake", 400}}},
+		{"buffer below target", BufferName, 1, 0, 10, 1000, []call{{"stake", 50}, {"deposit_and_stake", 100}}},
+		{"buffer above the ceiling", BufferName, 1, 0, 10, 1200, []call{{"unstake", 50}}},
+		{"stake all", StakeAllName, 1, 0, 10, 5000, []call{{"stake", 50}}},
+		{"stake all too early", StakeAllName, 1, 0, 5000, 5000, nil},
+	}
+	for _, tt := range tests {
+		p := DefaultParams()
+		p.Seats = tt.seats
+		s, err := New(tt.strategy, p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		intents := s.Decide(&Snapshot{
+			LeftBlocks:        tt.leftBlocks,
+			NumSeats:          tt.numSeats,
+			ExpectedSeatPrice: common.NearAmount(1000),
+			ExpectedStake:     common.NearAmount(tt.expectedStake),
+			StakedBalance:     map[string]common.Amount{"owner.near": common.NearAmount(1000)},
+			UnstakedBalance:   map[string]common.Amount{"owner.near": common.NearAmount(50)},
+			LiquidBalance:     map[string]common.Amount{"owner.near": common.NearAmount(1000)},
+		})
+		checkIntents(t, tt.name, intents, tt.want)
+	}
+}
+
+func TestUnstakeWithinOffset(t *testing.T) {
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	defer log.SetOutput(os.Stderr)
+
+	s, err := New(OneSeatName, DefaultParams())
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 1.05 seats are above the ceiling of 1.001, but 50 NEAR above the
+	// target is within the offset of 100 NEAR
+	intents := s.Decide(snapshot(1050))
+	checkIntents(t, "within offset", intents, nil)
+	if strings.Contains(buf.String(), "enough staked balance") {
+		t.Errorf("logged %q", buf.String())
+	}
+	if !strings.Contains(buf.String(), "within the offset") {
+		t.Errorf("the reason is not logged: %q", buf.String())
+	}
+}
+
+func TestStakeAllUnlockPolicies(t *testing.T) {
+	tests := []struct {
+		policy string
+		want   []call
+	}{
+		{KeepUnlocked, []call{{"stake", 500}}},
+		{RestakeUnlocked, []call{{"stake", 500}}},
+		{WithdrawUnlocked, []call{{"stake", 200}, {"withdraw", 300}}},
+	}
+	for _, tt := range tests {
+		p := DefaultParams()
+		p.UnlockPolicy = tt.policy
+		s, err := New(StakeAllName, p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		snap := snapshot(1000)
+		snap.Unlocked["owner.near"] = common.NearAmount(300)
+		checkIntents(t, tt.policy, s.Decide(snap), tt.want)
+	}
+}
+
+func TestNewRejects(t *testing.T) {
+	p := DefaultParams()
+	p.Seats = 0
+	if _, err := New(TargetSeatsName, p); err == nil {
+		t.Error("target-seats accepted 0 seats")
+	}
+	p = DefaultParams()
+	p.BufferPercent = -1
+	if _, err := New(BufferName, p); err == nil {
+		t.Error("buffer accepted a negative percent")
+	}
+	if _, err := New("all-in", DefaultParams()); err == nil {
+		t.Error("accepted an unknown strategy")
+	}
+}
+
+func TestFund(t *testing.T) {
+	type intent struct {
+		method, delegatorId string
+		amount              int64
+	}
+	unstaked := map[string]common.Amount{"a.near": common.NearAmount(300), "b.near": common.NearAmount(100)}
+	liquid := map[string]common.Amount{"a.near": common.NearAmount(50), "c.near": common.NearAmount(200)}
+	tests := []struct {
+		name   string
+		need   int64
+		liquid map[string]common.Amount
+		want   []intent
+	}{
+		{"from the unstaked balances", 250, liquid, []intent{{"stake", "a.near", 250}}},
+		{"every unstaked balance", 400, liquid, []intent{{"stake", "a.near", 300}, {"stake", "b.near", 100}}},
+		{"deposit the rest", 450, liquid, []intent{{"stake", "a.near", 300}, {"stake", "b.near", 100}, {"deposit_and_stake", "c.near", 50}}},
+		{"deposit from every account", 1000, liquid, []intent{
+			{"stake", "a.near", 300}, {"stake", "b.near", 100}, {"deposit_and_stake", "c.near", 200}, {"deposit_and_stake", "a.near", 50},
+		}},
+		{"nothing liquid", 500, nil, []intent{{"stake", "a.near", 300}, {"stake", "b.near", 100}}},
+	}
+	for _, tt := range tests {
+		got := fund(&Snapshot{UnstakedBalance: unstaked, LiquidBalance: tt.liquid}, common.NearAmount(tt.need), "test")
+		if len(got) != len(tt.want) {
+			t.Errorf("%s: got %+v, want %v", tt.name, got, tt.want)
+			continue
+		}
+		for i, w := range tt.want {
+			if got[i].Method != w.method || got[i].DelegatorId != w.delegatorId || got[i].Amount.Cmp(common.NearAmount(w.amount)) != 0 {
+				t.Errorf("%s: intent %d is %s %s for %s, want %s %d NEAR for %s", tt.name, i, got[i].Method, got[i].Amount, got[i].DelegatorId, w.method, w.amount, w.delegatorId)
+			}
+		}
+	}
+}
+
+func TestStakeFromLiquidBalance(t *testing.T) {
+	// The seat and the offset need 600 NEAR more, 200 NEAR are in the pool
+	snap := snapshot(500)
+	snap.UnstakedBalance = map[string]common.Amount{"owner.near": common.NearAmount(200)}
+	snap.Unlocked = nil
+	snap.LiquidBalance = map[string]common.Amount{"owner.near": common.NearAmount(1000)}
+	s, err := New(OneSeatName, unlockParams(KeepUnlocked))
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkIntents(t, "deposit and stake", s.Decide(snap), []call{{"stake", 200}, {"deposit_and_stake", 400}})
+}
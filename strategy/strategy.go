@@ -0,0 +1,122 @@
+package strategy
+
+import (
+	"fmt"
+
+	"github.com/rozum-dev/near-go-warchest/common"
+)
+
+// Snapshot is the state of the epoch, the seat prices and the delegator balances
+// a strategy decides on.
+type Snapshot struct {
+	PoolId           string
+	EpochStartHeight int64
+	EpochLength      int
+	LeftBlocks       int
+	// Block producer seats in the epoch, 0 if unknown
+	NumSeats          int
+	CurrentSeatPrice  common.Amount
+	NextSeatPrice     common.Amount
+	ExpectedSeatPrice common.Amount
+	CurrentStake      common.Amount
+	NextStake         common.Amount
+	ExpectedStake     common.Amount
+	// Staked and unstaked balances of the delegators in the pool
+	StakedBalance   map[string]common.Amount
+	UnstakedBalance map[string]common.Amount
+	// Balances of the delegator accounts above their reserve, which can be
+	// added with deposit_and_stake
+	LiquidBalance map[string]common.Amount
+	// Unstaked balances the pool lets the delegators withdraw now
+	Unlocked map[string]common.Amount
+}
+
+// Seats is the number of seats the expected stake buys at the expected seat price.
+func (s *Snapshot) Seats() float64 {
+	return s.ExpectedStake.Ratio(s.ExpectedSeatPrice)
+}
+
+// Intent is a single pool call a strategy asks the runner to make: stake,
+// unstake, deposit_and_stake or withdraw.
+type Intent struct {
+	Method      string        `json:"method"`
+	DelegatorId string        `json:"delegator_id"`
+	Amount      common.Amount `json:"amount"`
+	Reason      string        `json:"reason"`
+}
+
+type Strategy interface {
+	Name() string
+	Decide(s *Snapshot) []Intent
+}
+
+// Params configures the built-in strategies.
+type Params struct {
+	// Act only when fewer blocks than this are left in the epoch
+	Window int
+	// Unstake when the expected seats exceed the target by this factor
+	SeatCeiling float64
+	// Keep the stake this far above the target
+	Offset common.Amount
+	// Number of seats for target-seats
+	Seats int64
+	// Percentage above the expected seat price for buffer
+	BufferPercent float64
+	// What to do with unlocked balances the stake does not need
+	UnlockPolicy string
+}
+
+func DefaultParams() Params {
+	return Params{
+		Window:        1000,
+		SeatCeiling:   1.001,
+		Offset:        common.NearAmount(100),
+		Seats:         1,
+		BufferPercent: 5,
+		UnlockPolicy:  KeepUnlocked,
+	}
+}
+
+// What to do with unstaked balances once they can be withdrawn
+const (
+	// Withdraw to the delegator account
+	WithdrawUnlocked = "withdraw"
+	// Stake again as long as the seats stay below the ceiling
+	RestakeUnlocked = "restake"
+	// Leave them in the pool
+	KeepUnlocked = "none"
+)
+
+// Names of the built-in strategies
+const (
+	OneSeatName     = "one-seat"
+	TargetSeatsName = "target-seats"
+	BufferName      = "buffer"
+	StakeAllName    = "stake-all"
+)
+
+// New returns the built-in strategy with the given name.
+func New(name string, p Params) (Strategy, error) {
+	switch p.UnlockPolicy {
+	case WithdrawUnlocked, RestakeUnlocked, KeepUnlocked, "":
+	default:
+		return nil, fmt.Errorf("unknown unlock policy %q", p.UnlockPolicy)
+	}
+	switch name {
+	case OneSeatName, "":
+		return &TargetSeats{Params: p, seats: 1, name: OneSeatName}, nil
+	case TargetSeatsName:
+		if p.Seats < 1 {
+			return nil, fmt.Errorf("%s: seats must be at least 1, got %d", name, p.Seats)
+		}
+		return &TargetSeats{Params: p, seats: p.Seats, name: TargetSeatsName}, nil
+	case BufferName:
+		if p.BufferPercent < 0 {
+			return nil, fmt.Errorf("%s: buffer percent must not be negative, got %f", name, p.BufferPercent)
+		}
+		return &Buffer{Params: p}, nil
+	case StakeAllName:
+		return StakeAll{Params: p}, nil
+	}
+	return nil, fmt.Errorf("unknown strategy %q", name)
+}